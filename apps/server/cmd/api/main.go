@@ -3,20 +3,29 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/api"
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/auth"
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/fanout"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
 )
 
+// shutdownTimeout bounds how long a shutdown waits for requests to finish
+// and rooms to flush
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	godotenv.Load()
 
@@ -138,8 +147,16 @@ func main() {
 
 	queries := database.New(db)
 	authHandler := auth.NewHandler(queries)
-	projectHandler := api.NewProjectHandler(queries)
-	collabHub := api.NewCollaborationHub()
+	projectHandler := api.NewProjectHandler(db, queries)
+
+	backend, err := newFanoutBackend(config, queries)
+	if err != nil {
+		log.Fatalf("Failed to start collaboration fan-out: %v", err)
+	}
+	defer backend.Close()
+
+	collabHub := api.NewCollaborationHub(db, queries, backend)
+	projectHandler.Hub = collabHub
 
 	mux := api.NewRouter(authHandler, projectHandler, collabHub)
 
@@ -147,6 +164,75 @@ func main() {
 	if port == "" {
 		port = "8080"
 	}
-	fmt.Printf("Server running on port %s\n", port)
-	log.Fatal(http.ListenAndServe(":"+port, mux))
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: mux,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	serverErr := make(chan error, 1)
+	go func() {
+		fmt.Printf("Server running on port %s\n", port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+		return
+	case <-ctx.Done():
+	}
+	stop()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// Stop accepting connections and wait for in-flight requests. Hijacked
+	// collaboration sockets aren't tracked by the server, so the hub closes
+	// those itself and waits for its rooms to flush.
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP server shutdown: %v", err)
+	}
+	if err := collabHub.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Collaboration hub shutdown: %v", err)
+	}
+
+	// The fan-out backend, *sql.DB and pgx connection are closed by the
+	// deferred calls above
+	log.Println("Server stopped")
+}
+
+// newFanoutBackend picks how collaboration rooms are shared between server
+// instances. COLLAB_FANOUT=postgres uses LISTEN/NOTIFY; anything else keeps
+// rooms local to this instance. Listening needs a session connection, so
+// COLLAB_LISTEN_URL can point it past a transaction-mode pooler.
+func newFanoutBackend(config *pgx.ConnConfig, queries *database.Queries) (fanout.Backend, error) {
+	if os.Getenv("COLLAB_FANOUT") != "postgres" {
+		return fanout.NewMemory().Connect(), nil
+	}
+
+	listenConfig := config.Copy()
+	if listenURL := os.Getenv("COLLAB_LISTEN_URL"); listenURL != "" {
+		parsed, err := pgx.ParseConfig(listenURL)
+		if err != nil {
+			return nil, fmt.Errorf("parse COLLAB_LISTEN_URL: %w", err)
+		}
+		parsed.LookupFunc = config.LookupFunc
+		parsed.DialFunc = config.DialFunc
+		listenConfig = parsed
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	backend, err := fanout.NewPostgres(ctx, listenConfig, queries, "skyforge_collaboration")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Collaboration fan-out: postgres")
+	return backend, nil
 }
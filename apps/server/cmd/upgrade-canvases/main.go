@@ -0,0 +1,106 @@
+// Command upgrade-canvases rewrites every stored project canvas to the
+// current canvas version. Canvases are also upgraded on load, so running it
+// is optional; it saves the work on every later read and lets old upgrade
+// steps eventually be retired.
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "report what would change without writing")
+	batchSize := flag.Int("batch", 200, "projects to load per query")
+	flag.Parse()
+
+	godotenv.Load()
+
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		dbURL = os.Getenv("DB_URL")
+		if dbURL == "" {
+			log.Fatal("DATABASE_URL or DB_URL environment variable not set")
+		}
+	}
+
+	config, err := pgx.ParseConfig(dbURL)
+	if err != nil {
+		log.Fatalf("Error parsing database URL: %v", err)
+	}
+	config.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+
+	var db *sql.DB = stdlib.OpenDB(*config)
+	defer db.Close()
+	queries := database.New(db)
+
+	ctx := context.Background()
+	var scanned, upgraded, skipped, failed int
+	after := uuid.Nil
+	for {
+		rows, err := queries.ListProjectCanvasesAfter(ctx, database.ListProjectCanvasesAfterParams{
+			ID:    after,
+			Limit: int32(*batchSize),
+		})
+		if err != nil {
+			log.Fatalf("Failed to list projects: %v", err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			after = row.ID
+			scanned++
+
+			data, changed, err := canvas.Upgrade(row.Data)
+			if err != nil {
+				log.Printf("Project %s: %v", row.ID, err)
+				failed++
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if *dryRun {
+				log.Printf("Project %s: would upgrade", row.ID)
+				upgraded++
+				continue
+			}
+
+			// Only replace the canvas this run read, so edits saved since
+			// are left for the next run
+			n, err := queries.ReplaceProjectCanvas(ctx, database.ReplaceProjectCanvasParams{
+				Data:     data,
+				ID:       row.ID,
+				Previous: row.Data,
+			})
+			switch {
+			case err != nil:
+				log.Printf("Project %s: failed to save: %v", row.ID, err)
+				failed++
+			case n == 0:
+				log.Printf("Project %s: changed while upgrading, skipped", row.ID)
+				skipped++
+			default:
+				upgraded++
+			}
+		}
+	}
+
+	log.Printf("Scanned %d projects: %d upgraded, %d skipped, %d failed (canvas version %d)",
+		scanned, upgraded, skipped, failed, canvas.CurrentVersion)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
 )
@@ -18,46 +19,6 @@ type AIService struct {
 	model  *genai.GenerativeModel
 }
 
-// Column represents a table column in canvas format
-type Column struct {
-	ID           string   `json:"id"`
-	Name         string   `json:"name"`
-	Type         string   `json:"type"`
-	IsPrimaryKey bool     `json:"isPrimaryKey"`
-	Constraints  []string `json:"constraints"`
-}
-
-// TableData represents table data in canvas format
-type TableData struct {
-	Name    string   `json:"name"`
-	Columns []Column `json:"columns"`
-}
-
-// Node represents a React Flow node
-type Node struct {
-	ID       string             `json:"id"`
-	Type     string             `json:"type"`
-	Position map[string]float64 `json:"position"`
-	Data     TableData          `json:"data"`
-}
-
-// Edge represents a React Flow edge (for foreign key relationships)
-type Edge struct {
-	ID           string `json:"id"`
-	Source       string `json:"source"`
-	Target       string `json:"target"`
-	SourceHandle string `json:"sourceHandle"`
-	TargetHandle string `json:"targetHandle"`
-	Type         string `json:"type"`
-	Animated     bool   `json:"animated"`
-}
-
-// CanvasData represents the full canvas structure
-type CanvasData struct {
-	Nodes []Node `json:"nodes"`
-	Edges []Edge `json:"edges"`
-}
-
 // AIGeneratedTable represents a table as returned by the AI
 type AIGeneratedTable struct {
 	Name    string `json:"name"`
@@ -65,6 +26,8 @@ type AIGeneratedTable struct {
 		Name         string   `json:"name"`
 		Type         string   `json:"type"`
 		IsPrimaryKey bool     `json:"isPrimaryKey"`
+		IsUnique     bool     `json:"isUnique"`
+		IsNullable   bool     `json:"isNullable"`
 		Constraints  []string `json:"constraints"`
 	} `json:"columns"`
 }
@@ -110,7 +73,7 @@ func generateID() string {
 }
 
 // GenerateTablesFromPrompt generates canvas-compatible table data from a user prompt
-func (s *AIService) GenerateTablesFromPrompt(prompt string) (*CanvasData, error) {
+func (s *AIService) GenerateTablesFromPrompt(prompt string) (*canvas.Canvas, error) {
 	systemPrompt := `You are a database schema designer. Based on the user's description, generate a database schema with tables and their relationships.
 
 IMPORTANT: Return ONLY valid JSON, no markdown, no backticks, no explanations.
@@ -125,6 +88,8 @@ The JSON must follow this exact structure:
           "name": "column_name",
           "type": "uuid|varchar(255)|text|integer|bigint|boolean|timestamp|date|decimal(10,2)|jsonb",
           "isPrimaryKey": true/false,
+          "isUnique": true/false,
+          "isNullable": true/false,
           "constraints": ["NN"] 
         }
       ]
@@ -145,7 +110,7 @@ Rules:
 2. Use snake_case for all table and column names
 3. Include created_at (timestamp) and updated_at (timestamp) columns for most tables
 4. Use appropriate data types: uuid for IDs, varchar(255) for short strings, text for long content, integer for counts, boolean for flags, timestamp for dates
-5. Add "NN" (NOT NULL) constraint to required fields
+5. Add "NN" (NOT NULL) constraint to required fields and set "isNullable" to true on optional ones; set "isUnique" on columns that must be unique
 6. For foreign keys, name them as: referenced_table_id (e.g., user_id references users.id)
 7. Create sensible relationships between tables based on the user's description
 8. Return ONLY the JSON object, nothing else`
@@ -188,10 +153,7 @@ Rules:
 	}
 
 	// Convert AI response to canvas format
-	canvasData := &CanvasData{
-		Nodes: make([]Node, 0, len(aiResp.Tables)),
-		Edges: make([]Edge, 0, len(aiResp.Relations)),
-	}
+	canvasData := canvas.New()
 
 	// Track table name to node ID and column name to column ID mappings
 	tableNodeMap := make(map[string]string)
@@ -207,27 +169,26 @@ Rules:
 		x := float64((i%3)*350 + 100)
 		y := float64((i/3)*400 + 100)
 
-		columns := make([]Column, 0, len(table.Columns))
+		columns := make([]canvas.Column, 0, len(table.Columns))
 		for _, col := range table.Columns {
 			colID := generateID()
 			columnIDMap[nodeID][col.Name] = colID
-			columns = append(columns, Column{
+			columns = append(columns, canvas.Column{
 				ID:           colID,
 				Name:         col.Name,
 				Type:         col.Type,
 				IsPrimaryKey: col.IsPrimaryKey,
+				IsUnique:     col.IsUnique,
+				IsNullable:   col.IsNullable && !col.IsPrimaryKey,
 				Constraints:  col.Constraints,
 			})
 		}
 
-		node := Node{
-			ID:   nodeID,
-			Type: "tableNode",
-			Position: map[string]float64{
-				"x": x,
-				"y": y,
-			},
-			Data: TableData{
+		node := canvas.Node{
+			ID:       nodeID,
+			Type:     canvas.NodeTypeTable,
+			Position: &canvas.Position{X: x, Y: y},
+			Data: canvas.TableData{
 				Name:    table.Name,
 				Columns: columns,
 			},
@@ -235,29 +196,30 @@ Rules:
 		canvasData.Nodes = append(canvasData.Nodes, node)
 	}
 
-	// Create edges for relationships
+	// Create edges for relationships. The AI names the child table "from",
+	// while an edge runs from the referenced table to the child.
 	for _, rel := range aiResp.Relations {
-		sourceNodeID, sourceExists := tableNodeMap[rel.FromTable]
-		targetNodeID, targetExists := tableNodeMap[rel.ToTable]
+		sourceNodeID, sourceExists := tableNodeMap[rel.ToTable]
+		targetNodeID, targetExists := tableNodeMap[rel.FromTable]
 
 		if !sourceExists || !targetExists {
 			continue
 		}
 
-		sourceColID, sourceColExists := columnIDMap[sourceNodeID][rel.FromColumn]
-		targetColID, targetColExists := columnIDMap[targetNodeID][rel.ToColumn]
+		sourceColID, sourceColExists := columnIDMap[sourceNodeID][rel.ToColumn]
+		targetColID, targetColExists := columnIDMap[targetNodeID][rel.FromColumn]
 
 		if !sourceColExists || !targetColExists {
 			continue
 		}
 
-		edge := Edge{
+		edge := canvas.Edge{
 			ID:           generateID(),
 			Source:       sourceNodeID,
 			Target:       targetNodeID,
-			SourceHandle: fmt.Sprintf("%s-source", sourceColID),
-			TargetHandle: fmt.Sprintf("%s-target", targetColID),
-			Type:         "smoothstep",
+			SourceHandle: canvas.SourceHandle(sourceColID),
+			TargetHandle: canvas.TargetHandle(targetColID),
+			Type:         canvas.EdgeTypeSmoothStep,
 			Animated:     true,
 		}
 		canvasData.Edges = append(canvasData.Edges, edge)
@@ -273,12 +235,6 @@ func min(a, b int) int {
 	return b
 }
 
-// ExportRequest represents the canvas data for export
-type ExportRequest struct {
-	Nodes []Node `json:"nodes"`
-	Edges []Edge `json:"edges"`
-}
-
 // GenerateSQLFromCanvas generates PostgreSQL DDL from canvas data using AI
 func (s *AIService) GenerateSQLFromCanvas(canvasJSON []byte) (string, error) {
 	systemPrompt := `You are an expert PostgreSQL database architect. Given a database schema in JSON format, generate production-ready PostgreSQL DDL (Data Definition Language).
@@ -290,11 +246,11 @@ Requirements:
 2. Use appropriate PostgreSQL data types
 3. Add PRIMARY KEY constraints inline or as table constraints
 4. Add NOT NULL constraints where specified (columns with "NN" in constraints)
-5. Add UNIQUE constraints where appropriate
+5. Add UNIQUE constraints where appropriate, and a CHECK constraint for each entry in a table's "checks" (its "name" and "expression"; "columnId" is the column it belongs to)
 6. Generate ALTER TABLE statements for FOREIGN KEY constraints based on the edges (relationships)
 7. Create indexes on foreign key columns for better query performance
 8. Use snake_case for all identifiers
-9. Add appropriate DEFAULT values:
+9. Add DEFAULT values. A column's "default" takes precedence: kind "literal" is a constant value, "expression" is SQL to use as written, and "sequence" makes the column auto-increment. Otherwise use appropriate defaults:
    - uuid columns: DEFAULT gen_random_uuid()
    - serial/bigserial: auto-increment (no explicit default needed)
    - created_at: DEFAULT CURRENT_TIMESTAMP
@@ -304,10 +260,13 @@ Requirements:
 12. Group related statements together with comments
 
 Edge format explanation:
-- "source" is the table ID containing the foreign key
-- "target" is the table ID being referenced
-- "sourceHandle" contains the column ID of the foreign key (format: "columnId-source")
-- "targetHandle" contains the column ID being referenced (format: "columnId-target")
+- "source" is the table ID being referenced
+- "target" is the table ID containing the foreign key
+- "sourceHandle" contains the column ID being referenced (format: "columnId-source")
+- "targetHandle" contains the column ID of the foreign key (format: "columnId-target")
+- "data.columns", when present, lists every {source, target} column ID pair of a composite foreign key in order
+- "data.onDelete" and "data.onUpdate", when present, are the referential actions (no_action, restrict, cascade, set_null, set_default)
+- "data.deferrable" and "data.initiallyDeferred", when true, make the foreign key DEFERRABLE and INITIALLY DEFERRED
 
 Generate clean, well-formatted, production-ready SQL.`
 
@@ -378,7 +337,7 @@ Requirements:
 6. Add appropriate attributes:
    - @id for primary keys
    - @unique for unique constraints
-   - @default() with appropriate values
+   - @default() from a column's "default" when it has one (literal, expression or sequence), otherwise with appropriate values
    - @updatedAt for updated_at columns
    - @map() for column name mapping if needed
    - @@map() for table name mapping if needed
@@ -388,13 +347,18 @@ Requirements:
    - Use @relation directive with fields and references
    - Name relation fields appropriately (singular for belongsTo, plural for hasMany)
 
-8. Add a header comment: // Generated by Skyforge AI
+8. Prisma has no CHECK constraints: for each entry in a table's "checks", add a comment in the model giving its name and expression
+
+9. Add a header comment: // Generated by Skyforge AI
 
 Edge format explanation:
-- "source" is the table ID containing the foreign key
-- "target" is the table ID being referenced  
-- "sourceHandle" contains the column ID of the foreign key (format: "columnId-source")
-- "targetHandle" contains the column ID being referenced (format: "columnId-target")
+- "source" is the table ID being referenced
+- "target" is the table ID containing the foreign key
+- "sourceHandle" contains the column ID being referenced (format: "columnId-source")
+- "targetHandle" contains the column ID of the foreign key (format: "columnId-target")
+- "data.columns", when present, lists every {source, target} column ID pair of a composite foreign key in order
+- "data.onDelete" and "data.onUpdate", when present, are the referential actions (no_action, restrict, cascade, set_null, set_default)
+- "data.deferrable" and "data.initiallyDeferred", when true, make the foreign key DEFERRABLE and INITIALLY DEFERRED
 
 Generate clean, well-formatted, production-ready Prisma schema.`
 
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// clientSendBuffer is how many outbound messages a client may have queued
+// before it counts as too slow to keep up with its room
+const clientSendBuffer = 256
+
+type outboundMessage struct {
+	messageType int
+	data        []byte
+}
+
+// client is one collaboration socket. Every write goes through its send
+// queue and is performed by its writer goroutine, so a slow peer never holds
+// up the rest of the room and the connection is never written concurrently.
+type client struct {
+	conn      *websocket.Conn
+	send      chan outboundMessage
+	done      chan struct{}
+	closeOnce sync.Once
+	readOnly  bool
+
+	userID    uuid.UUID
+	name      string
+	avatarURL *string
+	role      Role
+	// wantsPresence is set for sockets that asked for presence frames
+	wantsPresence bool
+
+	mu           sync.Mutex
+	awarenessIDs map[uint64]bool
+	presence     presenceState
+}
+
+func newClient(conn *websocket.Conn, user database.User, role Role, wantsPresence bool) *client {
+	c := &client{
+		conn:          conn,
+		send:          make(chan outboundMessage, clientSendBuffer),
+		done:          make(chan struct{}),
+		readOnly:      !role.Can(PermissionEdit),
+		userID:        user.ID,
+		name:          user.Name,
+		role:          role,
+		wantsPresence: wantsPresence,
+		awarenessIDs:  make(map[uint64]bool),
+	}
+	if user.AvatarUrl.Valid {
+		c.avatarURL = &user.AvatarUrl.String
+	}
+	return c
+}
+
+// queue hands a message to the writer without blocking. It returns false if
+// the queue is full or the client is closed.
+func (c *client) queue(messageType int, data []byte) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- outboundMessage{messageType: messageType, data: data}:
+		return true
+	default:
+		return false
+	}
+}
+
+// deliver queues a message and handles a full queue. Awareness and presence
+// are resent on every change, so they are dropped. A dropped document update
+// would leave the peer out of sync, so the client is disconnected instead;
+// its provider reconnects and resyncs from the room's document.
+func (c *client) deliver(messageType int, data []byte) {
+	if c.queue(messageType, data) {
+		return
+	}
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+
+	if msg, err := yjs.DecodeMessage(data); messageType == websocket.BinaryMessage && err == nil && (msg.Type == yjs.MessageAwareness || msg.Type == messagePresence) {
+		return
+	}
+	log.Printf("Disconnecting slow collaboration client %s", c.conn.RemoteAddr())
+	c.close()
+}
+
+// close stops the writer and closes the connection, which also ends the
+// client's read loop. It is safe to call more than once.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+// goAway tells the peer the server is going away, then closes the client.
+// The peer reconnects, to this server or another.
+func (c *client) goAway(reason string) {
+	message := websocket.FormatCloseMessage(websocket.CloseGoingAway, reason)
+	c.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(time.Second))
+	c.close()
+}
+
+// writePump writes queued messages and periodic pings until the client is
+// closed or a write fails
+func (c *client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.close()
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(msg.messageType, msg.data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/gorilla/websocket"
+)
+
+// newTestClient connects a websocket pair and wraps the server side in a
+// client. The writer isn't started, so the queue only fills.
+func newTestClient(t *testing.T) *client {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		conns <- conn
+	}))
+	t.Cleanup(srv.Close)
+
+	peer, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { peer.Close() })
+
+	c := newClient(<-conns, database.User{Name: "test"}, RoleEditor, true)
+	t.Cleanup(c.close)
+	return c
+}
+
+// fillQueue queues messages until the send queue is full
+func fillQueue(t *testing.T, c *client) {
+	t.Helper()
+	for i := 0; i < clientSendBuffer; i++ {
+		if !c.queue(websocket.BinaryMessage, yjs.EncodeSyncMessage(yjs.SyncUpdate, []byte{0, 0})) {
+			t.Fatalf("queue full after %d messages", i)
+		}
+	}
+	if c.queue(websocket.BinaryMessage, []byte{0}) {
+		t.Fatal("queue accepted more than clientSendBuffer messages")
+	}
+}
+
+func isClosed(c *client) bool {
+	select {
+	case <-c.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestDeliverDropsAwarenessWhenQueueIsFull(t *testing.T) {
+	c := newTestClient(t)
+	fillQueue(t, c)
+
+	c.deliver(websocket.BinaryMessage, yjs.EncodeMessage(yjs.MessageAwareness, []byte{0}))
+	c.deliver(websocket.BinaryMessage, yjs.EncodeMessage(messagePresence, []byte("{}")))
+
+	if isClosed(c) {
+		t.Fatal("client was disconnected for dropped awareness and presence")
+	}
+	if len(c.send) != clientSendBuffer {
+		t.Fatalf("queue holds %d messages, want %d", len(c.send), clientSendBuffer)
+	}
+}
+
+func TestDeliverDisconnectsOnDocumentUpdateOverflow(t *testing.T) {
+	c := newTestClient(t)
+	fillQueue(t, c)
+
+	c.deliver(websocket.BinaryMessage, yjs.EncodeSyncMessage(yjs.SyncUpdate, []byte{0, 0}))
+
+	if !isClosed(c) {
+		t.Fatal("client kept after a document update overflowed its queue")
+	}
+	if c.queue(websocket.BinaryMessage, []byte{0}) {
+		t.Fatal("closed client accepted a message")
+	}
+}
+
+func TestWritePumpExitsOnClose(t *testing.T) {
+	c := newTestClient(t)
+	fillQueue(t, c)
+
+	exited := make(chan struct{})
+	go func() {
+		c.writePump()
+		close(exited)
+	}()
+	c.close()
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("writer still running after close")
+	}
+}
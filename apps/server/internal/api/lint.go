@@ -0,0 +1,198 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/compiler"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/google/uuid"
+)
+
+// lintRuleResponse is a lint rule together with whether the project runs it
+type lintRuleResponse struct {
+	compiler.LintRule
+	Enabled bool `json:"enabled"`
+}
+
+type lintResponse struct {
+	Results []compiler.LintResult `json:"results"`
+	Rules   []lintRuleResponse    `json:"rules"`
+}
+
+// lintProjectRequest optionally carries an unsaved canvas to lint instead of
+// the stored one
+type lintProjectRequest struct {
+	Data json.RawMessage `json:"data"`
+}
+
+type updateLintRulesRequest struct {
+	Rules map[string]bool `json:"rules"`
+}
+
+// projectLintConfig loads the project's rule overrides, keyed by rule ID
+func (h *ProjectHandler) projectLintConfig(ctx context.Context, projectID uuid.UUID) (map[string]bool, error) {
+	rows, err := h.DB.ListProjectLintRules(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	enabled := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		enabled[row.RuleID] = row.Enabled
+	}
+	return enabled, nil
+}
+
+func lintRulesWithConfig(enabled map[string]bool) []lintRuleResponse {
+	rules := compiler.LintRules()
+	out := make([]lintRuleResponse, 0, len(rules))
+	for _, rule := range rules {
+		on, ok := enabled[rule.ID]
+		out = append(out, lintRuleResponse{LintRule: rule, Enabled: !ok || on})
+	}
+	return out
+}
+
+// LintProject runs the schema linter over the project's canvas, or over the
+// canvas in the request body when one is given
+func (h *ProjectHandler) LintProject(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	var req lintProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
+	if err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	raw := project.Data
+	if len(req.Data) > 0 {
+		raw = req.Data
+	}
+	data, err := loadCanvasJSON(raw)
+	if err != nil {
+		http.Error(w, "Invalid canvas data", http.StatusBadRequest)
+		return
+	}
+
+	enabled, err := h.projectLintConfig(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to load lint rules", http.StatusInternalServerError)
+		return
+	}
+
+	results := []compiler.LintResult{}
+	if len(data) > 0 {
+		schema, err := compiler.BuildSchema(data)
+		if err != nil {
+			http.Error(w, "Invalid canvas data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		results = compiler.Lint(schema, enabled)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lintResponse{
+		Results: results,
+		Rules:   lintRulesWithConfig(enabled),
+	})
+}
+
+func (h *ProjectHandler) GetLintRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	enabled, err := h.projectLintConfig(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to load lint rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lintRulesWithConfig(enabled))
+}
+
+// UpdateLintRules enables or disables lint rules for the project. Rules left
+// out of the request keep their current setting.
+func (h *ProjectHandler) UpdateLintRules(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	var req updateLintRulesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	for ruleID := range req.Rules {
+		if !compiler.IsLintRule(ruleID) {
+			http.Error(w, "Unknown lint rule: "+ruleID, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionEdit); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	for ruleID, on := range req.Rules {
+		if err := h.DB.UpsertProjectLintRule(r.Context(), database.UpsertProjectLintRuleParams{
+			ProjectID: projectID,
+			RuleID:    ruleID,
+			Enabled:   on,
+		}); err != nil {
+			http.Error(w, "Failed to update lint rules", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	enabled, err := h.projectLintConfig(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to load lint rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lintRulesWithConfig(enabled))
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// messagePresence is the y-websocket message type the hub uses for presence
+// frames. y-websocket providers don't know it, so it's only sent to sockets
+// that opt in with ?presence=1.
+const messagePresence = 4
+
+// presenceState is what a client shares about itself through awareness
+type presenceState struct {
+	cursor        json.RawMessage
+	selectedTable string
+	updatedAt     time.Time
+}
+
+// presenceEntry describes one user in a room. A user connected from several
+// tabs is listed once with the cursor of their most recently active tab.
+type presenceEntry struct {
+	UserID        uuid.UUID       `json:"user_id"`
+	Name          string          `json:"name"`
+	AvatarURL     *string         `json:"avatar_url"`
+	Role          Role            `json:"role"`
+	Cursor        json.RawMessage `json:"cursor,omitempty"`
+	SelectedTable string          `json:"selected_table,omitempty"`
+	Connections   int             `json:"connections"`
+
+	updatedAt time.Time
+}
+
+type presenceResponse struct {
+	ProjectID uuid.UUID       `json:"project_id"`
+	Users     []presenceEntry `json:"users"`
+}
+
+// awarenessFields are the parts of a frontend awareness state the hub reads
+type awarenessFields struct {
+	User *struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Cursor        json.RawMessage `json:"cursor"`
+	SelectedTable string          `json:"selectedTable"`
+}
+
+// updatePresence records the client's cursor and selection from an awareness
+// update and reports whether they changed. Only states claiming the client's
+// own user are taken, since clients also relay other peers' states.
+func (c *client) updatePresence(update []byte) bool {
+	states, err := yjs.DecodeAwarenessUpdate(update)
+	if err != nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := false
+	for _, s := range states {
+		if s.State == nil {
+			if c.awarenessIDs[s.ClientID] {
+				delete(c.awarenessIDs, s.ClientID)
+				changed = changed || c.presence.cursor != nil || c.presence.selectedTable != ""
+				c.presence = presenceState{updatedAt: time.Now()}
+			}
+			continue
+		}
+
+		var fields awarenessFields
+		if err := json.Unmarshal(s.State, &fields); err != nil || fields.User == nil || fields.User.ID != c.userID.String() {
+			continue
+		}
+		c.awarenessIDs[s.ClientID] = true
+		if fields.SelectedTable != c.presence.selectedTable || !bytes.Equal(fields.Cursor, c.presence.cursor) {
+			changed = true
+		}
+		c.presence = presenceState{
+			cursor:        fields.Cursor,
+			selectedTable: fields.SelectedTable,
+			updatedAt:     time.Now(),
+		}
+	}
+	return changed
+}
+
+// presence lists the users connected to the room on this instance
+func (room *Room) presence() []presenceEntry {
+	room.mu.RLock()
+	entries := make([]presenceEntry, 0, len(room.clients))
+	for c := range room.clients {
+		c.mu.Lock()
+		state := c.presence
+		c.mu.Unlock()
+
+		entries = append(entries, presenceEntry{
+			UserID:        c.userID,
+			Name:          c.name,
+			AvatarURL:     c.avatarURL,
+			Role:          c.role,
+			Cursor:        state.cursor,
+			SelectedTable: state.selectedTable,
+			Connections:   1,
+			updatedAt:     state.updatedAt,
+		})
+	}
+	room.mu.RUnlock()
+	return mergePresence(entries)
+}
+
+// mergePresence combines the entries of each user, adding up their
+// connections and keeping their most recent cursor
+func mergePresence(entries []presenceEntry) []presenceEntry {
+	byUser := make(map[uuid.UUID]*presenceEntry)
+	for _, e := range entries {
+		entry, ok := byUser[e.UserID]
+		if !ok {
+			e := e
+			byUser[e.UserID] = &e
+			continue
+		}
+		entry.Connections += e.Connections
+		if e.updatedAt.After(entry.updatedAt) {
+			entry.Cursor = e.Cursor
+			entry.SelectedTable = e.SelectedTable
+			entry.updatedAt = e.updatedAt
+		}
+	}
+
+	merged := make([]presenceEntry, 0, len(byUser))
+	for _, entry := range byUser {
+		merged = append(merged, *entry)
+	}
+	sortPresence(merged)
+	return merged
+}
+
+func sortPresence(entries []presenceEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Name != entries[j].Name {
+			return entries[i].Name < entries[j].Name
+		}
+		return entries[i].UserID.String() < entries[j].UserID.String()
+	})
+}
+
+const (
+	// presenceRefreshInterval is how often each hub re-announces its rooms'
+	// presence to the other instances
+	presenceRefreshInterval = 30 * time.Second
+	// presenceTTL is how long an instance's presence is kept without being
+	// refreshed, so users of an instance that went away drop out
+	presenceTTL = 3 * presenceRefreshInterval
+)
+
+// remoteRoomPresence is what another instance last announced for a room
+type remoteRoomPresence struct {
+	projectID uuid.UUID
+	users     []presenceEntry
+	expires   time.Time
+}
+
+// presenceAnnouncement is the payload of a fanoutPresence message
+type presenceAnnouncement struct {
+	ProjectID uuid.UUID        `json:"project_id"`
+	Users     []remotePresence `json:"users"`
+}
+
+// remotePresence carries an entry's activity time, which the API leaves out
+type remotePresence struct {
+	presenceEntry
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// broadcastPresence announces the room's presence on this instance to the
+// other hubs and sends the room's combined presence to its clients
+func (h *CollaborationHub) broadcastPresence(room *Room) {
+	h.announcePresence(room)
+	h.sendPresence(room)
+}
+
+// announcePresence publishes the room's presence on this instance. An empty
+// list tells the other hubs to forget this instance's users in the room.
+func (h *CollaborationHub) announcePresence(room *Room) {
+	entries := room.presence()
+	announcement := presenceAnnouncement{
+		ProjectID: room.projectID,
+		Users:     make([]remotePresence, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		announcement.Users = append(announcement.Users, remotePresence{
+			presenceEntry: entry,
+			UpdatedAt:     entry.updatedAt,
+		})
+	}
+	payload, err := json.Marshal(announcement)
+	if err != nil {
+		return
+	}
+	h.publish(fanoutEnvelope{Room: room.key, Kind: fanoutPresence, Data: payload})
+}
+
+// sendPresence sends the room's presence across every instance to each
+// client that asked for presence frames
+func (h *CollaborationHub) sendPresence(room *Room) {
+	payload, err := json.Marshal(presenceResponse{
+		ProjectID: room.projectID,
+		Users:     mergePresence(append(room.presence(), h.remoteRoomPresence(room.key)...)),
+	})
+	if err != nil {
+		return
+	}
+	message := yjs.EncodeMessage(messagePresence, payload)
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	for c := range room.clients {
+		if c.wantsPresence {
+			c.deliver(websocket.BinaryMessage, message)
+		}
+	}
+}
+
+// receivePresence stores another hub's presence for a room and passes the
+// change on to the room's clients here, if any
+func (h *CollaborationHub) receivePresence(env fanoutEnvelope) {
+	var announcement presenceAnnouncement
+	if err := json.Unmarshal(env.Data, &announcement); err != nil {
+		log.Printf("Dropping malformed presence from hub %s: %v", env.Origin, err)
+		return
+	}
+
+	h.presenceMu.Lock()
+	if len(announcement.Users) == 0 {
+		delete(h.remotePresence[env.Room], env.Origin)
+		if len(h.remotePresence[env.Room]) == 0 {
+			delete(h.remotePresence, env.Room)
+		}
+	} else {
+		users := make([]presenceEntry, 0, len(announcement.Users))
+		for _, user := range announcement.Users {
+			entry := user.presenceEntry
+			entry.updatedAt = user.UpdatedAt
+			users = append(users, entry)
+		}
+		if h.remotePresence[env.Room] == nil {
+			h.remotePresence[env.Room] = make(map[string]remoteRoomPresence)
+		}
+		h.remotePresence[env.Room][env.Origin] = remoteRoomPresence{
+			projectID: announcement.ProjectID,
+			users:     users,
+			expires:   time.Now().Add(presenceTTL),
+		}
+	}
+	h.presenceMu.Unlock()
+
+	h.mu.RLock()
+	room := h.rooms[env.Room]
+	h.mu.RUnlock()
+	if room != nil {
+		h.sendPresence(room)
+	}
+}
+
+// remoteRoomPresence lists the users the other instances have in a room
+func (h *CollaborationHub) remoteRoomPresence(roomKey string) []presenceEntry {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	var entries []presenceEntry
+	now := time.Now()
+	for _, remote := range h.remotePresence[roomKey] {
+		if now.Before(remote.expires) {
+			entries = append(entries, remote.users...)
+		}
+	}
+	return entries
+}
+
+// remoteProjectPresence lists the users the other instances have in any of
+// the project's rooms
+func (h *CollaborationHub) remoteProjectPresence(projectID uuid.UUID) []presenceEntry {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	var entries []presenceEntry
+	now := time.Now()
+	for _, instances := range h.remotePresence {
+		for _, remote := range instances {
+			if remote.projectID == projectID && now.Before(remote.expires) {
+				entries = append(entries, remote.users...)
+			}
+		}
+	}
+	return entries
+}
+
+// refreshPresence re-announces every room's presence until the hub shuts
+// down, and forgets instances that stopped announcing theirs
+func (h *CollaborationHub) refreshPresence() {
+	ticker := time.NewTicker(presenceRefreshInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		h.mu.RLock()
+		closing := h.closing
+		rooms := make([]*Room, 0, len(h.rooms))
+		for _, room := range h.rooms {
+			rooms = append(rooms, room)
+		}
+		h.mu.RUnlock()
+		if closing {
+			return
+		}
+
+		expired := h.expireRemotePresence()
+		for _, room := range rooms {
+			h.announcePresence(room)
+			if expired[room.key] {
+				h.sendPresence(room)
+			}
+		}
+	}
+}
+
+// expireRemotePresence drops presence that wasn't refreshed in time and
+// returns the keys of the rooms it changed
+func (h *CollaborationHub) expireRemotePresence() map[string]bool {
+	h.presenceMu.Lock()
+	defer h.presenceMu.Unlock()
+
+	expired := make(map[string]bool)
+	now := time.Now()
+	for roomKey, instances := range h.remotePresence {
+		for instanceID, remote := range instances {
+			if !now.Before(remote.expires) {
+				delete(instances, instanceID)
+				expired[roomKey] = true
+			}
+		}
+		if len(instances) == 0 {
+			delete(h.remotePresence, roomKey)
+		}
+	}
+	return expired
+}
+
+// ProjectPresence lists the users connected to any of the project's rooms,
+// on this instance or the others
+func (h *CollaborationHub) ProjectPresence(projectID uuid.UUID) []presenceEntry {
+	h.mu.RLock()
+	var rooms []*Room
+	for _, room := range h.rooms {
+		if room.projectID == projectID {
+			rooms = append(rooms, room)
+		}
+	}
+	h.mu.RUnlock()
+
+	entries := h.remoteProjectPresence(projectID)
+	for _, room := range rooms {
+		entries = append(entries, room.presence()...)
+	}
+	return mergePresence(entries)
+}
+
+// ActiveEditors counts, per project, the distinct users connected to any
+// instance that can edit it
+func (h *CollaborationHub) ActiveEditors() map[uuid.UUID]int {
+	editors := make(map[uuid.UUID]map[uuid.UUID]bool)
+	addEditor := func(projectID, userID uuid.UUID) {
+		if editors[projectID] == nil {
+			editors[projectID] = make(map[uuid.UUID]bool)
+		}
+		editors[projectID][userID] = true
+	}
+
+	h.mu.RLock()
+	for _, room := range h.rooms {
+		room.mu.RLock()
+		for c := range room.clients {
+			if !c.readOnly {
+				addEditor(room.projectID, c.userID)
+			}
+		}
+		room.mu.RUnlock()
+	}
+	h.mu.RUnlock()
+
+	h.presenceMu.Lock()
+	now := time.Now()
+	for _, instances := range h.remotePresence {
+		for _, remote := range instances {
+			if !now.Before(remote.expires) {
+				continue
+			}
+			for _, user := range remote.users {
+				if user.Role.Can(PermissionEdit) {
+					addEditor(remote.projectID, user.UserID)
+				}
+			}
+		}
+	}
+	h.presenceMu.Unlock()
+
+	counts := make(map[uuid.UUID]int, len(editors))
+	for projectID, users := range editors {
+		counts[projectID] = len(users)
+	}
+	return counts
+}
+
+func (h *ProjectHandler) GetProjectPresence(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	users := []presenceEntry{}
+	if h.Hub != nil {
+		users = h.Hub.ProjectPresence(projectID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(presenceResponse{
+		ProjectID: projectID,
+		Users:     users,
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/fanout"
+	"github.com/google/uuid"
+)
+
+// newPresenceClient builds a client without a connection. It never asks for
+// presence frames, so nothing but the initial sync is queued for it.
+func newPresenceClient(userID uuid.UUID, name string, role Role) *client {
+	return &client{
+		send:         make(chan outboundMessage, clientSendBuffer),
+		done:         make(chan struct{}),
+		readOnly:     !role.Can(PermissionEdit),
+		userID:       userID,
+		name:         name,
+		role:         role,
+		awarenessIDs: make(map[uint64]bool),
+	}
+}
+
+// join adds c to the hub's room the way HandleWebSocket does
+func join(t *testing.T, h *CollaborationHub, roomKey string, projectID uuid.UUID, c *client) *Room {
+	t.Helper()
+	room, err := h.joinRoom(roomKey, database.Project{ID: projectID}, c)
+	if err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	h.broadcastPresence(room)
+	return room
+}
+
+func leave(h *CollaborationHub, roomKey string, room *Room, c *client) {
+	h.leaveRoom(roomKey, room, c)
+	h.broadcastPresence(room)
+}
+
+// eventually polls cond until it holds or a second has passed
+func eventually(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func connections(entries []presenceEntry, userID uuid.UUID) int {
+	for _, entry := range entries {
+		if entry.UserID == userID {
+			return entry.Connections
+		}
+	}
+	return 0
+}
+
+func TestPresenceIsSharedAcrossHubs(t *testing.T) {
+	bus := fanout.NewMemory()
+	a := NewCollaborationHub(nil, nil, bus.Connect())
+	b := NewCollaborationHub(nil, nil, bus.Connect())
+
+	projectID := uuid.New()
+	roomKey := "room-" + projectID.String()
+	editor, viewer := uuid.New(), uuid.New()
+
+	editorOnA := newPresenceClient(editor, "Ada", RoleEditor)
+	roomA := join(t, a, roomKey, projectID, editorOnA)
+	viewerOnA := newPresenceClient(viewer, "Vic", RoleViewer)
+	join(t, a, roomKey, projectID, viewerOnA)
+
+	// b has no clients in the room, but still reports a's
+	eventually(t, "a's users on b", func() bool {
+		return len(b.ProjectPresence(projectID)) == 2
+	})
+	if got := b.ActiveEditors()[projectID]; got != 1 {
+		t.Errorf("b counts %d active editors, want 1", got)
+	}
+
+	// The same user connected to both hubs is listed once
+	editorOnB := newPresenceClient(editor, "Ada", RoleEditor)
+	roomB := join(t, b, roomKey, projectID, editorOnB)
+	for name, h := range map[string]*CollaborationHub{"a": a, "b": b} {
+		h := h
+		eventually(t, "editor connected twice on "+name, func() bool {
+			return connections(h.ProjectPresence(projectID), editor) == 2
+		})
+		if got := h.ActiveEditors()[projectID]; got != 1 {
+			t.Errorf("%s counts %d active editors, want 1", name, got)
+		}
+	}
+
+	// Once a's room empties, b only lists its own client
+	leave(a, roomKey, roomA, viewerOnA)
+	leave(a, roomKey, roomA, editorOnA)
+	eventually(t, "a's users dropped on b", func() bool {
+		entries := b.ProjectPresence(projectID)
+		return len(entries) == 1 && connections(entries, editor) == 1
+	})
+
+	leave(b, roomKey, roomB, editorOnB)
+	eventually(t, "b's users dropped on a", func() bool {
+		return len(a.ProjectPresence(projectID)) == 0 && a.ActiveEditors()[projectID] == 0
+	})
+}
+
+func TestRemotePresenceExpires(t *testing.T) {
+	h := NewCollaborationHub(nil, nil, nil)
+	projectID := uuid.New()
+	h.remotePresence["room"] = map[string]remoteRoomPresence{
+		"gone": {
+			projectID: projectID,
+			users:     []presenceEntry{{UserID: uuid.New(), Role: RoleEditor, Connections: 1}},
+			expires:   time.Now().Add(-time.Second),
+		},
+	}
+
+	if entries := h.ProjectPresence(projectID); len(entries) != 0 {
+		t.Fatalf("expired presence listed: %+v", entries)
+	}
+	if got := h.ActiveEditors()[projectID]; got != 0 {
+		t.Fatalf("expired presence counted as %d active editors", got)
+	}
+	if expired := h.expireRemotePresence(); !expired["room"] {
+		t.Fatal("expired room not reported")
+	}
+	if len(h.remotePresence) != 0 {
+		t.Fatal("expired presence kept")
+	}
+}
@@ -0,0 +1,282 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
+)
+
+// serverClientID is the Yjs client the server writes as: the seed of each
+// project's document and the edits REST saves make to it. Both happen under
+// the project's row lock against the stored document, so the client's clock
+// never forks.
+const serverClientID = 0
+
+// canvasProblemsError is returned when a room's canvas fails validation
+type canvasProblemsError []canvas.Problem
+
+func (e canvasProblemsError) Error() string {
+	return fmt.Sprintf("canvas has %d problems", len(e))
+}
+
+// canvasArrays returns the nodes and edges of a stored canvas as the values
+// of the document's root arrays
+func canvasArrays(data json.RawMessage) (map[string][]interface{}, error) {
+	arrays := map[string][]interface{}{"nodes": {}, "edges": {}}
+	if len(data) == 0 {
+		return arrays, nil
+	}
+
+	upgraded, _, err := canvas.Upgrade(data)
+	if err != nil {
+		return nil, err
+	}
+	var stored struct {
+		Nodes []interface{} `json:"nodes"`
+		Edges []interface{} `json:"edges"`
+	}
+	if err := json.Unmarshal(upgraded, &stored); err != nil {
+		return nil, err
+	}
+	if stored.Nodes != nil {
+		arrays["nodes"] = stored.Nodes
+	}
+	if stored.Edges != nil {
+		arrays["edges"] = stored.Edges
+	}
+	return arrays, nil
+}
+
+// canvasItemKey keys nodes and edges by their ID
+func canvasItemKey(v interface{}) (string, bool) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	id, ok := obj["id"].(string)
+	return id, ok && id != ""
+}
+
+// loadDocument decodes a stored document state
+func loadDocument(state []byte) (*yjs.Doc, error) {
+	doc := yjs.NewDoc()
+	if len(state) == 0 {
+		return doc, nil
+	}
+	if err := doc.ApplyUpdate(state); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// editDocument makes doc hold the canvas and returns the update that did
+// it, or nil if it already did
+func editDocument(doc *yjs.Doc, data json.RawMessage) ([]byte, error) {
+	arrays, err := canvasArrays(data)
+	if err != nil {
+		return nil, err
+	}
+	update, err := doc.EncodeArrayEdits(serverClientID, arrays, canvasItemKey)
+	if err != nil || update == nil {
+		return nil, err
+	}
+	if err := doc.ApplyUpdate(update); err != nil {
+		return nil, err
+	}
+	return update, nil
+}
+
+// seedDocument returns the state a project's first room starts from
+func seedDocument(data json.RawMessage) ([]byte, error) {
+	doc := yjs.NewDoc()
+	if _, err := editDocument(doc, data); err != nil {
+		return nil, err
+	}
+	return doc.EncodeStateAsUpdate(nil), nil
+}
+
+// documentCanvas materializes a document as a canvas. Only the first node
+// and edge of each ID is kept, as clients do. ok is false while neither
+// array has been written.
+func documentCanvas(doc *yjs.Doc) (data json.RawMessage, ok bool, err error) {
+	nodes, hasNodes := doc.Array("nodes")
+	edges, hasEdges := doc.Array("edges")
+	if !hasNodes && !hasEdges {
+		return nil, false, nil
+	}
+
+	data, err = json.Marshal(map[string]interface{}{
+		"version": canvas.CurrentVersion,
+		"nodes":   firstOfEachKey(nodes),
+		"edges":   firstOfEachKey(edges),
+	})
+	return data, err == nil, err
+}
+
+func firstOfEachKey(values []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(values))
+	seen := make(map[string]bool, len(values))
+	for _, v := range values {
+		if key, ok := canvasItemKey(v); ok {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		out = append(out, v)
+	}
+	return out
+}
+
+// mergeDocument merges a room's document into the stored one, which is nil
+// before the project's first save. It also returns what the stored document
+// holds that the room lacks, such as a REST save the room never heard of,
+// or nil.
+func mergeDocument(stored, room []byte) (*yjs.Doc, []byte, error) {
+	roomDoc, err := loadDocument(room)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, err := loadDocument(stored)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := merged.ApplyUpdate(room); err != nil {
+		return nil, nil, err
+	}
+
+	sv := roomDoc.StateVector()
+	missing := merged.EncodeStateAsUpdate(sv)
+	if bytes.Equal(missing, roomDoc.EncodeStateAsUpdate(sv)) {
+		return merged, nil, nil
+	}
+	return merged, missing, nil
+}
+
+// getProjectDocument returns the project's stored document state, or nil
+// if none has been stored yet
+func getProjectDocument(ctx context.Context, q *database.Queries, projectID uuid.UUID) ([]byte, error) {
+	state, err := q.GetProjectDocument(ctx, projectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return state, err
+}
+
+// openProjectDocument returns the state a new room of the project starts
+// from. The first room seeds it from the project's canvas and stores it,
+// under the project's row lock so that it only happens once.
+func openProjectDocument(ctx context.Context, conn *sql.DB, db *database.Queries, projectID uuid.UUID) ([]byte, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := db.WithTx(tx)
+
+	if _, err := q.LockProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	state, err := getProjectDocument(ctx, q, projectID)
+	if err != nil || state != nil {
+		return state, err
+	}
+
+	project, err := q.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	if state, err = seedDocument(project.Data); err != nil {
+		return nil, fmt.Errorf("seed document: %w", err)
+	}
+	if err := q.UpsertProjectDocument(ctx, database.UpsertProjectDocumentParams{
+		ProjectID: projectID,
+		State:     state,
+	}); err != nil {
+		return nil, err
+	}
+	return state, tx.Commit()
+}
+
+// saveRoomDocument merges a room's document into the stored one and saves
+// the canvas it holds as the project's data and newest version, all in one
+// transaction under the project's row lock. Canvases that fail validation
+// aren't saved. It returns what the room is missing from the stored
+// document, or nil.
+func saveRoomDocument(ctx context.Context, conn *sql.DB, db *database.Queries, projectID uuid.UUID, state []byte) ([]byte, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	q := db.WithTx(tx)
+
+	if _, err := q.LockProject(ctx, projectID); err != nil {
+		return nil, err
+	}
+	stored, err := getProjectDocument(ctx, q, projectID)
+	if err != nil {
+		return nil, err
+	}
+	merged, missing, err := mergeDocument(stored, state)
+	if err != nil {
+		return nil, fmt.Errorf("merge document: %w", err)
+	}
+
+	data, ok, err := documentCanvas(merged)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		data, problems, err := prepareCanvas(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(problems) > 0 {
+			return nil, canvasProblemsError(problems)
+		}
+		if _, err := saveCanvas(ctx, q, projectID, uuid.Nil, data, "Collaborative edits"); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := q.UpsertProjectDocument(ctx, database.UpsertProjectDocumentParams{
+		ProjectID: projectID,
+		State:     merged.EncodeStateAsUpdate(nil),
+	}); err != nil {
+		return nil, err
+	}
+	return missing, tx.Commit()
+}
+
+// editProjectDocument turns a canvas saved outside the collaboration rooms
+// into edits of the stored document, so rooms opened later continue the
+// same document. It returns the update for the project's open rooms, or nil.
+// The caller must hold the project's row lock.
+func editProjectDocument(ctx context.Context, q *database.Queries, projectID uuid.UUID, data json.RawMessage) ([]byte, error) {
+	stored, err := getProjectDocument(ctx, q, projectID)
+	if err != nil || stored == nil {
+		// The first room seeds its document from the saved canvas
+		return nil, err
+	}
+	doc, err := loadDocument(stored)
+	if err != nil {
+		return nil, err
+	}
+	update, err := editDocument(doc, data)
+	if err != nil || update == nil {
+		return nil, err
+	}
+	return update, q.UpsertProjectDocument(ctx, database.UpsertProjectDocumentParams{
+		ProjectID: projectID,
+		State:     doc.EncodeStateAsUpdate(nil),
+	})
+}
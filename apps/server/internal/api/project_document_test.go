@@ -0,0 +1,265 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+)
+
+const testCanvas = `{
+	"version": 2,
+	"nodes": [
+		{"id": "users", "type": "tableNode", "position": {"x": 0, "y": 0}, "data": {"name": "users", "columns": [{"id": "users-id", "name": "id", "type": "uuid", "isPrimaryKey": true, "constraints": []}]}},
+		{"id": "posts", "type": "tableNode", "position": {"x": 300, "y": 0}, "data": {"name": "posts", "columns": [{"id": "posts-id", "name": "id", "type": "uuid", "isPrimaryKey": true, "constraints": []}, {"id": "posts-author", "name": "author_id", "type": "uuid", "isPrimaryKey": false, "constraints": ["FK"]}]}}
+	],
+	"edges": [
+		{"id": "posts-author-users", "source": "users", "target": "posts", "sourceHandle": "users-id-source", "targetHandle": "posts-author-target"}
+	]
+}`
+
+// testClientID is the Yjs client of the browser in these tests
+const testClientID = 7
+
+// syncClient exchanges sync steps 1 and 2 between a room and a client's
+// copy of the document, as a client does on every connect
+func syncClient(t *testing.T, room *roomDocument, clientDoc *yjs.Doc) {
+	t.Helper()
+	fromClient := clientDoc.EncodeStateAsUpdate(room.doc.StateVector())
+	if err := room.apply(fromClient); err != nil {
+		t.Fatalf("room apply: %v", err)
+	}
+	fromRoom, err := room.diff(clientDoc.EncodeStateVector())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := clientDoc.ApplyUpdate(fromRoom); err != nil {
+		t.Fatalf("client apply: %v", err)
+	}
+}
+
+// clientEdit makes the client's copy hold the canvas, as its store does, and
+// sends the edit to the room
+func clientEdit(t *testing.T, room *roomDocument, clientDoc *yjs.Doc, data string) {
+	t.Helper()
+	arrays, err := canvasArrays(json.RawMessage(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := clientDoc.EncodeArrayEdits(testClientID, arrays, canvasItemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update == nil {
+		return
+	}
+	if err := clientDoc.ApplyUpdate(update); err != nil {
+		t.Fatal(err)
+	}
+	if err := room.apply(update); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// flush merges the room into the stored state as flushRoom does, and
+// returns the new stored state
+func flush(t *testing.T, stored []byte, room *roomDocument) []byte {
+	t.Helper()
+	state, ok := room.takeState()
+	if !ok {
+		return stored
+	}
+	merged, missing, err := mergeDocument(stored, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != nil {
+		if err := room.merge(missing); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return merged.EncodeStateAsUpdate(nil)
+}
+
+func decodeDocument(t *testing.T, doc *yjs.Doc) *canvas.Canvas {
+	t.Helper()
+	data, ok, err := documentCanvas(doc)
+	if err != nil || !ok {
+		t.Fatalf("documentCanvas: ok=%v err=%v", ok, err)
+	}
+	c, err := canvas.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// assertNoDuplicates checks that every node and edge appears once in the
+// document itself, not just in its materialized canvas
+func assertNoDuplicates(t *testing.T, doc *yjs.Doc, nodes, edges int) {
+	t.Helper()
+	for name, want := range map[string]int{"nodes": nodes, "edges": edges} {
+		values, _ := doc.Array(name)
+		if len(values) != want {
+			t.Errorf("%s has %d elements, want %d: %v", name, len(values), want, values)
+		}
+	}
+}
+
+// nodeX returns the x position of a node. Edited items move to the end of
+// their array, so nodes are looked up by ID.
+func nodeX(t *testing.T, c *canvas.Canvas, nodeID string) float64 {
+	t.Helper()
+	for _, node := range c.Nodes {
+		if node.ID == nodeID {
+			return node.Position.X
+		}
+	}
+	t.Fatalf("node %s is missing", nodeID)
+	return 0
+}
+
+func movedCanvas(t *testing.T, nodeID string, x float64) string {
+	t.Helper()
+	c, err := canvas.Decode([]byte(testCanvas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range c.Nodes {
+		if c.Nodes[i].ID == nodeID {
+			c.Nodes[i].Position.X = x
+		}
+	}
+	data, err := canvas.Encode(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestReconnectAfterFlushKeepsOneCopyOfEachItem(t *testing.T) {
+	stored, err := seedDocument(json.RawMessage(testCanvas))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	room, err := loadRoomDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDoc := yjs.NewDoc()
+	syncClient(t, room, clientDoc)
+	assertNoDuplicates(t, clientDoc, 2, 1)
+
+	clientEdit(t, room, clientDoc, movedCanvas(t, "users", 120))
+	stored = flush(t, stored, room)
+
+	// The room empties and is reopened from what was stored, and the client
+	// reconnects with its own copy
+	reopened, err := loadRoomDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncClient(t, reopened, clientDoc)
+
+	for name, doc := range map[string]*yjs.Doc{"room": reopened.doc, "client": clientDoc} {
+		t.Run(name, func(t *testing.T) {
+			assertNoDuplicates(t, doc, 2, 1)
+			if x := nodeX(t, decodeDocument(t, doc), "users"); x != 120 {
+				t.Errorf("users is at x=%v, want 120", x)
+			}
+		})
+	}
+
+	// Reopening again without edits changes nothing
+	if again := flush(t, stored, reopened); len(again) != len(stored) {
+		t.Errorf("stored state grew from %d to %d bytes without edits", len(stored), len(again))
+	}
+}
+
+func TestRESTSaveReachesStaleClientOnce(t *testing.T) {
+	stored, err := seedDocument(json.RawMessage(testCanvas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err := loadRoomDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDoc := yjs.NewDoc()
+	syncClient(t, room, clientDoc)
+	stored = flush(t, stored, room)
+
+	// A REST save moves posts while the client is offline
+	storedDoc, err := loadDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update, err := editDocument(storedDoc, json.RawMessage(movedCanvas(t, "posts", 640)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update == nil {
+		t.Fatal("editDocument returned no update for a changed canvas")
+	}
+	stored = storedDoc.EncodeStateAsUpdate(nil)
+
+	reopened, err := loadRoomDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	syncClient(t, reopened, clientDoc)
+
+	assertNoDuplicates(t, clientDoc, 2, 1)
+	if x := nodeX(t, decodeDocument(t, clientDoc), "posts"); x != 640 {
+		t.Errorf("posts is at x=%v, want 640", x)
+	}
+}
+
+func TestMergeDocumentReturnsWhatTheRoomMissed(t *testing.T) {
+	stored, err := seedDocument(json.RawMessage(testCanvas))
+	if err != nil {
+		t.Fatal(err)
+	}
+	room, err := loadRoomDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientDoc := yjs.NewDoc()
+	syncClient(t, room, clientDoc)
+
+	// A REST save lands while the room is open and the room misses its
+	// broadcast; the client edits a different node meanwhile
+	storedDoc, err := loadDocument(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := editDocument(storedDoc, json.RawMessage(movedCanvas(t, "posts", 640))); err != nil {
+		t.Fatal(err)
+	}
+	stored = storedDoc.EncodeStateAsUpdate(nil)
+	clientEdit(t, room, clientDoc, movedCanvas(t, "users", 120))
+
+	state, _ := room.takeState()
+	merged, missing, err := mergeDocument(stored, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing == nil {
+		t.Fatal("mergeDocument didn't return the REST save")
+	}
+	if err := room.merge(missing); err != nil {
+		t.Fatal(err)
+	}
+
+	assertNoDuplicates(t, merged, 2, 1)
+	assertNoDuplicates(t, room.doc, 2, 1)
+	c := decodeDocument(t, room.doc)
+	if users, posts := nodeX(t, c, "users"), nodeX(t, c, "posts"); users != 120 || posts != 640 {
+		t.Errorf("users is at x=%v and posts at x=%v, want 120 and 640", users, posts)
+	}
+	if _, ok := room.takeState(); ok {
+		t.Error("merging the stored document made the room dirty")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -17,20 +18,26 @@ import (
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/ai"
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/auth"
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/cache"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/compiler"
 	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
 	"github.com/google/uuid"
 )
 
 type ProjectHandler struct {
+	// Conn is the connection pool behind DB, for work that needs a transaction
+	Conn  *sql.DB
 	DB    *database.Queries
 	AI    *ai.AIService
 	Cache *cache.Cache
+	// Hub reports who is connected to each project; it may be nil
+	Hub *CollaborationHub
 }
 
-func NewProjectHandler(db *database.Queries) *ProjectHandler {
+func NewProjectHandler(conn *sql.DB, db *database.Queries) *ProjectHandler {
 	aiService, _ := ai.NewAIService()
 	return &ProjectHandler{
+		Conn:  conn,
 		DB:    db,
 		AI:    aiService,
 		Cache: cache.GetGlobal(),
@@ -92,6 +99,13 @@ func (h *ProjectHandler) CreateProject(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(project)
 }
 
+// projectListItem is a project in the dashboard listing along with the number
+// of people currently editing it
+type projectListItem struct {
+	database.GetProjectsByUserRow
+	ActiveEditors int `json:"active_editors"`
+}
+
 func (h *ProjectHandler) GetMyProjects(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authorize(r)
 	if err != nil {
@@ -105,13 +119,22 @@ func (h *ProjectHandler) GetMyProjects(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var activeEditors map[uuid.UUID]int
+	if h.Hub != nil {
+		activeEditors = h.Hub.ActiveEditors()
+	}
+
 	// Ensure we return an empty array instead of null for new users
-	if projects == nil {
-		projects = []database.GetProjectsByUserRow{}
+	items := make([]projectListItem, 0, len(projects))
+	for _, project := range projects {
+		items = append(items, projectListItem{
+			GetProjectsByUserRow: project,
+			ActiveEditors:        activeEditors[project.ID],
+		})
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(projects)
+	json.NewEncoder(w).Encode(items)
 }
 
 func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
@@ -133,40 +156,25 @@ func (h *ProjectHandler) GetProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	project, err := h.getProjectForUser(r.Context(), projectID, userID)
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
 	if err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
 
+	if data, err := loadCanvasJSON(project.Data); err != nil {
+		log.Printf("Failed to upgrade canvas of project %s: %v", projectID, err)
+	} else if len(data) > 0 {
+		project.Data = data
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(project)
 }
 
 type UpdateProjectRequest struct {
-	Data json.RawMessage `json:"data"`
-}
-
-type shareLinkResponse struct {
-	ProjectID uuid.UUID  `json:"project_id"`
-	Token     string     `json:"token"`
-	RoomKey   string     `json:"room_key"`
-	CreatedAt time.Time  `json:"created_at"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedBy uuid.UUID  `json:"created_by"`
-}
-
-type createShareLinkRequest struct {
-	ExpiresInHours *int `json:"expiresInHours"`
-}
-
-type joinShareLinkResponse struct {
-	ProjectID   uuid.UUID  `json:"project_id"`
-	ProjectName string     `json:"project_name"`
-	RoomKey     string     `json:"room_key"`
-	Token       string     `json:"token"`
-	OwnerID     uuid.UUID  `json:"owner_id"`
-	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	Data    json.RawMessage `json:"data"`
+	Message string          `json:"message"`
 }
 
 var errProjectAccessDenied = errors.New("project access denied")
@@ -202,16 +210,26 @@ func (h *ProjectHandler) UpdateProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.getProjectForUser(r.Context(), projectID, userID); err != nil {
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionEdit); err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
 
-	project, err := h.DB.UpdateProjectData(r.Context(), database.UpdateProjectDataParams{
-		ID:   projectID,
-		Data: cleanData,
-	})
+	if len(cleanData) > 0 {
+		var problems []canvas.Problem
+		if cleanData, problems, err = prepareCanvas(cleanData); err != nil {
+			http.Error(w, "Invalid canvas data: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(problems) > 0 {
+			writeCanvasProblems(w, problems)
+			return
+		}
+	}
+
+	project, err := h.saveProjectData(r.Context(), projectID, userID, cleanData, req.Message)
 	if err != nil {
+		log.Printf("Failed to save project %s: %v", projectID, err)
 		http.Error(w, "Failed to update project", http.StatusInternalServerError)
 		return
 	}
@@ -237,17 +255,11 @@ func (h *ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	project, err := h.getProjectForUser(r.Context(), projectID, userID)
-	if err != nil {
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionDelete); err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
 
-	if project.UserID != userID {
-		http.Error(w, "Only project owners can delete projects", http.StatusForbidden)
-		return
-	}
-
 	if err := h.DB.DeleteProject(r.Context(), database.DeleteProjectParams{
 		ID:     projectID,
 		UserID: userID,
@@ -260,6 +272,40 @@ func (h *ProjectHandler) DeleteProject(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *ProjectHandler) ExportProjectSQL(w http.ResponseWriter, r *http.Request) {
+	// Non-Postgres dialects are resolved through the compiler registry and are
+	// always generated deterministically (the AI exporter only speaks PostgreSQL)
+	var dialect compiler.Dialect = compiler.PostgresDialect{}
+	if name := r.URL.Query().Get("dialect"); name != "" {
+		var ok bool
+		if dialect, ok = compiler.LookupDialect(name); !ok {
+			http.Error(w, fmt.Sprintf("Unsupported dialect %q (supported: %s)", name, strings.Join(compiler.DialectNames(), ", ")), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// ?fk=inline declares foreign keys inside CREATE TABLE, which the AI
+	// exporter doesn't honor either
+	var opts compiler.DDLOptions
+	switch fk := r.URL.Query().Get("fk"); fk {
+	case "", "alter":
+	case "inline":
+		opts.InlineForeignKeys = true
+	default:
+		http.Error(w, fmt.Sprintf("Unsupported fk mode %q (supported: alter, inline)", fk), http.StatusBadRequest)
+		return
+	}
+
+	if dialect.Name() != (compiler.PostgresDialect{}).Name() || opts.InlineForeignKeys {
+		format := dialect.Name()
+		if opts.InlineForeignKeys {
+			format += "-inline"
+		}
+		h.exportDeterministic(w, r, format, dialect.Name(), func(data []byte) (string, error) {
+			return compiler.GenerateDialectSQLWithOptions(data, dialect, opts)
+		})
+		return
+	}
+
 	userID, err := h.authorize(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -269,13 +315,13 @@ func (h *ProjectHandler) ExportProjectSQL(w http.ResponseWriter, r *http.Request
 	projectIDStr := r.PathValue("id")
 	projectID, _ := uuid.Parse(projectIDStr)
 
-	project, err := h.getProjectForUser(r.Context(), projectID, userID)
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
 	if err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
 
-	dataBytes, err := normalizeCanvasJSON(project.Data)
+	dataBytes, err := loadCanvasJSON(project.Data)
 	if err != nil {
 		http.Error(w, "Failed to parse project data: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -337,13 +383,13 @@ func (h *ProjectHandler) ExportProjectPrisma(w http.ResponseWriter, r *http.Requ
 	projectIDStr := r.PathValue("id")
 	projectID, _ := uuid.Parse(projectIDStr)
 
-	project, err := h.getProjectForUser(r.Context(), projectID, userID)
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
 	if err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
 
-	dataBytes, err := normalizeCanvasJSON(project.Data)
+	dataBytes, err := loadCanvasJSON(project.Data)
 	if err != nil {
 		http.Error(w, "Failed to parse project data: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -395,6 +441,71 @@ func (h *ProjectHandler) ExportProjectPrisma(w http.ResponseWriter, r *http.Requ
 	w.Write([]byte(prismaSchema))
 }
 
+func (h *ProjectHandler) ExportProjectMySQL(w http.ResponseWriter, r *http.Request) {
+	// The AI exporter only speaks PostgreSQL, so MySQL is always deterministic
+	h.exportDeterministic(w, r, "mysql", "MySQL", compiler.GenerateMySQL)
+}
+
+func (h *ProjectHandler) ExportProjectSQLite(w http.ResponseWriter, r *http.Request) {
+	h.exportDeterministic(w, r, "sqlite", "SQLite", compiler.GenerateSQLite)
+}
+
+// exportDeterministic serves a cached, non-AI export of the project's canvas
+// produced by generate.
+func (h *ProjectHandler) exportDeterministic(w http.ResponseWriter, r *http.Request, format, label string, generate func([]byte) (string, error)) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectIDStr := r.PathValue("id")
+	projectID, _ := uuid.Parse(projectIDStr)
+
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
+	if err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	dataBytes, err := loadCanvasJSON(project.Data)
+	if err != nil {
+		http.Error(w, "Failed to parse project data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(dataBytes) == 0 {
+		http.Error(w, "Project has no canvas data", http.StatusBadRequest)
+		return
+	}
+
+	// Generate cache key based on project ID, format, and data hash
+	dataHash := hashCanvasData(dataBytes)
+	cacheKey := generateCacheKey(projectID, format, dataHash)
+
+	// Check cache first
+	if cached, found := h.Cache.Get(cacheKey); found {
+		if script, ok := cached.(string); ok {
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("X-Cache", "HIT")
+			w.Write([]byte(script))
+			return
+		}
+	}
+
+	script, err := generate(dataBytes)
+	if err != nil {
+		http.Error(w, "Failed to generate "+label+": "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Cache the result for 24 hours
+	h.Cache.Set(cacheKey, script, 24*time.Hour)
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Header().Set("X-Cache", "MISS")
+	w.Write([]byte(script))
+}
+
 type AIGenerateTablesRequest struct {
 	Prompt string `json:"prompt"`
 }
@@ -419,7 +530,7 @@ func (h *ProjectHandler) AIGenerateTables(w http.ResponseWriter, r *http.Request
 	}
 
 	// Verify user has access to project
-	if _, err := h.getProjectForUser(r.Context(), projectID, userID); err != nil {
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionEdit); err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
@@ -470,7 +581,7 @@ func (h *ProjectHandler) ImportSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.getProjectForUser(r.Context(), projectID, userID); err != nil {
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionEdit); err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
@@ -502,12 +613,21 @@ func (h *ProjectHandler) ImportSQL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Imported canvases are held to the same rules as saved ones
+	canvasData, problems, err := prepareCanvas(canvasData)
+	if err != nil {
+		http.Error(w, "Failed to import SQL: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(problems) > 0 {
+		writeCanvasProblems(w, problems)
+		return
+	}
+
 	// Update project with imported canvas data
-	updatedProject, err := h.DB.UpdateProjectData(r.Context(), database.UpdateProjectDataParams{
-		ID:   projectID,
-		Data: canvasData,
-	})
+	updatedProject, err := h.saveProjectData(r.Context(), projectID, userID, canvasData, "Imported from SQL")
 	if err != nil {
+		log.Printf("Failed to save project %s: %v", projectID, err)
 		http.Error(w, "Failed to update project", http.StatusInternalServerError)
 		return
 	}
@@ -519,7 +639,22 @@ func (h *ProjectHandler) ImportSQL(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(updatedProject)
 }
 
-func (h *ProjectHandler) GetShareLink(w http.ResponseWriter, r *http.Request) {
+type ProjectCollaboratorResponse struct {
+	ID        string    `json:"id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	AvatarUrl *string   `json:"avatar_url"`
+	Provider  string    `json:"provider"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (h *ProjectHandler) GetProjectCollaborators(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
 	userID, err := h.authorize(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
@@ -533,229 +668,199 @@ func (h *ProjectHandler) GetShareLink(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if _, err := h.getProjectForUser(r.Context(), projectID, userID); err != nil {
+	// Verify user has access to this project
+	_, err = h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
+	if err != nil {
 		writeProjectAccessError(w, err)
 		return
 	}
 
-	link, err := h.DB.GetActiveShareLinkForProject(r.Context(), projectID)
+	// Get all collaborators using sqlc generated query
+	collabRows, err := h.DB.GetProjectCollaborators(r.Context(), projectID)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "No active share link", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to load share link", http.StatusInternalServerError)
+		http.Error(w, "Failed to fetch collaborators", http.StatusInternalServerError)
 		return
 	}
 
+	collaborators := make([]ProjectCollaboratorResponse, 0, len(collabRows))
+	for _, row := range collabRows {
+		var avatarUrl *string
+		if row.AvatarUrl.Valid {
+			avatarUrl = &row.AvatarUrl.String
+		}
+		collaborators = append(collaborators, ProjectCollaboratorResponse{
+			ID:        row.ID.String(),
+			Email:     row.Email,
+			Name:      row.Name,
+			AvatarUrl: avatarUrl,
+			Provider:  row.Provider,
+			Role:      row.Role,
+			CreatedAt: row.CreatedAt,
+		})
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(makeShareLinkResponse(link))
+	json.NewEncoder(w).Encode(collaborators)
 }
 
-func (h *ProjectHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+type updateCollaboratorRoleRequest struct {
+	Role string `json:"role"`
+}
 
+// UpdateCollaboratorRole changes a collaborator's role. Owners and admins can
+// manage roles, but only the owner can grant the admin role or change an
+// existing admin.
+func (h *ProjectHandler) UpdateCollaboratorRole(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authorize(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	projectIDStr := r.PathValue("id")
-	projectID, err := uuid.Parse(projectIDStr)
+	projectID, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid project ID", http.StatusBadRequest)
 		return
 	}
-
-	project, err := h.getProjectForUser(r.Context(), projectID, userID)
+	targetID, err := uuid.Parse(r.PathValue("userId"))
 	if err != nil {
-		writeProjectAccessError(w, err)
-		return
-	}
-	if project.UserID != userID {
-		http.Error(w, "Only the project owner can create share links", http.StatusForbidden)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	var req createShareLinkRequest
-	if r.Body != nil {
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
-			http.Error(w, "Invalid request body", http.StatusBadRequest)
-			return
-		}
-	}
-
-	var expires sql.NullTime
-	if req.ExpiresInHours != nil && *req.ExpiresInHours > 0 {
-		expires = sql.NullTime{
-			Time:  time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour),
-			Valid: true,
-		}
-	}
-
-	if err := h.DB.RevokeShareLinksForProject(r.Context(), projectID); err != nil {
-		http.Error(w, "Failed to reset previous share links", http.StatusInternalServerError)
+	var req updateCollaboratorRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	token := generateCollaborationToken()
-	roomKey := generateCollaborationToken()
-
-	link, err := h.DB.CreateProjectShareLink(r.Context(), database.CreateProjectShareLinkParams{
-		ProjectID: projectID,
-		Token:     token,
-		RoomKey:   roomKey,
-		CreatedBy: userID,
-		ExpiresAt: expires,
-	})
-	if err != nil {
-		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+	newRole := Role(req.Role)
+	if !isAssignableRole(newRole) {
+		http.Error(w, "role must be one of admin, editor, commenter, viewer", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(makeShareLinkResponse(link))
-}
-
-func (h *ProjectHandler) JoinShareLink(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	project, role, err := h.getProjectWithRole(r.Context(), projectID, userID)
+	if err != nil {
+		writeProjectAccessError(w, err)
 		return
 	}
-
-	userID, err := h.authorize(r)
-	if err != nil {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !role.Can(PermissionManage) {
+		writeProjectAccessError(w, errInsufficientRole)
 		return
 	}
-
-	token := r.PathValue("token")
-	if token == "" {
-		http.Error(w, "Share token is required", http.StatusBadRequest)
+	if targetID == project.UserID {
+		http.Error(w, "The project owner's role cannot be changed", http.StatusBadRequest)
 		return
 	}
 
-	link, err := h.DB.GetShareLinkByToken(r.Context(), token)
+	target, err := h.DB.GetProjectCollaborator(r.Context(), database.GetProjectCollaboratorParams{
+		ProjectID: projectID,
+		UserID:    targetID,
+	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Share link not found", http.StatusNotFound)
+			http.Error(w, "Collaborator not found", http.StatusNotFound)
 			return
 		}
-		http.Error(w, "Failed to load share link", http.StatusInternalServerError)
+		http.Error(w, "Failed to load collaborator", http.StatusInternalServerError)
 		return
 	}
-
-	if link.ExpiresAt.Valid && time.Now().After(link.ExpiresAt.Time) {
-		_ = h.DB.RevokeShareLinksForProject(r.Context(), link.ProjectID)
-		http.Error(w, "Share link expired", http.StatusGone)
+	if role != RoleOwner && (newRole == RoleAdmin || parseRole(target.Role) == RoleAdmin) {
+		http.Error(w, "Only the project owner can manage admins", http.StatusForbidden)
 		return
 	}
 
-	project, err := h.DB.GetProjectByID(r.Context(), link.ProjectID)
+	updated, err := h.DB.UpdateProjectCollaboratorRole(r.Context(), database.UpdateProjectCollaboratorRoleParams{
+		ProjectID: projectID,
+		UserID:    targetID,
+		Role:      string(newRole),
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Project not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to load project", http.StatusInternalServerError)
+		http.Error(w, "Failed to update collaborator", http.StatusInternalServerError)
 		return
 	}
-
-	if project.UserID != userID {
-		if err := h.DB.UpsertProjectCollaborator(r.Context(), database.UpsertProjectCollaboratorParams{
-			ProjectID: project.ID,
-			UserID:    userID,
-			Role:      "editor",
-		}); err != nil {
-			http.Error(w, "Failed to add collaborator", http.StatusInternalServerError)
-			return
-		}
-	}
-
-	resp := joinShareLinkResponse{
-		ProjectID:   project.ID,
-		ProjectName: project.Name,
-		RoomKey:     link.RoomKey,
-		Token:       link.Token,
-		OwnerID:     project.UserID,
-	}
-	if link.ExpiresAt.Valid {
-		resp.ExpiresAt = &link.ExpiresAt.Time
+	if updated == 0 {
+		http.Error(w, "Collaborator not found", http.StatusNotFound)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
-}
-
-type ProjectCollaboratorResponse struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	Name      string    `json:"name"`
-	AvatarUrl *string   `json:"avatar_url"`
-	Provider  string    `json:"provider"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *ProjectHandler) GetProjectCollaborators(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
+// RemoveCollaborator revokes a collaborator's access. Members can always
+// remove themselves; removing anyone else needs the manage permission, and
+// only the owner can remove an admin.
+func (h *ProjectHandler) RemoveCollaborator(w http.ResponseWriter, r *http.Request) {
 	userID, err := h.authorize(r)
 	if err != nil {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	projectIDStr := r.PathValue("id")
-	projectID, err := uuid.Parse(projectIDStr)
+	projectID, err := uuid.Parse(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid project ID", http.StatusBadRequest)
 		return
 	}
-
-	// Verify user has access to this project
-	_, err = h.getProjectForUser(r.Context(), projectID, userID)
+	targetID, err := uuid.Parse(r.PathValue("userId"))
 	if err != nil {
-		writeProjectAccessError(w, err)
+		http.Error(w, "Invalid user ID", http.StatusBadRequest)
 		return
 	}
 
-	// Get all collaborators using sqlc generated query
-	collabRows, err := h.DB.GetProjectCollaborators(r.Context(), projectID)
+	project, role, err := h.getProjectWithRole(r.Context(), projectID, userID)
 	if err != nil {
-		http.Error(w, "Failed to fetch collaborators", http.StatusInternalServerError)
+		writeProjectAccessError(w, err)
+		return
+	}
+	if targetID == project.UserID {
+		http.Error(w, "The project owner cannot be removed", http.StatusBadRequest)
 		return
 	}
 
-	collaborators := make([]ProjectCollaboratorResponse, 0, len(collabRows))
-	for _, row := range collabRows {
-		var avatarUrl *string
-		if row.AvatarUrl.Valid {
-			avatarUrl = &row.AvatarUrl.String
+	if targetID != userID {
+		if !role.Can(PermissionManage) {
+			writeProjectAccessError(w, errInsufficientRole)
+			return
+		}
+		if role != RoleOwner {
+			target, err := h.DB.GetProjectCollaborator(r.Context(), database.GetProjectCollaboratorParams{
+				ProjectID: projectID,
+				UserID:    targetID,
+			})
+			if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Failed to load collaborator", http.StatusInternalServerError)
+				return
+			}
+			if err == nil && parseRole(target.Role) == RoleAdmin {
+				http.Error(w, "Only the project owner can manage admins", http.StatusForbidden)
+				return
+			}
 		}
-		collaborators = append(collaborators, ProjectCollaboratorResponse{
-			ID:        row.ID.String(),
-			Email:     row.Email,
-			Name:      row.Name,
-			AvatarUrl: avatarUrl,
-			Provider:  row.Provider,
-			Role:      row.Role,
-			CreatedAt: row.CreatedAt,
-		})
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(collaborators)
+	removed, err := h.DB.DeleteProjectCollaborator(r.Context(), database.DeleteProjectCollaboratorParams{
+		ProjectID: projectID,
+		UserID:    targetID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to remove collaborator", http.StatusInternalServerError)
+		return
+	}
+	if removed == 0 {
+		http.Error(w, "Collaborator not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (h *ProjectHandler) authorize(r *http.Request) (uuid.UUID, error) {
+	return userIDFromRequest(r)
+}
+
+// userIDFromRequest validates the auth_token cookie and returns its user
+func userIDFromRequest(r *http.Request) (uuid.UUID, error) {
 	cookie, err := r.Cookie("auth_token")
 	if err != nil {
 		return uuid.Nil, err
@@ -767,33 +872,88 @@ func (h *ProjectHandler) authorize(r *http.Request) (uuid.UUID, error) {
 	return uuid.Parse(userIDStr)
 }
 
-func (h *ProjectHandler) getProjectForUser(ctx context.Context, projectID, userID uuid.UUID) (database.Project, error) {
-	project, err := h.DB.GetProjectByID(ctx, projectID)
+// getProjectForUser loads a project and checks that the user's role on it
+// grants perm. Non-members get errProjectAccessDenied and members whose role
+// falls short get errInsufficientRole.
+func (h *ProjectHandler) getProjectForUser(ctx context.Context, projectID, userID uuid.UUID, perm Permission) (database.Project, error) {
+	project, role, err := h.getProjectWithRole(ctx, projectID, userID)
 	if err != nil {
 		return database.Project{}, err
 	}
+	if !role.Can(perm) {
+		return database.Project{}, errInsufficientRole
+	}
+	return project, nil
+}
+
+// getProjectWithRole loads a project along with the user's role on it
+func (h *ProjectHandler) getProjectWithRole(ctx context.Context, projectID, userID uuid.UUID) (database.Project, Role, error) {
+	return loadProjectRole(ctx, h.DB, projectID, userID)
+}
+
+// loadProjectRole loads a project along with the user's role on it, returning
+// errProjectAccessDenied when the user is neither owner nor collaborator
+func loadProjectRole(ctx context.Context, db *database.Queries, projectID, userID uuid.UUID) (database.Project, Role, error) {
+	project, err := db.GetProjectByID(ctx, projectID)
+	if err != nil {
+		return database.Project{}, "", err
+	}
 
 	if project.UserID == userID {
-		return project, nil
+		return project, RoleOwner, nil
 	}
 
-	_, err = h.DB.GetProjectCollaborator(ctx, database.GetProjectCollaboratorParams{
+	collaborator, err := db.GetProjectCollaborator(ctx, database.GetProjectCollaboratorParams{
 		ProjectID: projectID,
 		UserID:    userID,
 	})
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return database.Project{}, errProjectAccessDenied
+			return database.Project{}, "", errProjectAccessDenied
 		}
-		return database.Project{}, err
+		return database.Project{}, "", err
 	}
-	return project, nil
+	return project, parseRole(collaborator.Role), nil
+}
+
+// canvasProblemsResponse lists why a canvas was rejected
+type canvasProblemsResponse struct {
+	Error    string           `json:"error"`
+	Problems []canvas.Problem `json:"problems"`
+}
+
+// prepareCanvas validates a canvas about to be saved and stamps it with the
+// current version. Problems are returned when it can't be saved as is.
+func prepareCanvas(data json.RawMessage) (json.RawMessage, []canvas.Problem, error) {
+	graph, err := canvas.Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if problems := canvas.Validate(graph); len(problems) > 0 {
+		return nil, problems, nil
+	}
+	stamped, err := canvas.Stamp(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stamped, nil, nil
+}
+
+func writeCanvasProblems(w http.ResponseWriter, problems []canvas.Problem) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(canvasProblemsResponse{
+		Error:    "Canvas has problems that must be fixed before saving",
+		Problems: problems,
+	})
 }
 
 func writeProjectAccessError(w http.ResponseWriter, err error) {
 	switch {
 	case errors.Is(err, errProjectAccessDenied):
 		http.Error(w, "Forbidden", http.StatusForbidden)
+	case errors.Is(err, errInsufficientRole):
+		http.Error(w, "Your role on this project does not allow this action", http.StatusForbidden)
 	case errors.Is(err, sql.ErrNoRows):
 		http.Error(w, "Project not found", http.StatusNotFound)
 	default:
@@ -801,23 +961,18 @@ func writeProjectAccessError(w http.ResponseWriter, err error) {
 	}
 }
 
-func makeShareLinkResponse(link database.ProjectShareLink) shareLinkResponse {
-	var expires *time.Time
-	if link.ExpiresAt.Valid {
-		expires = &link.ExpiresAt.Time
+// loadCanvasJSON normalizes stored canvas data and upgrades it to the current
+// canvas version
+func loadCanvasJSON(raw json.RawMessage) (json.RawMessage, error) {
+	data, err := normalizeCanvasJSON(raw)
+	if err != nil || len(data) == 0 {
+		return data, err
 	}
-	return shareLinkResponse{
-		ProjectID: link.ProjectID,
-		Token:     link.Token,
-		RoomKey:   link.RoomKey,
-		CreatedAt: link.CreatedAt,
-		ExpiresAt: expires,
-		CreatedBy: link.CreatedBy,
+	upgraded, _, err := canvas.Upgrade(data)
+	if err != nil {
+		return nil, err
 	}
-}
-
-func generateCollaborationToken() string {
-	return strings.ReplaceAll(uuid.NewString(), "-", "")
+	return json.RawMessage(upgraded), nil
 }
 
 func normalizeCanvasJSON(raw json.RawMessage) (json.RawMessage, error) {
@@ -0,0 +1,95 @@
+package api
+
+import "errors"
+
+// Role is a user's role on a project. The owner is implied by projects.user_id;
+// every other role is stored in project_collaborators.role.
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleAdmin     Role = "admin"
+	RoleEditor    Role = "editor"
+	RoleCommenter Role = "commenter"
+	RoleViewer    Role = "viewer"
+)
+
+// Permission is an action a handler needs the caller's role to allow
+type Permission int
+
+const (
+	// PermissionView covers reading the project, its versions and exports
+	PermissionView Permission = iota
+	// PermissionComment covers leaving feedback without changing the schema
+	PermissionComment
+	// PermissionEdit covers every mutation of the canvas
+	PermissionEdit
+	// PermissionManage covers share links and collaborator roles
+	PermissionManage
+	// PermissionDelete covers deleting the project
+	PermissionDelete
+)
+
+var errInsufficientRole = errors.New("insufficient project role")
+
+// rolePermissions lists what each role may do. Roles are not strictly
+// hierarchical: commenters can comment but not edit, viewers can only read.
+var rolePermissions = map[Role]map[Permission]bool{
+	RoleOwner: {
+		PermissionView: true, PermissionComment: true, PermissionEdit: true, PermissionManage: true, PermissionDelete: true,
+	},
+	RoleAdmin: {
+		PermissionView: true, PermissionComment: true, PermissionEdit: true, PermissionManage: true,
+	},
+	RoleEditor: {
+		PermissionView: true, PermissionComment: true, PermissionEdit: true,
+	},
+	RoleCommenter: {
+		PermissionView: true, PermissionComment: true,
+	},
+	RoleViewer: {
+		PermissionView: true,
+	},
+}
+
+// parseRole maps a stored role onto a known Role. Unknown values get the
+// least privileged role rather than failing open.
+func parseRole(s string) Role {
+	role := Role(s)
+	if _, ok := rolePermissions[role]; ok {
+		return role
+	}
+	return RoleViewer
+}
+
+// isAssignableRole reports whether role can be granted to a collaborator.
+// Ownership is tied to projects.user_id and can't be handed out this way.
+func isAssignableRole(role Role) bool {
+	_, ok := rolePermissions[role]
+	return ok && role != RoleOwner
+}
+
+// roleRank orders roles by how much they grant, for deciding whether a share
+// link would upgrade an existing collaborator
+var roleRank = map[Role]int{
+	RoleViewer:    1,
+	RoleCommenter: 2,
+	RoleEditor:    3,
+	RoleAdmin:     4,
+	RoleOwner:     5,
+}
+
+// isShareLinkRole reports whether role can be granted through a share link
+func isShareLinkRole(role Role) bool {
+	return role == RoleViewer || role == RoleEditor
+}
+
+// Outranks reports whether r grants strictly more than other
+func (r Role) Outranks(other Role) bool {
+	return roleRank[r] > roleRank[other]
+}
+
+// Can reports whether the role grants the permission
+func (r Role) Can(p Permission) bool {
+	return rolePermissions[r][p]
+}
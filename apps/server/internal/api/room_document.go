@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// roomFlushInterval is how often a room's document is saved while it has
+// unsaved changes
+const roomFlushInterval = 10 * time.Second
+
+// roomDocument is the hub's authoritative copy of a room's Yjs document. The
+// frontend keeps the canvas in two root arrays, "nodes" and "edges".
+type roomDocument struct {
+	mu    sync.Mutex
+	doc   *yjs.Doc
+	dirty bool
+	// broken is set once an update fails to apply. The copy may then differ
+	// from what clients see, so it takes no more updates and is never
+	// flushed; the hub resets the room instead.
+	broken bool
+}
+
+var errDocumentBroken = errors.New("room document failed to apply an earlier update")
+
+func newRoomDocument() *roomDocument {
+	return &roomDocument{doc: yjs.NewDoc()}
+}
+
+// loadRoomDocument starts a room's document from the project's stored
+// document state
+func loadRoomDocument(state []byte) (*roomDocument, error) {
+	doc, err := loadDocument(state)
+	if err != nil {
+		return nil, err
+	}
+	return &roomDocument{doc: doc}, nil
+}
+
+func (d *roomDocument) apply(update []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken {
+		return errDocumentBroken
+	}
+	if err := d.doc.ApplyUpdate(update); err != nil {
+		d.broken = true
+		return err
+	}
+	d.dirty = true
+	return nil
+}
+
+// merge applies an update the stored document already holds, so it doesn't
+// make the room dirty
+func (d *roomDocument) merge(update []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.broken {
+		return errDocumentBroken
+	}
+	if err := d.doc.ApplyUpdate(update); err != nil {
+		d.broken = true
+		return err
+	}
+	return nil
+}
+
+// syncStep1 asks a client for everything the server is missing
+func (d *roomDocument) syncStep1() []byte {
+	return yjs.EncodeSyncMessage(yjs.SyncStep1, d.stateVector())
+}
+
+// stateVector returns the document's encoded state vector
+func (d *roomDocument) stateVector() []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.doc.EncodeStateVector()
+}
+
+// diff returns an update with everything missing from the given state
+// vector. A nil state vector returns the full document.
+func (d *roomDocument) diff(stateVector []byte) ([]byte, error) {
+	var sv map[uint64]uint64
+	if stateVector != nil {
+		var err error
+		if sv, err = yjs.DecodeStateVector(stateVector); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.doc.EncodeStateAsUpdate(sv), nil
+}
+
+// syncStep2 answers a client's state vector with everything it is missing.
+// A nil state vector sends the full document.
+func (d *roomDocument) syncStep2(stateVector []byte) ([]byte, error) {
+	update, err := d.diff(stateVector)
+	if err != nil {
+		return nil, err
+	}
+	return yjs.EncodeSyncMessage(yjs.SyncStep2, update), nil
+}
+
+// takeState returns the whole document if it changed since it was last
+// taken. Nothing is returned until a client has written to the room, so an
+// idle room never writes to the project.
+func (d *roomDocument) takeState() ([]byte, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.dirty || d.broken {
+		return nil, false
+	}
+	d.dirty = false
+	return d.doc.EncodeStateAsUpdate(nil), true
+}
+
+func (d *roomDocument) markDirty() {
+	d.mu.Lock()
+	d.dirty = true
+	d.mu.Unlock()
+}
+
+// queueInitialSync queues the room's full state for a joining client and
+// asks it for anything the server is missing. Every client gets it, the
+// room's first included, so edits made before connecting reach the room.
+func (h *CollaborationHub) queueInitialSync(c *client, room *Room) error {
+	full, err := room.document.syncStep2(nil)
+	if err != nil {
+		return err
+	}
+	for _, message := range [][]byte{room.document.syncStep1(), full} {
+		if !c.queue(websocket.BinaryMessage, message) {
+			return fmt.Errorf("send queue full")
+		}
+	}
+	return nil
+}
+
+// runFlusher periodically writes the room's document to the project until
+// the room is closed, then flushes one last time
+func (h *CollaborationHub) runFlusher(room *Room) {
+	ticker := time.NewTicker(roomFlushInterval)
+	defer ticker.Stop()
+	defer close(room.flushed)
+	for {
+		select {
+		case <-ticker.C:
+			h.flushRoom(room)
+		case <-room.closed:
+			h.flushRoom(room)
+			return
+		}
+	}
+}
+
+// openDocument returns the document a new room of the project starts from.
+// Hubs without a database keep their rooms in memory, starting empty.
+func (h *CollaborationHub) openDocument(projectID uuid.UUID) (*roomDocument, error) {
+	if h.Conn == nil {
+		return newRoomDocument(), nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	state, err := openProjectDocument(ctx, h.Conn, h.DB, projectID)
+	if err != nil {
+		return nil, err
+	}
+	return loadRoomDocument(state)
+}
+
+// flushRoom saves the room's document through the project's stored document,
+// and sends the room whatever that holds and the room doesn't
+func (h *CollaborationHub) flushRoom(room *Room) {
+	if h.Conn == nil {
+		return
+	}
+	state, ok := room.document.takeState()
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	missing, err := saveRoomDocument(ctx, h.Conn, h.DB, room.projectID, state)
+	var problems canvasProblemsError
+	if errors.As(err, &problems) {
+		// Saved again once the room's next edit fixes them
+		log.Printf("Not flushing room for project %s: %v", room.projectID, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to flush room for project %s: %v", room.projectID, err)
+		room.document.markDirty()
+		return
+	}
+
+	if missing != nil {
+		if err := room.document.merge(missing); err != nil {
+			log.Printf("Failed to apply stored document in room %s: %v", room.key, err)
+			h.resetRoom(room)
+			return
+		}
+		h.broadcast(room, nil, websocket.BinaryMessage, yjs.EncodeSyncMessage(yjs.SyncUpdate, missing))
+	}
+
+	// Invalidate export cache for this project
+	h.Cache.DeletePrefix(fmt.Sprintf("export:%s:", room.projectID.String()))
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// Kinds of messages exchanged between hubs through the fan-out backend
+const (
+	// fanoutUpdate carries a document update to apply and relay
+	fanoutUpdate = "update"
+	// fanoutRelay carries a raw frame, such as awareness, to relay as is
+	fanoutRelay = "relay"
+	// fanoutSyncRequest carries a state vector. Hubs that have the room answer
+	// with an update addressed to the requesting hub.
+	fanoutSyncRequest = "sync-request"
+	// fanoutProjectUpdate carries a document update made outside the rooms,
+	// such as a REST save, to every room of the project. Its Room is the
+	// project ID.
+	fanoutProjectUpdate = "project-update"
+	// fanoutPresence carries the sending hub's clients in a room. Every hub
+	// keeps it, whether or not it has the room itself.
+	fanoutPresence = "presence"
+)
+
+type fanoutEnvelope struct {
+	Origin      string `json:"origin"`
+	To          string `json:"to,omitempty"`
+	Room        string `json:"room"`
+	Kind        string `json:"kind"`
+	MessageType int    `json:"message_type,omitempty"`
+	Data        []byte `json:"data"`
+}
+
+// publish sends a room message to the other hubs
+func (h *CollaborationHub) publish(env fanoutEnvelope) {
+	env.Origin = h.instanceID
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), writeWait)
+	defer cancel()
+	if err := h.fanout.Publish(ctx, payload); err != nil {
+		log.Printf("Failed to publish %s for room %s: %v", env.Kind, env.Room, err)
+	}
+}
+
+// requestSync asks the other hubs for the parts of a new room's document
+// this hub is missing
+func (h *CollaborationHub) requestSync(roomKey string, room *Room) {
+	h.publish(fanoutEnvelope{
+		Room: roomKey,
+		Kind: fanoutSyncRequest,
+		Data: room.document.stateVector(),
+	})
+}
+
+// applyProjectUpdate sends a saved edit of the project's stored document to
+// every room of the project, on every hub
+func (h *CollaborationHub) applyProjectUpdate(projectID uuid.UUID, update []byte) {
+	h.updateProjectRooms(projectID, update)
+	h.publish(fanoutEnvelope{Room: projectID.String(), Kind: fanoutProjectUpdate, Data: update})
+}
+
+// updateProjectRooms applies an edit the stored document already holds to
+// this hub's rooms of the project
+func (h *CollaborationHub) updateProjectRooms(projectID uuid.UUID, update []byte) {
+	h.mu.RLock()
+	var rooms []*Room
+	for _, room := range h.rooms {
+		if room.projectID == projectID {
+			rooms = append(rooms, room)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, room := range rooms {
+		if err := room.document.merge(update); err != nil {
+			log.Printf("Failed to apply saved edit in room %s: %v", room.key, err)
+			h.resetRoom(room)
+			continue
+		}
+		h.broadcast(room, nil, websocket.BinaryMessage, yjs.EncodeSyncMessage(yjs.SyncUpdate, update))
+	}
+}
+
+// receive handles messages from the other hubs until the backend is closed
+func (h *CollaborationHub) receive() {
+	for payload := range h.fanout.Messages() {
+		var env fanoutEnvelope
+		if err := json.Unmarshal(payload, &env); err != nil {
+			log.Printf("Dropping malformed fan-out message: %v", err)
+			continue
+		}
+		if env.Origin == h.instanceID || (env.To != "" && env.To != h.instanceID) {
+			continue
+		}
+		switch env.Kind {
+		case fanoutPresence:
+			h.receivePresence(env)
+			continue
+		case fanoutProjectUpdate:
+			if projectID, err := uuid.Parse(env.Room); err == nil {
+				h.updateProjectRooms(projectID, env.Data)
+			}
+			continue
+		}
+
+		h.mu.RLock()
+		room := h.rooms[env.Room]
+		h.mu.RUnlock()
+		if room == nil {
+			continue
+		}
+
+		switch env.Kind {
+		case fanoutUpdate:
+			if err := room.document.apply(env.Data); err != nil {
+				log.Printf("Failed to apply update in room %s: %v", env.Room, err)
+				h.resetRoom(room)
+				continue
+			}
+			h.broadcast(room, nil, websocket.BinaryMessage, yjs.EncodeSyncMessage(yjs.SyncUpdate, env.Data))
+		case fanoutRelay:
+			h.broadcast(room, nil, env.MessageType, env.Data)
+		case fanoutSyncRequest:
+			update, err := room.document.diff(env.Data)
+			if err != nil {
+				log.Printf("Invalid state vector from hub %s: %v", env.Origin, err)
+				continue
+			}
+			// Publishing from the receive loop could wait on our own full
+			// queue, so the answer is sent separately. The new hub also
+			// hears who is in the room here.
+			go func() {
+				h.publish(fanoutEnvelope{
+					To:   env.Origin,
+					Room: env.Room,
+					Kind: fanoutUpdate,
+					Data: update,
+				})
+				h.announcePresence(room)
+			}()
+		}
+	}
+}
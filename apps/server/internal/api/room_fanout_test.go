@@ -0,0 +1,53 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/fanout"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
+)
+
+func TestFailedUpdateResetsRoom(t *testing.T) {
+	bus := fanout.NewMemory()
+	h := NewCollaborationHub(nil, nil, bus.Connect())
+	other := bus.Connect()
+
+	c := newTestClient(t)
+	room, err := h.joinRoom("room", database.Project{ID: uuid.New()}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another hub relays an update this hub's document can't decode
+	bad := []byte{1, 1, 1}
+	payload, err := json.Marshal(fanoutEnvelope{Origin: "other", Room: "room", Kind: fanoutUpdate, Data: bad})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Publish(context.Background(), payload); err != nil {
+		t.Fatal(err)
+	}
+
+	eventually(t, "the room's client to be disconnected", func() bool { return isClosed(c) })
+	h.mu.RLock()
+	_, exists := h.rooms["room"]
+	h.mu.RUnlock()
+	if exists {
+		t.Error("broken room is still open")
+	}
+	if _, ok := room.document.takeState(); ok {
+		t.Error("broken room would still be flushed")
+	}
+
+	relayed := yjs.EncodeSyncMessage(yjs.SyncUpdate, bad)
+	for len(c.send) > 0 {
+		if msg := <-c.send; bytes.Equal(msg.data, relayed) {
+			t.Error("update that failed to apply was relayed to the room")
+		}
+	}
+}
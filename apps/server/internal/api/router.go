@@ -52,12 +52,28 @@ func NewRouter(authHandler *auth.Handler, projectHandler *ProjectHandler, hub *C
 
 	mux.HandleFunc("GET /projects/{id}/export", projectHandler.ExportProjectSQL)
 	mux.HandleFunc("GET /projects/{id}/export/prisma", projectHandler.ExportProjectPrisma)
+	mux.HandleFunc("GET /projects/{id}/export/mysql", projectHandler.ExportProjectMySQL)
+	mux.HandleFunc("GET /projects/{id}/export/sqlite", projectHandler.ExportProjectSQLite)
 	mux.HandleFunc("POST /projects/{id}/import-sql", projectHandler.ImportSQL)
 	mux.HandleFunc("POST /projects/{id}/ai/generate-tables", projectHandler.AIGenerateTables)
 	mux.HandleFunc("GET /projects/{id}/share-link", projectHandler.GetShareLink)
 	mux.HandleFunc("POST /projects/{id}/share-link", projectHandler.CreateShareLink)
+	mux.HandleFunc("GET /projects/{id}/share-links", projectHandler.ListShareLinks)
+	mux.HandleFunc("POST /projects/{id}/share-links", projectHandler.CreateShareLink)
+	mux.HandleFunc("DELETE /projects/{id}/share-links/{linkId}", projectHandler.RevokeShareLink)
 	mux.HandleFunc("POST /projects/share-links/{token}/join", projectHandler.JoinShareLink)
 	mux.HandleFunc("GET /projects/{id}/collaborators", projectHandler.GetProjectCollaborators)
+	mux.HandleFunc("PUT /projects/{id}/collaborators/{userId}", projectHandler.UpdateCollaboratorRole)
+	mux.HandleFunc("DELETE /projects/{id}/collaborators/{userId}", projectHandler.RemoveCollaborator)
+	mux.HandleFunc("GET /projects/{id}/presence", projectHandler.GetProjectPresence)
+	mux.HandleFunc("GET /projects/{id}/versions", projectHandler.ListProjectVersions)
+	mux.HandleFunc("GET /projects/{id}/versions/{versionId}", projectHandler.GetProjectVersion)
+	mux.HandleFunc("POST /projects/{id}/versions/{versionId}/restore", projectHandler.RestoreProjectVersion)
+	mux.HandleFunc("GET /projects/{id}/diff", projectHandler.DiffProjectVersions)
+	mux.HandleFunc("GET /projects/{id}/migration", projectHandler.GenerateProjectMigration)
+	mux.HandleFunc("POST /projects/{id}/lint", projectHandler.LintProject)
+	mux.HandleFunc("GET /projects/{id}/lint/rules", projectHandler.GetLintRules)
+	mux.HandleFunc("PUT /projects/{id}/lint/rules", projectHandler.UpdateLintRules)
 
 	// WebSocket Routes for Collaboration
 	mux.HandleFunc("/ws/collaboration/", hub.HandleWebSocket)
@@ -0,0 +1,416 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/google/uuid"
+)
+
+type shareLinkResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	ProjectID  uuid.UUID  `json:"project_id"`
+	Name       *string    `json:"name,omitempty"`
+	Role       string     `json:"role"`
+	Token      string     `json:"token,omitempty"`
+	RoomKey    string     `json:"room_key"`
+	MaxUses    *int32     `json:"max_uses,omitempty"`
+	UseCount   int32      `json:"use_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedBy  uuid.UUID  `json:"created_by"`
+}
+
+type createShareLinkRequest struct {
+	Name           string `json:"name"`
+	Role           string `json:"role"`
+	MaxUses        *int   `json:"maxUses"`
+	ExpiresInHours *int   `json:"expiresInHours"`
+}
+
+type joinShareLinkResponse struct {
+	ProjectID   uuid.UUID  `json:"project_id"`
+	ProjectName string     `json:"project_name"`
+	RoomKey     string     `json:"room_key"`
+	Token       string     `json:"token"`
+	OwnerID     uuid.UUID  `json:"owner_id"`
+	Role        string     `json:"role"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
+
+// GetShareLink returns the project's most recent active share link. Every
+// member can read it to find the collaboration room, but the token is only
+// included for members who can manage sharing.
+func (h *ProjectHandler) GetShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectIDStr := r.PathValue("id")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	_, role, err := h.getProjectWithRole(r.Context(), projectID, userID)
+	if err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	link, err := h.DB.GetActiveShareLinkForProject(r.Context(), projectID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "No active share link", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load share link", http.StatusInternalServerError)
+		return
+	}
+
+	resp := makeShareLinkResponse(link)
+	if !role.Can(PermissionManage) {
+		resp.Token = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// ListShareLinks returns every active (unrevoked) share link of the project
+func (h *ProjectHandler) ListShareLinks(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionManage); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	links, err := h.DB.ListShareLinksForProject(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to fetch share links", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]shareLinkResponse, 0, len(links))
+	for _, link := range links {
+		resp = append(resp, makeShareLinkResponse(link))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// CreateShareLink adds a share link next to the project's existing ones. Links
+// grant editor access unless a role is given.
+func (h *ProjectHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectIDStr := r.PathValue("id")
+	projectID, err := uuid.Parse(projectIDStr)
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionManage); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	var req createShareLinkRequest
+	if r.Body != nil {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	role := RoleEditor
+	if req.Role != "" {
+		role = Role(req.Role)
+		if !isShareLinkRole(role) {
+			http.Error(w, "role must be viewer or editor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var maxUses sql.NullInt32
+	if req.MaxUses != nil {
+		if *req.MaxUses <= 0 {
+			http.Error(w, "maxUses must be positive", http.StatusBadRequest)
+			return
+		}
+		maxUses = sql.NullInt32{Int32: int32(*req.MaxUses), Valid: true}
+	}
+
+	var expires sql.NullTime
+	if req.ExpiresInHours != nil && *req.ExpiresInHours > 0 {
+		expires = sql.NullTime{
+			Time:  time.Now().Add(time.Duration(*req.ExpiresInHours) * time.Hour),
+			Valid: true,
+		}
+	}
+
+	roomKey, err := h.projectRoomKey(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	link, err := h.DB.CreateProjectShareLink(r.Context(), database.CreateProjectShareLinkParams{
+		ProjectID: projectID,
+		Token:     generateCollaborationToken(),
+		RoomKey:   roomKey,
+		CreatedBy: userID,
+		ExpiresAt: expires,
+		Name:      sql.NullString{String: name, Valid: name != ""},
+		Role:      string(role),
+		MaxUses:   maxUses,
+	})
+	if err != nil {
+		http.Error(w, "Failed to create share link", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(makeShareLinkResponse(link))
+}
+
+// RevokeShareLink revokes a single share link. Collaborators who already
+// joined through it keep their access.
+func (h *ProjectHandler) RevokeShareLink(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	linkID, err := uuid.Parse(r.PathValue("linkId"))
+	if err != nil {
+		http.Error(w, "Invalid share link ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionManage); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	revoked, err := h.DB.RevokeShareLink(r.Context(), database.RevokeShareLinkParams{
+		ID:        linkID,
+		ProjectID: projectID,
+	})
+	if err != nil {
+		http.Error(w, "Failed to revoke share link", http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		http.Error(w, "Share link not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// JoinShareLink adds the caller to the link's project with the link's role.
+// Joining only consumes one of the link's uses when it grants something: new
+// members are added and existing members are upgraded, but never downgraded.
+func (h *ProjectHandler) JoinShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	token := r.PathValue("token")
+	if token == "" {
+		http.Error(w, "Share token is required", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.DB.GetShareLinkByToken(r.Context(), token)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Share link not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load share link", http.StatusInternalServerError)
+		return
+	}
+
+	if link.ExpiresAt.Valid && time.Now().After(link.ExpiresAt.Time) {
+		http.Error(w, "Share link expired", http.StatusGone)
+		return
+	}
+
+	project, err := h.DB.GetProjectByID(r.Context(), link.ProjectID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to load project", http.StatusInternalServerError)
+		return
+	}
+
+	linkRole := parseRole(link.Role)
+	granted := RoleOwner
+	if project.UserID != userID {
+		granted, err = h.joinWithShareLink(r.Context(), link, userID, linkRole)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				http.Error(w, "Share link has reached its maximum number of uses", http.StatusGone)
+				return
+			}
+			http.Error(w, "Failed to add collaborator", http.StatusInternalServerError)
+			return
+		}
+	} else if err := h.DB.TouchShareLink(r.Context(), link.ID); err != nil {
+		http.Error(w, "Failed to update share link", http.StatusInternalServerError)
+		return
+	}
+
+	resp := joinShareLinkResponse{
+		ProjectID:   project.ID,
+		ProjectName: project.Name,
+		RoomKey:     link.RoomKey,
+		Token:       link.Token,
+		OwnerID:     project.UserID,
+		Role:        string(granted),
+	}
+	if link.ExpiresAt.Valid {
+		resp.ExpiresAt = &link.ExpiresAt.Time
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// joinWithShareLink applies a share link to a non-owner and returns the role
+// they end up with. Members whose role is already as high as the link's
+// don't use it up. The use is claimed and the role granted in one
+// transaction under the project's row lock, so concurrent joins can neither
+// claim a use without granting it nor claim twice for the same user. It
+// returns sql.ErrNoRows when the link has no uses left.
+func (h *ProjectHandler) joinWithShareLink(ctx context.Context, link database.ProjectShareLink, userID uuid.UUID, linkRole Role) (Role, error) {
+	tx, err := h.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	q := h.DB.WithTx(tx)
+
+	if _, err := q.LockProject(ctx, link.ProjectID); err != nil {
+		return "", err
+	}
+	collaborator, err := q.GetProjectCollaborator(ctx, database.GetProjectCollaboratorParams{
+		ProjectID: link.ProjectID,
+		UserID:    userID,
+	})
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", err
+	}
+	if err == nil {
+		current := parseRole(collaborator.Role)
+		if !linkRole.Outranks(current) {
+			if err := q.TouchShareLink(ctx, link.ID); err != nil {
+				return "", err
+			}
+			return current, tx.Commit()
+		}
+	}
+
+	if _, err := q.ClaimShareLinkUse(ctx, link.ID); err != nil {
+		return "", err
+	}
+	if err := q.UpsertProjectCollaborator(ctx, database.UpsertProjectCollaboratorParams{
+		ProjectID: link.ProjectID,
+		UserID:    userID,
+		Role:      string(linkRole),
+	}); err != nil {
+		return "", err
+	}
+	return linkRole, tx.Commit()
+}
+
+// projectRoomKey returns the collaboration room shared by all of the project's
+// links, generating one for the project's first link.
+func (h *ProjectHandler) projectRoomKey(ctx context.Context, projectID uuid.UUID) (string, error) {
+	roomKey, err := h.DB.GetProjectRoomKey(ctx, projectID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return generateCollaborationToken(), nil
+	}
+	return roomKey, err
+}
+
+func makeShareLinkResponse(link database.ProjectShareLink) shareLinkResponse {
+	resp := shareLinkResponse{
+		ID:        link.ID,
+		ProjectID: link.ProjectID,
+		Role:      link.Role,
+		Token:     link.Token,
+		RoomKey:   link.RoomKey,
+		UseCount:  link.UseCount,
+		CreatedAt: link.CreatedAt,
+		CreatedBy: link.CreatedBy,
+	}
+	if link.Name.Valid {
+		resp.Name = &link.Name.String
+	}
+	if link.MaxUses.Valid {
+		resp.MaxUses = &link.MaxUses.Int32
+	}
+	if link.ExpiresAt.Valid {
+		resp.ExpiresAt = &link.ExpiresAt.Time
+	}
+	if link.LastUsedAt.Valid {
+		resp.LastUsedAt = &link.LastUsedAt.Time
+	}
+	return resp
+}
+
+func generateCollaborationToken() string {
+	return strings.ReplaceAll(uuid.NewString(), "-", "")
+}
@@ -0,0 +1,445 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/compiler"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/google/uuid"
+)
+
+type projectVersionResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	ProjectID     uuid.UUID       `json:"project_id"`
+	VersionNumber int32           `json:"version_number"`
+	ContentHash   string          `json:"content_hash"`
+	Message       *string         `json:"message,omitempty"`
+	CreatedBy     *uuid.UUID      `json:"created_by,omitempty"`
+	AuthorName    *string         `json:"author_name,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Data          json.RawMessage `json:"data,omitempty"`
+}
+
+// saveProjectData writes the project's canvas and records it as a version in
+// one transaction. The project row is locked first, so concurrent saves of
+// the same project number their versions one after another. The project's
+// collaboration document is edited to match, and its open rooms get the
+// edit once the save commits.
+func (h *ProjectHandler) saveProjectData(ctx context.Context, projectID, userID uuid.UUID, data json.RawMessage, message string) (database.Project, error) {
+	tx, err := h.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Project{}, err
+	}
+	defer tx.Rollback()
+	q := h.DB.WithTx(tx)
+
+	if _, err := q.LockProject(ctx, projectID); err != nil {
+		return database.Project{}, err
+	}
+	project, err := saveCanvas(ctx, q, projectID, userID, data, message)
+	if err != nil {
+		return database.Project{}, err
+	}
+	update, err := editProjectDocument(ctx, q, projectID, project.Data)
+	if err != nil {
+		return database.Project{}, fmt.Errorf("edit document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return database.Project{}, err
+	}
+
+	if update != nil && h.Hub != nil {
+		h.Hub.applyProjectUpdate(projectID, update)
+	}
+	return project, nil
+}
+
+// saveCanvas writes the project's canvas and records it as its newest
+// version. The caller must hold the project's row lock.
+func saveCanvas(ctx context.Context, q *database.Queries, projectID, userID uuid.UUID, data json.RawMessage, message string) (database.Project, error) {
+	project, err := q.UpdateProjectData(ctx, database.UpdateProjectDataParams{
+		ID:   projectID,
+		Data: data,
+	})
+	if err != nil {
+		return database.Project{}, err
+	}
+	if err := recordProjectVersion(ctx, q, projectID, userID, project.Data, message); err != nil {
+		return database.Project{}, fmt.Errorf("record version: %w", err)
+	}
+	return project, nil
+}
+
+// recordProjectVersion snapshots data as the project's newest version. Saves
+// whose content hash matches the latest version are skipped so repeated saves
+// of an unchanged canvas don't pile up duplicate versions. data should be the
+// stored (jsonb-normalized) payload so formatting differences don't defeat the
+// hash comparison. The caller must hold the project's row lock.
+func recordProjectVersion(ctx context.Context, q *database.Queries, projectID, userID uuid.UUID, data json.RawMessage, message string) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	contentHash := hashCanvasData(data)
+
+	latest, err := q.GetLatestProjectVersion(ctx, projectID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if err == nil && latest.ContentHash == contentHash {
+		return nil
+	}
+
+	_, err = q.CreateProjectVersion(ctx, database.CreateProjectVersionParams{
+		ProjectID:   projectID,
+		Data:        data,
+		ContentHash: contentHash,
+		Message:     sql.NullString{String: message, Valid: message != ""},
+		CreatedBy:   uuid.NullUUID{UUID: userID, Valid: userID != uuid.Nil},
+	})
+	return err
+}
+
+func (h *ProjectHandler) ListProjectVersions(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	rows, err := h.DB.ListProjectVersions(r.Context(), projectID)
+	if err != nil {
+		http.Error(w, "Failed to fetch versions", http.StatusInternalServerError)
+		return
+	}
+
+	versions := make([]projectVersionResponse, 0, len(rows))
+	for _, row := range rows {
+		resp := projectVersionResponse{
+			ID:            row.ID,
+			ProjectID:     row.ProjectID,
+			VersionNumber: row.VersionNumber,
+			ContentHash:   row.ContentHash,
+			CreatedAt:     row.CreatedAt,
+		}
+		if row.Message.Valid {
+			resp.Message = &row.Message.String
+		}
+		if row.CreatedBy.Valid {
+			resp.CreatedBy = &row.CreatedBy.UUID
+		}
+		if row.AuthorName.Valid {
+			resp.AuthorName = &row.AuthorName.String
+		}
+		versions = append(versions, resp)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
+
+func (h *ProjectHandler) GetProjectVersion(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	versionID, err := uuid.Parse(r.PathValue("versionId"))
+	if err != nil {
+		http.Error(w, "Invalid version ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	version, err := h.DB.GetProjectVersion(r.Context(), database.GetProjectVersionParams{
+		ID:        versionID,
+		ProjectID: projectID,
+	})
+	if err != nil {
+		writeVersionLookupError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(makeProjectVersionResponse(version))
+}
+
+func (h *ProjectHandler) RestoreProjectVersion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+	versionID, err := uuid.Parse(r.PathValue("versionId"))
+	if err != nil {
+		http.Error(w, "Invalid version ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionEdit); err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	version, err := h.DB.GetProjectVersion(r.Context(), database.GetProjectVersionParams{
+		ID:        versionID,
+		ProjectID: projectID,
+	})
+	if err != nil {
+		writeVersionLookupError(w, err)
+		return
+	}
+
+	data, err := loadCanvasJSON(version.Data)
+	if err != nil {
+		http.Error(w, "Failed to parse version data: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(data) == 0 {
+		data = version.Data
+	}
+
+	// The restore itself becomes the newest version so it can be undone too
+	message := fmt.Sprintf("Restored version %d", version.VersionNumber)
+	project, err := h.saveProjectData(r.Context(), projectID, userID, data, message)
+	if err != nil {
+		log.Printf("Failed to restore version for project %s: %v", projectID, err)
+		http.Error(w, "Failed to restore version", http.StatusInternalServerError)
+		return
+	}
+
+	// Invalidate export cache for this project
+	h.Cache.DeletePrefix(fmt.Sprintf("export:%s:", projectID.String()))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// DiffProjectVersions returns the structured schema diff between two stored
+// versions. Either side may be "current" (the default for to) to diff against
+// the project's live canvas.
+func (h *ProjectHandler) DiffProjectVersions(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
+	if err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	fromRef := r.URL.Query().Get("from")
+	if fromRef == "" {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	toRef := r.URL.Query().Get("to")
+	if toRef == "" {
+		toRef = currentVersionRef
+	}
+
+	fromData, ok := h.resolveVersionData(r.Context(), w, project, fromRef)
+	if !ok {
+		return
+	}
+	toData, ok := h.resolveVersionData(r.Context(), w, project, toRef)
+	if !ok {
+		return
+	}
+
+	diff, err := compiler.DiffCanvas(fromData, toData)
+	if err != nil {
+		http.Error(w, "Failed to diff versions: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(diff)
+}
+
+type migrationResponse struct {
+	Up       string                      `json:"up"`
+	Down     string                      `json:"down"`
+	Warnings []compiler.MigrationWarning `json:"warnings"`
+}
+
+// GenerateProjectMigration returns the ALTER-based migration between two
+// versions, either as a goose file (format=goose, the default) or as separate
+// plain up/down scripts (format=plain).
+func (h *ProjectHandler) GenerateProjectMigration(w http.ResponseWriter, r *http.Request) {
+	userID, err := h.authorize(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	projectID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid project ID", http.StatusBadRequest)
+		return
+	}
+
+	project, err := h.getProjectForUser(r.Context(), projectID, userID, PermissionView)
+	if err != nil {
+		writeProjectAccessError(w, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "goose"
+	}
+	if format != "goose" && format != "plain" {
+		http.Error(w, "format must be goose or plain", http.StatusBadRequest)
+		return
+	}
+
+	fromRef := r.URL.Query().Get("from")
+	if fromRef == "" {
+		http.Error(w, "from is required", http.StatusBadRequest)
+		return
+	}
+	toRef := r.URL.Query().Get("to")
+	if toRef == "" {
+		toRef = currentVersionRef
+	}
+
+	fromData, ok := h.resolveVersionData(r.Context(), w, project, fromRef)
+	if !ok {
+		return
+	}
+	toData, ok := h.resolveVersionData(r.Context(), w, project, toRef)
+	if !ok {
+		return
+	}
+
+	migration, err := compiler.GenerateCanvasMigration(fromData, toData)
+	if err != nil {
+		http.Error(w, "Failed to generate migration: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if format == "goose" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(migration.Goose()))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(migrationResponse{
+		Up:       migration.UpSQL(),
+		Down:     migration.DownSQL(),
+		Warnings: migration.Warnings,
+	})
+}
+
+// currentVersionRef selects the project's live canvas instead of a stored version
+const currentVersionRef = "current"
+
+// resolveVersionData loads the canvas for a version reference, writing the
+// error response itself when the reference can't be resolved.
+func (h *ProjectHandler) resolveVersionData(ctx context.Context, w http.ResponseWriter, project database.Project, ref string) (json.RawMessage, bool) {
+	var raw json.RawMessage
+	if ref == currentVersionRef {
+		raw = project.Data
+	} else {
+		versionID, err := uuid.Parse(ref)
+		if err != nil {
+			http.Error(w, "Invalid version ID: "+ref, http.StatusBadRequest)
+			return nil, false
+		}
+		version, err := h.DB.GetProjectVersion(ctx, database.GetProjectVersionParams{
+			ID:        versionID,
+			ProjectID: project.ID,
+		})
+		if err != nil {
+			writeVersionLookupError(w, err)
+			return nil, false
+		}
+		raw = version.Data
+	}
+
+	data, err := loadCanvasJSON(raw)
+	if err != nil {
+		http.Error(w, "Failed to parse project data: "+err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	return data, true
+}
+
+func makeProjectVersionResponse(version database.ProjectVersion) projectVersionResponse {
+	resp := projectVersionResponse{
+		ID:            version.ID,
+		ProjectID:     version.ProjectID,
+		VersionNumber: version.VersionNumber,
+		ContentHash:   version.ContentHash,
+		CreatedAt:     version.CreatedAt,
+		Data:          version.Data,
+	}
+	if version.Message.Valid {
+		resp.Message = &version.Message.String
+	}
+	if version.CreatedBy.Valid {
+		resp.CreatedBy = &version.CreatedBy.UUID
+	}
+	return resp
+}
+
+func writeVersionLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Version not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, "Failed to load version", http.StatusInternalServerError)
+}
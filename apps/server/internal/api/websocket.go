@@ -1,11 +1,23 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/cache"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/fanout"
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/yjs"
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
 
@@ -18,54 +30,254 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		// Allow all origins in development
-		// In production, you should check the origin
-		return true
-	},
-	ReadBufferSize:  4096,
-	WriteBufferSize: 4096,
-	// Enable compression for better performance
-	EnableCompression: true,
-}
+// roomKeyPrefix is prepended to the room key by the frontend's y-websocket provider
+const roomKeyPrefix = "skyforge-"
 
 // Room represents a collaboration room
 type Room struct {
-	clients map[*websocket.Conn]bool
-	mu      sync.RWMutex
+	key       string
+	clients   map[*client]bool
+	mu        sync.RWMutex
+	projectID uuid.UUID
+	document  *roomDocument
+	// closed is closed when the room is removed from the hub
+	closed chan struct{}
+	// flushed is closed once the room's final flush is done
+	flushed chan struct{}
 }
 
-// CollaborationHub manages all collaboration rooms
+// CollaborationHub manages the collaboration rooms of this server instance.
+// Room traffic is also published through the fan-out backend, so clients of
+// the same room connected to other instances stay in sync.
 type CollaborationHub struct {
-	rooms map[string]*Room
-	mu    sync.RWMutex
+	// Conn is the connection pool behind DB. Without one, rooms live in
+	// memory only: they start empty and are never saved.
+	Conn     *sql.DB
+	DB       *database.Queries
+	Cache    *cache.Cache
+	rooms    map[string]*Room
+	mu       sync.RWMutex
+	upgrader websocket.Upgrader
+
+	fanout     fanout.Backend
+	instanceID string
+	// closing is set by Shutdown; no rooms can be joined afterwards
+	closing bool
+
+	// remotePresence holds what the other instances last reported about
+	// their clients, by room key and instance ID
+	presenceMu     sync.Mutex
+	remotePresence map[string]map[string]remoteRoomPresence
+}
+
+var errHubClosing = errors.New("collaboration hub is shutting down")
+
+// NewCollaborationHub creates a hub saving rooms through conn and db and
+// publishing through backend. A nil backend keeps rooms local to this
+// instance.
+func NewCollaborationHub(conn *sql.DB, db *database.Queries, backend fanout.Backend) *CollaborationHub {
+	if backend == nil {
+		backend = fanout.NewMemory().Connect()
+	}
+	allowed := allowedOrigins()
+	h := &CollaborationHub{
+		Conn:       conn,
+		DB:         db,
+		Cache:      cache.GetGlobal(),
+		rooms:      make(map[string]*Room),
+		fanout:     backend,
+		instanceID: uuid.NewString(),
+
+		remotePresence: make(map[string]map[string]remoteRoomPresence),
+		upgrader: websocket.Upgrader{
+			CheckOrigin: func(r *http.Request) bool {
+				return checkOrigin(r, allowed)
+			},
+			ReadBufferSize:  4096,
+			WriteBufferSize: 4096,
+			// Enable compression for better performance
+			EnableCompression: true,
+		},
+	}
+	go h.receive()
+	go h.refreshPresence()
+	return h
+}
+
+// allowedOrigins lists the origins allowed to open collaboration sockets.
+// FRONTEND_URL may hold several comma-separated URLs.
+func allowedOrigins() map[string]bool {
+	frontendURL := os.Getenv("FRONTEND_URL")
+	if frontendURL == "" {
+		// Fallback for local development
+		frontendURL = "http://localhost:3000"
+	}
+
+	origins := make(map[string]bool)
+	for _, raw := range strings.Split(frontendURL, ",") {
+		u, err := url.Parse(strings.TrimSpace(raw))
+		if err != nil || u.Host == "" {
+			continue
+		}
+		origins[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	return origins
+}
+
+// checkOrigin rejects browser handshakes from origins other than the
+// frontend. Requests without an Origin header don't come from a browser and
+// are left to the auth check.
+func checkOrigin(r *http.Request, allowed map[string]bool) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return allowed[strings.ToLower(u.Scheme+"://"+u.Host)]
 }
 
-func NewCollaborationHub() *CollaborationHub {
-	return &CollaborationHub{
-		rooms: make(map[string]*Room),
+// resolveRoom resolves the room key to its project and returns the project
+// and the user's role on it
+func (h *CollaborationHub) resolveRoom(ctx context.Context, roomKey string, userID uuid.UUID) (database.Project, Role, error) {
+	projectID, err := h.DB.GetProjectIDByRoomKey(ctx, strings.TrimPrefix(roomKey, roomKeyPrefix))
+	if err != nil {
+		return database.Project{}, "", err
 	}
+	return loadProjectRole(ctx, h.DB, projectID, userID)
 }
 
-func (h *CollaborationHub) getOrCreateRoom(roomKey string) *Room {
+// isReadOnlyMessage reports whether a y-websocket message leaves the shared
+// document untouched: awareness traffic and sync step 1 (a state vector
+// request). Anything unrecognised counts as a write.
+func isReadOnlyMessage(message []byte) bool {
+	msg, err := yjs.DecodeMessage(message)
+	if err != nil {
+		return false
+	}
+	switch msg.Type {
+	case yjs.MessageAwareness, yjs.MessageQueryAwareness:
+		return true
+	case yjs.MessageSync:
+		return msg.Step == yjs.SyncStep1
+	default:
+		return false
+	}
+}
+
+// joinRoom adds c to the room, creating the room from the project's stored
+// document and starting its flusher if needed. The initial sync is queued
+// while holding the room's lock, so no broadcast can reach the client ahead
+// of it.
+func (h *CollaborationHub) joinRoom(roomKey string, project database.Project, c *client) (*Room, error) {
+	for {
+		h.mu.RLock()
+		_, exists := h.rooms[roomKey]
+		h.mu.RUnlock()
+
+		// Opening reads the whole document, so it's done before taking the
+		// lock. It's thrown away if another client created the room meanwhile.
+		var document *roomDocument
+		if !exists {
+			var err error
+			if document, err = h.openDocument(project.ID); err != nil {
+				return nil, fmt.Errorf("open document: %w", err)
+			}
+		}
+
+		room, ok, err := h.addClient(roomKey, project.ID, document, c)
+		if ok || err != nil {
+			return room, err
+		}
+		// The room was removed before c could join it
+	}
+}
+
+// addClient adds c to the room, creating it from document if it doesn't
+// exist. It reports false if the room doesn't exist and document is nil.
+func (h *CollaborationHub) addClient(roomKey string, projectID uuid.UUID, document *roomDocument, c *client) (*Room, bool, error) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if h.closing {
+		return nil, false, errHubClosing
+	}
 
 	room, exists := h.rooms[roomKey]
 	if !exists {
+		if document == nil {
+			return nil, false, nil
+		}
 		room = &Room{
-			clients: make(map[*websocket.Conn]bool),
+			key:       roomKey,
+			clients:   make(map[*client]bool),
+			projectID: projectID,
+			document:  document,
+			closed:    make(chan struct{}),
+			flushed:   make(chan struct{}),
 		}
 		h.rooms[roomKey] = room
+		go h.runFlusher(room)
+		go h.requestSync(roomKey, room)
 	}
-	return room
+
+	room.mu.Lock()
+	defer room.mu.Unlock()
+	if err := h.queueInitialSync(c, room); err != nil {
+		return nil, false, err
+	}
+	room.clients[c] = true
+	return room, true, nil
 }
 
-func (h *CollaborationHub) removeRoom(roomKey string) {
+// leaveRoom removes c from the room and removes the room once it's empty.
+// It returns the number of clients left.
+func (h *CollaborationHub) leaveRoom(roomKey string, room *Room, c *client) int {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	delete(h.rooms, roomKey)
+
+	room.mu.Lock()
+	delete(room.clients, c)
+	clientCount := len(room.clients)
+	room.mu.Unlock()
+
+	if clientCount == 0 && h.rooms[roomKey] == room {
+		delete(h.rooms, roomKey)
+		close(room.closed)
+	}
+	return clientCount
+}
+
+// resetRoom removes a room whose document failed to apply an update and
+// disconnects its clients. They reconnect to a new room opened from the
+// stored document and send it whatever it lacks, so nothing is lost, while
+// the broken copy is never saved.
+func (h *CollaborationHub) resetRoom(room *Room) {
+	h.mu.Lock()
+	if h.rooms[room.key] == room {
+		delete(h.rooms, room.key)
+		close(room.closed)
+	}
+	h.mu.Unlock()
+
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	for c := range room.clients {
+		go c.goAway("room reset")
+	}
+}
+
+// broadcast queues a message for every client in the room except skip,
+// which may be nil
+func (h *CollaborationHub) broadcast(room *Room, skip *client, messageType int, message []byte) {
+	room.mu.RLock()
+	defer room.mu.RUnlock()
+	for c := range room.clients {
+		if c != skip {
+			c.deliver(messageType, message)
+		}
+	}
 }
 
 func (h *CollaborationHub) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
@@ -76,68 +288,83 @@ func (h *CollaborationHub) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	h.mu.RLock()
+	closing := h.closing
+	h.mu.RUnlock()
+	if closing {
+		http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	userID, err := userIDFromRequest(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	project, role, err := h.resolveRoom(r.Context(), roomKey, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, errProjectAccessDenied):
+			http.Error(w, "Forbidden", http.StatusForbidden)
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Room not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Database error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	user, err := h.DB.GetUserByID(r.Context(), userID)
+	if err != nil {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+
 	// Upgrade connection to WebSocket
-	conn, err := upgrader.Upgrade(w, r, nil)
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
 		return
 	}
-	defer conn.Close()
-
-	room := h.getOrCreateRoom(roomKey)
+	c := newClient(conn, user, role, r.URL.Query().Get("presence") == "1")
+	defer c.close()
 
-	// Add client to room
-	room.mu.Lock()
-	room.clients[conn] = true
-	room.mu.Unlock()
+	room, err := h.joinRoom(roomKey, project, c)
+	if errors.Is(err, errHubClosing) {
+		c.goAway("server shutting down")
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to join room %s: %v", roomKey, err)
+		return
+	}
+	go c.writePump()
 
+	room.mu.RLock()
 	log.Printf("Client connected to room: %s (total clients: %d)", roomKey, len(room.clients))
+	room.mu.RUnlock()
+	h.broadcastPresence(room)
 
 	// Remove client when they disconnect
 	defer func() {
-		room.mu.Lock()
-		delete(room.clients, conn)
-		room.mu.Unlock()
-
-		// Remove room if empty
-		room.mu.RLock()
-		clientCount := len(room.clients)
-		room.mu.RUnlock()
-
+		clientCount := h.leaveRoom(roomKey, room, c)
 		if clientCount == 0 {
-			h.removeRoom(roomKey)
 			log.Printf("Room %s removed (no clients)", roomKey)
 		} else {
 			log.Printf("Client disconnected from room: %s (remaining clients: %d)", roomKey, clientCount)
 		}
+		h.broadcastPresence(room)
 	}()
 
-	// Set up ping/pong handlers for connection health
+	// Set up ping/pong handlers for connection health; pings are sent by the
+	// client's writer
 	conn.SetReadDeadline(time.Now().Add(pongWait))
 	conn.SetPongHandler(func(string) error {
 		conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
 
-	// Start a goroutine to send periodic pings
-	done := make(chan struct{})
-	go func() {
-		ticker := time.NewTicker(pingPeriod)
-		defer ticker.Stop()
-		for {
-			select {
-			case <-ticker.C:
-				conn.SetWriteDeadline(time.Now().Add(writeWait))
-				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
-					return
-				}
-			case <-done:
-				return
-			}
-		}
-	}()
-
-	// Broadcast messages to all clients in the room (including sender for Yjs sync)
 	for {
 		messageType, message, err := conn.ReadMessage()
 		if err != nil {
@@ -147,28 +374,77 @@ func (h *CollaborationHub) HandleWebSocket(w http.ResponseWriter, r *http.Reques
 			break
 		}
 
-		// Broadcast to all clients in the room (Yjs needs echo back to sender)
+		// Sync messages are answered from and applied to the room's document
+		if msg, err := yjs.DecodeMessage(message); messageType == websocket.BinaryMessage && err == nil && msg.Type == yjs.MessageSync {
+			switch msg.Step {
+			case yjs.SyncStep1:
+				reply, err := room.document.syncStep2(msg.Payload)
+				if err != nil {
+					log.Printf("Invalid state vector in room %s: %v", roomKey, err)
+					continue
+				}
+				c.deliver(websocket.BinaryMessage, reply)
+				continue
+			case yjs.SyncStep2, yjs.SyncUpdate:
+				// Read-only members' document updates never reach the
+				// document or the other clients
+				if c.readOnly {
+					continue
+				}
+				if err := room.document.apply(msg.Payload); err != nil {
+					log.Printf("Failed to apply update in room %s: %v", roomKey, err)
+					h.resetRoom(room)
+					continue
+				}
+				h.broadcast(room, c, websocket.BinaryMessage, yjs.EncodeSyncMessage(yjs.SyncUpdate, msg.Payload))
+				h.publish(fanoutEnvelope{Room: roomKey, Kind: fanoutUpdate, Data: msg.Payload})
+				continue
+			}
+		}
+
+		// Read-only members still share awareness, but nothing else
+		if c.readOnly && (messageType != websocket.BinaryMessage || !isReadOnlyMessage(message)) {
+			continue
+		}
+
+		// Everything else (awareness) is relayed to the whole room
+		h.broadcast(room, nil, messageType, message)
+		h.publish(fanoutEnvelope{Room: roomKey, Kind: fanoutRelay, MessageType: messageType, Data: message})
+
+		if msg, err := yjs.DecodeMessage(message); err == nil && msg.Type == yjs.MessageAwareness && c.updatePresence(msg.Payload) {
+			h.broadcastPresence(room)
+		}
+	}
+}
+
+// Shutdown stops the hub from accepting clients, closes every connected
+// client with a going-away close frame and waits until each room has flushed
+// its document, or until ctx is done
+func (h *CollaborationHub) Shutdown(ctx context.Context) error {
+	h.mu.Lock()
+	h.closing = true
+	rooms := make([]*Room, 0, len(h.rooms))
+	for _, room := range h.rooms {
+		rooms = append(rooms, room)
+	}
+	h.mu.Unlock()
+
+	for _, room := range rooms {
 		room.mu.RLock()
-		clients := make([]*websocket.Conn, 0, len(room.clients))
-		for client := range room.clients {
-			clients = append(clients, client)
+		for c := range room.clients {
+			go c.goAway("server shutting down")
 		}
 		room.mu.RUnlock()
+	}
 
-		// Broadcast to all clients with write deadline
-		for _, client := range clients {
-			client.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := client.WriteMessage(messageType, message); err != nil {
-				log.Printf("Error broadcasting message: %v", err)
-				client.Close()
-				room.mu.Lock()
-				delete(room.clients, client)
-				room.mu.Unlock()
-			}
+	// Each client's read loop leaves its room as the connection closes, and
+	// the last one out triggers the room's final flush
+	for _, room := range rooms {
+		select {
+		case <-room.flushed:
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
-	
-	// Signal ping goroutine to stop
-	close(done)
+	return nil
 }
-
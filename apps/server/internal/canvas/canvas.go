@@ -0,0 +1,316 @@
+// Package canvas is the typed model of a project's canvas: the React Flow
+// nodes and edges the frontend edits, stored in projects.data.
+package canvas
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// CurrentVersion is the canvas encoding written by this server. Older
+// canvases are brought up to it by Upgrade.
+const CurrentVersion = 2
+
+// React Flow node and edge types used by the frontend
+const (
+	NodeTypeTable      = "tableNode"
+	EdgeTypeSmoothStep = "smoothstep"
+)
+
+// Column constraint codes
+const (
+	ConstraintNotNull       = "NN"
+	ConstraintUnique        = "UNQ"
+	ConstraintForeignKey    = "FK"
+	ConstraintAutoIncrement = "AI"
+)
+
+// Index access methods. An empty method leaves the choice to the database,
+// which is a B-tree everywhere.
+const (
+	IndexMethodBTree  = "btree"
+	IndexMethodHash   = "hash"
+	IndexMethodGIN    = "gin"
+	IndexMethodGiST   = "gist"
+	IndexMethodSPGiST = "spgist"
+	IndexMethodBRIN   = "brin"
+)
+
+// Index column orders. An empty order is ascending.
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+// Column default kinds
+const (
+	// DefaultLiteral is a constant. Its value is stored unquoted; generators
+	// quote it as the column's type requires.
+	DefaultLiteral = "literal"
+	// DefaultExpression is SQL evaluated on insert, such as now()
+	DefaultExpression = "expression"
+	// DefaultSequence numbers rows from a sequence, identity or
+	// auto-increment counter. Its value, when set, is the sequence it was
+	// imported from.
+	DefaultSequence = "sequence"
+)
+
+// Referential actions a foreign key takes when the referenced row is deleted
+// or updated. An empty action is the database default, NO ACTION.
+const (
+	ActionNoAction   = "no_action"
+	ActionRestrict   = "restrict"
+	ActionCascade    = "cascade"
+	ActionSetNull    = "set_null"
+	ActionSetDefault = "set_default"
+)
+
+// Canvas is a whole canvas document
+type Canvas struct {
+	Version int    `json:"version,omitempty"`
+	Nodes   []Node `json:"nodes"`
+	Edges   []Edge `json:"edges"`
+}
+
+// Node is a table on the canvas
+type Node struct {
+	ID       string    `json:"id"`
+	Type     string    `json:"type,omitempty"`
+	Position *Position `json:"position,omitempty"`
+	Data     TableData `json:"data"`
+
+	// Extra holds attributes the server doesn't model, such as React Flow's
+	// measurements, so they survive a decode and encode
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type Position struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+type TableData struct {
+	Name    string   `json:"name"`
+	Label   string   `json:"label,omitempty"`
+	Columns []Column `json:"columns"`
+	// CompositePrimaryKey says whether the table's primary key is meant to
+	// span several columns. Unset means it may; only an explicit false makes
+	// several primary key columns a problem.
+	CompositePrimaryKey *bool   `json:"compositePrimaryKey,omitempty"`
+	Indexes             []Index `json:"indexes,omitempty"`
+	Checks              []Check `json:"checks,omitempty"`
+
+	// Extra holds table settings only the frontend uses
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type Column struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Type         string   `json:"type"`
+	IsPrimaryKey bool     `json:"isPrimaryKey"`
+	IsUnique     bool     `json:"isUnique,omitempty"`
+	IsNullable   bool     `json:"isNullable,omitempty"`
+	Constraints  []string `json:"constraints"`
+	Default      *Default `json:"default,omitempty"`
+
+	// Extra holds column attributes the server doesn't model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Default is the value a column takes when an insert leaves it out
+type Default struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+
+	// Extra holds what newer frontends add to a default
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Index is a secondary index on a table. Columns refer to the table's
+// columns by ID, so renaming a column keeps its indexes.
+type Index struct {
+	ID      string        `json:"id"`
+	Name    string        `json:"name,omitempty"`
+	Columns []IndexColumn `json:"columns"`
+	Unique  bool          `json:"unique,omitempty"`
+	// Where is the predicate of a partial index, without the WHERE keyword
+	Where  string `json:"where,omitempty"`
+	Method string `json:"method,omitempty"`
+
+	// Extra holds index options the server doesn't model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Check is a CHECK constraint. ColumnID is set when the check was declared
+// on a column rather than on the table; either way it may refer to any of
+// the table's columns.
+type Check struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+	// Expression is the condition, without the CHECK keyword or the
+	// parentheses around it
+	Expression string `json:"expression"`
+	ColumnID   string `json:"columnId,omitempty"`
+
+	// Extra holds check options the server doesn't model
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type IndexColumn struct {
+	ColumnID string `json:"columnId"`
+	Order    string `json:"order,omitempty"`
+
+	// Extra holds per-column index options, such as collations
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Edge is a foreign key. The target table holds the key and the source
+// table is the one it references, so an edge is drawn from the referenced
+// column to the referencing one.
+type Edge struct {
+	ID           string                 `json:"id,omitempty"`
+	Source       string                 `json:"source"`
+	Target       string                 `json:"target"`
+	SourceHandle string                 `json:"sourceHandle"`
+	TargetHandle string                 `json:"targetHandle"`
+	Type         string                 `json:"type,omitempty"`
+	Animated     bool                   `json:"animated,omitempty"`
+	Label        string                 `json:"label,omitempty"`
+	Style        map[string]interface{} `json:"style,omitempty"`
+	LabelStyle   map[string]interface{} `json:"labelStyle,omitempty"`
+	LabelBgStyle map[string]interface{} `json:"labelBgStyle,omitempty"`
+	Data         *EdgeData              `json:"data,omitempty"`
+
+	// Extra holds React Flow edge attributes, such as markers
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// EdgeData is the server's part of an edge's React Flow data
+type EdgeData struct {
+	// Columns are the ordered column pairs of a composite foreign key; the
+	// first pair is the one the handles are drawn between. Single-column
+	// edges leave it empty.
+	Columns []EdgeColumn `json:"columns,omitempty"`
+	// OnDelete and OnUpdate are referential actions
+	OnDelete string `json:"onDelete,omitempty"`
+	OnUpdate string `json:"onUpdate,omitempty"`
+	// Deferrable lets the check wait until the end of the transaction when
+	// asked to; InitiallyDeferred makes it wait by default and implies
+	// Deferrable.
+	Deferrable        bool `json:"deferrable,omitempty"`
+	InitiallyDeferred bool `json:"initiallyDeferred,omitempty"`
+
+	// Extra holds the rest of the edge's React Flow data
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// EdgeColumn pairs a referenced source column with the target column that
+// references it
+type EdgeColumn struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// New returns an empty canvas at the current version
+func New() *Canvas {
+	return &Canvas{
+		Version: CurrentVersion,
+		Nodes:   []Node{},
+		Edges:   []Edge{},
+	}
+}
+
+// Decode upgrades and parses a stored canvas
+func Decode(data []byte) (*Canvas, error) {
+	data, _, err := Upgrade(data)
+	if err != nil {
+		return nil, err
+	}
+	c := &Canvas{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	if c.Nodes == nil {
+		c.Nodes = []Node{}
+	}
+	if c.Edges == nil {
+		c.Edges = []Edge{}
+	}
+	return c, nil
+}
+
+// Encode serializes the canvas at the current version
+func Encode(c *Canvas) ([]byte, error) {
+	out := *c
+	out.Version = CurrentVersion
+	if out.Nodes == nil {
+		out.Nodes = []Node{}
+	}
+	if out.Edges == nil {
+		out.Edges = []Edge{}
+	}
+	return json.Marshal(&out)
+}
+
+// TableName returns the node's table name, falling back to its label
+func (n Node) TableName() string {
+	if name := strings.TrimSpace(n.Data.Name); name != "" {
+		return name
+	}
+	return strings.TrimSpace(n.Data.Label)
+}
+
+// HasConstraint reports whether the column carries the constraint code
+func (c Column) HasConstraint(code string) bool {
+	for _, constraint := range c.Constraints {
+		if strings.EqualFold(constraint, code) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddConstraint adds the constraint code unless the column already has it
+func (c *Column) AddConstraint(code string) {
+	if !c.HasConstraint(code) {
+		c.Constraints = append(c.Constraints, code)
+	}
+}
+
+// Column returns the column with the given ID
+func (d TableData) Column(id string) (Column, bool) {
+	for _, col := range d.Columns {
+		if col.ID == id {
+			return col, true
+		}
+	}
+	return Column{}, false
+}
+
+// ColumnPairs returns the edge's column pairs, in key order
+func (e Edge) ColumnPairs() []EdgeColumn {
+	if e.Data != nil && len(e.Data.Columns) > 0 {
+		return e.Data.Columns
+	}
+	return []EdgeColumn{{
+		Source: HandleColumnID(e.SourceHandle),
+		Target: HandleColumnID(e.TargetHandle),
+	}}
+}
+
+// SourceHandle returns the handle an edge leaves a column from
+func SourceHandle(columnID string) string {
+	return columnID + "-source"
+}
+
+// TargetHandle returns the handle an edge enters a column at
+func TargetHandle(columnID string) string {
+	return columnID + "-target"
+}
+
+// HandleColumnID returns the column ID an edge handle belongs to
+func HandleColumnID(handle string) string {
+	handle = strings.TrimSuffix(handle, "-source")
+	return strings.TrimSuffix(handle, "-target")
+}
@@ -0,0 +1,129 @@
+package canvas
+
+import "strings"
+
+// expressionKeywords are the words a check expression can contain that never
+// name a column: operators, literals, the keywords inside calls such as
+// TRIM, SUBSTRING and OVERLAY, and the type and field names that follow
+// casts and EXTRACT
+var expressionKeywords = map[string]bool{
+	"and": true, "or": true, "not": true, "in": true, "is": true, "null": true,
+	"true": true, "false": true, "unknown": true, "between": true, "symmetric": true,
+	"like": true, "ilike": true, "similar": true, "to": true, "escape": true,
+	"regexp": true, "rlike": true, "glob": true, "match": true,
+	"case": true, "when": true, "then": true, "else": true, "end": true,
+	"any": true, "all": true, "some": true, "array": true, "exists": true,
+	"distinct": true, "from": true, "collate": true, "as": true, "isnull": true, "notnull": true,
+	"current_date": true, "current_time": true, "current_timestamp": true,
+	"localtime": true, "localtimestamp": true, "current_user": true, "session_user": true,
+	"interval": true, "date": true, "time": true, "timestamp": true, "with": true,
+	"without": true, "zone": true, "varying": true, "character": true, "precision": true,
+	"double": true, "integer": true, "int": true, "bigint": true, "smallint": true,
+	"numeric": true, "decimal": true, "real": true, "text": true, "varchar": true,
+	"char": true, "boolean": true, "uuid": true, "json": true, "jsonb": true,
+	"year": true, "month": true, "day": true, "hour": true, "minute": true, "second": true,
+	"epoch": true, "at": true, "both": true, "leading": true, "trailing": true,
+	"for": true, "placing": true, "overlaps": true, "using": true, "of": true,
+}
+
+// nameKeywords are followed by a name that isn't a column: the type in
+// CAST(x AS type) and the collation after COLLATE
+var nameKeywords = map[string]bool{"as": true, "collate": true}
+
+// expressionColumns lists the column names a SQL expression refers to, in
+// order of first use. It only lists names it is sure of, skipping string
+// literals, numbers, keywords, function names, the qualifier of a qualified
+// name, the types after :: and AS, collation names and the types of typed
+// literals such as date '2024-01-01'.
+func expressionColumns(expr string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if key := strings.ToLower(name); !seen[key] {
+			seen[key] = true
+			names = append(names, name)
+		}
+	}
+
+	// next returns the first byte after position i that isn't a space
+	next := func(i int) byte {
+		for ; i < len(expr); i++ {
+			if expr[i] != ' ' && expr[i] != '\t' && expr[i] != '\n' {
+				return expr[i]
+			}
+		}
+		return 0
+	}
+	// afterNameKeyword reports whether the word at start follows :: or a
+	// keyword in nameKeywords
+	afterNameKeyword := func(start int) bool {
+		before := strings.TrimRight(expr[:start], " \t\n")
+		if strings.HasSuffix(before, "::") {
+			return true
+		}
+		end := len(before)
+		for end > 0 && isIdentPart(before[end-1]) {
+			end--
+		}
+		return nameKeywords[strings.ToLower(before[end:])]
+	}
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == '\'':
+			i = skipQuoted(expr, i, '\'')
+		case c == '"' || c == '`':
+			start := i
+			i = skipQuoted(expr, i, c)
+			name := strings.Trim(expr[start:i], string(c))
+			if name != "" && next(i) != '(' && next(i) != '.' && !afterNameKeyword(start) {
+				add(name)
+			}
+		case isIdentStart(c):
+			start := i
+			for i < len(expr) && isIdentPart(expr[i]) {
+				i++
+			}
+			word := expr[start:i]
+			switch {
+			case next(i) == '\'':
+				// A literal prefix such as E'...' or a typed literal
+			case next(i) == '(' || next(i) == '.' || afterNameKeyword(start):
+			case expressionKeywords[strings.ToLower(word)]:
+			default:
+				add(word)
+			}
+		case c >= '0' && c <= '9':
+			for i < len(expr) && isIdentPart(expr[i]) {
+				i++
+			}
+		default:
+			i++
+		}
+	}
+	return names
+}
+
+// skipQuoted returns the position just past the quoted text starting at i.
+// A doubled quote inside is an escaped quote.
+func skipQuoted(s string, i int, quote byte) int {
+	for i++; i < len(s); i++ {
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9') || c == '$'
+}
@@ -0,0 +1,90 @@
+package canvas
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExpressionColumns(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"price > 0", []string{"price"}},
+		{"price > 0 AND price < max_price", []string{"price", "max_price"}},
+		{"status IN ('active', 'it''s done')", []string{"status"}},
+		{"email LIKE '%@%' ESCAPE '!'", []string{"email"}},
+		{"length(name) BETWEEN 1 AND 100", []string{"name"}},
+		{"t.amount >= 0", []string{"amount"}},
+		{`"Order Total" >= 0`, []string{"Order Total"}},
+		{"ends_at IS NULL OR ends_at > starts_at", []string{"ends_at", "starts_at"}},
+		{"CASE WHEN kind = 'a' THEN qty > 0 ELSE true END", []string{"kind", "qty"}},
+		{"code ~ E'^[A-Z]+$'", []string{"code"}},
+		{"amount::numeric(10, 2) > 0", []string{"amount"}},
+		{"EXTRACT(year FROM born_on) > 1900", []string{"born_on"}},
+
+		// Collations, casts, TRIM and typed literals name no columns
+		{`name COLLATE "C" <> ''`, []string{"name"}},
+		{"name COLLATE en_US <> ''", []string{"name"}},
+		{"trim(both ' ' from name) <> ''", []string{"name"}},
+		{"trim(leading '0' from code) <> ''", []string{"code"}},
+		{"CAST(created AS timestamptz) > '2020-01-01'", []string{"created"}},
+		{"CAST(email AS citext) <> ''", []string{"email"}},
+		{"CAST(ratio AS double precision) <= 1", []string{"ratio"}},
+		{"email::citext <> ''", []string{"email"}},
+		{"created_at > timestamptz '2020-01-01'", []string{"created_at"}},
+		{"created_at AT TIME ZONE 'UTC' > date '2020-01-01'", []string{"created_at"}},
+		{"substring(code from 1 for 2) = 'AB'", []string{"code"}},
+		{"overlay(code placing 'x' from 1 for 1) <> code", []string{"code"}},
+		{"(starts_at, ends_at) OVERLAPS (now(), now())", []string{"starts_at", "ends_at"}},
+
+		{"1 = 1", nil},
+		{"'literal only'", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			if got := expressionColumns(tt.expr); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expressionColumns(%q) = %q, want %q", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateAcceptsChecksOnKnownColumns(t *testing.T) {
+	tests := []struct {
+		expr    string
+		missing bool
+	}{
+		{`name COLLATE "C" <> ''`, false},
+		{"trim(both ' ' from name) <> ''", false},
+		{"CAST(created_at AS timestamptz) > '2020-01-01'", false},
+		{"CAST(name AS citext) <> ''", false},
+		{"nmae <> ''", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c := &Canvas{Nodes: []Node{{
+				ID: "users",
+				Data: TableData{
+					Name: "users",
+					Columns: []Column{
+						{ID: "name", Name: "name", Type: "text"},
+						{ID: "created_at", Name: "created_at", Type: "timestamp"},
+					},
+					Checks: []Check{{ID: "check", Expression: tt.expr}},
+				},
+			}}}
+
+			var missing bool
+			for _, p := range Validate(c) {
+				if p.Code != ProblemCheckColumn {
+					t.Errorf("unexpected problem %+v", p)
+				}
+				missing = true
+			}
+			if missing != tt.missing {
+				t.Errorf("reported a missing column: %v, want %v", missing, tt.missing)
+			}
+		})
+	}
+}
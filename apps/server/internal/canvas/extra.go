@@ -0,0 +1,171 @@
+package canvas
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// The frontend owns the canvas, so every type with an Extra map keeps the
+// JSON members it doesn't model there instead of dropping them.
+
+func (n *Node) UnmarshalJSON(data []byte) error {
+	type plain Node
+	return unmarshalWithExtra(data, (*plain)(n), &n.Extra)
+}
+
+func (n Node) MarshalJSON() ([]byte, error) {
+	type plain Node
+	return marshalWithExtra(plain(n), n.Extra)
+}
+
+func (d *TableData) UnmarshalJSON(data []byte) error {
+	type plain TableData
+	return unmarshalWithExtra(data, (*plain)(d), &d.Extra)
+}
+
+func (d TableData) MarshalJSON() ([]byte, error) {
+	type plain TableData
+	return marshalWithExtra(plain(d), d.Extra)
+}
+
+func (c *Column) UnmarshalJSON(data []byte) error {
+	type plain Column
+	return unmarshalWithExtra(data, (*plain)(c), &c.Extra)
+}
+
+func (c Column) MarshalJSON() ([]byte, error) {
+	type plain Column
+	if c.Constraints == nil {
+		c.Constraints = []string{}
+	}
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+func (i *Index) UnmarshalJSON(data []byte) error {
+	type plain Index
+	return unmarshalWithExtra(data, (*plain)(i), &i.Extra)
+}
+
+func (i Index) MarshalJSON() ([]byte, error) {
+	type plain Index
+	if i.Columns == nil {
+		i.Columns = []IndexColumn{}
+	}
+	return marshalWithExtra(plain(i), i.Extra)
+}
+
+func (c *IndexColumn) UnmarshalJSON(data []byte) error {
+	type plain IndexColumn
+	return unmarshalWithExtra(data, (*plain)(c), &c.Extra)
+}
+
+func (c IndexColumn) MarshalJSON() ([]byte, error) {
+	type plain IndexColumn
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+func (e *Edge) UnmarshalJSON(data []byte) error {
+	type plain Edge
+	return unmarshalWithExtra(data, (*plain)(e), &e.Extra)
+}
+
+func (e Edge) MarshalJSON() ([]byte, error) {
+	type plain Edge
+	return marshalWithExtra(plain(e), e.Extra)
+}
+
+func (c *Check) UnmarshalJSON(data []byte) error {
+	type plain Check
+	return unmarshalWithExtra(data, (*plain)(c), &c.Extra)
+}
+
+func (c Check) MarshalJSON() ([]byte, error) {
+	type plain Check
+	return marshalWithExtra(plain(c), c.Extra)
+}
+
+func (d *Default) UnmarshalJSON(data []byte) error {
+	type plain Default
+	return unmarshalWithExtra(data, (*plain)(d), &d.Extra)
+}
+
+func (d Default) MarshalJSON() ([]byte, error) {
+	type plain Default
+	return marshalWithExtra(plain(d), d.Extra)
+}
+
+func (d *EdgeData) UnmarshalJSON(data []byte) error {
+	type plain EdgeData
+	return unmarshalWithExtra(data, (*plain)(d), &d.Extra)
+}
+
+func (d EdgeData) MarshalJSON() ([]byte, error) {
+	type plain EdgeData
+	return marshalWithExtra(plain(d), d.Extra)
+}
+
+func unmarshalWithExtra(data []byte, v interface{}, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	known := knownMembers(reflect.TypeOf(v).Elem())
+	for name := range members {
+		if known[name] {
+			delete(members, name)
+		}
+	}
+	if len(members) == 0 {
+		members = nil
+	}
+	*extra = members
+	return nil
+}
+
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if _, ok := members[name]; !ok {
+			members[name] = value
+		}
+	}
+	return json.Marshal(members)
+}
+
+var knownMembersCache sync.Map
+
+// knownMembers returns the JSON member names a struct type models
+func knownMembers(t reflect.Type) map[string]bool {
+	if cached, ok := knownMembersCache.Load(t); ok {
+		return cached.(map[string]bool)
+	}
+
+	known := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = t.Field(i).Name
+		}
+		known[name] = true
+	}
+	knownMembersCache.Store(t, known)
+	return known
+}
@@ -0,0 +1,170 @@
+package canvas
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// upgrade rewrites a decoded canvas document from one version to the next
+type upgrade func(doc map[string]interface{}) error
+
+// upgrades[v] upgrades a version v document to version v+1. Adding a version
+// means appending its upgrade here and bumping CurrentVersion.
+var upgrades = []upgrade{
+	upgradeV0,
+	upgradeV1,
+}
+
+func init() {
+	if len(upgrades) != CurrentVersion {
+		panic(fmt.Sprintf("canvas: %d upgrades registered for version %d", len(upgrades), CurrentVersion))
+	}
+}
+
+// Upgrade brings a stored canvas up to CurrentVersion and reports whether it
+// changed. Canvases without a version are treated as version 0.
+func Upgrade(data []byte) ([]byte, bool, error) {
+	data = bytes.TrimSpace(data)
+
+	// Some early projects stored the canvas as a JSON-encoded string
+	var quoted string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &quoted); err != nil {
+			return nil, false, err
+		}
+		data = []byte(quoted)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, false, err
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+
+	version, err := documentVersion(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	if version == CurrentVersion && quoted == "" {
+		return data, false, nil
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		if err := upgrades[v](doc); err != nil {
+			return nil, false, fmt.Errorf("upgrade canvas from version %d: %w", v, err)
+		}
+		doc["version"] = v + 1
+	}
+
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, false, err
+	}
+	return out, true, nil
+}
+
+// Stamp marks a canvas coming from the frontend as CurrentVersion. Clients
+// only ever edit upgraded canvases but don't send the version back.
+func Stamp(data []byte) ([]byte, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = map[string]json.RawMessage{}
+	}
+	if _, ok := doc["version"]; ok {
+		return data, nil
+	}
+	doc["version"] = json.RawMessage(fmt.Sprint(CurrentVersion))
+	return json.Marshal(doc)
+}
+
+func documentVersion(doc map[string]interface{}) (int, error) {
+	raw, ok := doc["version"]
+	if !ok || raw == nil {
+		return 0, nil
+	}
+	v, ok := raw.(float64)
+	if !ok || v != float64(int(v)) || v < 0 {
+		return 0, fmt.Errorf("invalid canvas version %v", raw)
+	}
+	if int(v) > CurrentVersion {
+		return 0, fmt.Errorf("canvas version %d is newer than supported version %d", int(v), CurrentVersion)
+	}
+	return int(v), nil
+}
+
+// upgradeV0 normalizes unversioned canvases: nodes and edges become arrays,
+// tables named only through their label get a name, and columns get a
+// constraints array
+func upgradeV0(doc map[string]interface{}) error {
+	nodes, _ := doc["nodes"].([]interface{})
+	if nodes == nil {
+		nodes = []interface{}{}
+	}
+	doc["nodes"] = nodes
+	if edges, _ := doc["edges"].([]interface{}); edges == nil {
+		doc["edges"] = []interface{}{}
+	}
+
+	for _, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		data, ok := node["data"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if name, _ := data["name"].(string); name == "" {
+			if label, _ := data["label"].(string); label != "" {
+				data["name"] = label
+			}
+		}
+		columns, _ := data["columns"].([]interface{})
+		for _, c := range columns {
+			col, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := col["constraints"].([]interface{}); !ok {
+				col["constraints"] = []interface{}{}
+			}
+		}
+	}
+	return nil
+}
+
+// upgradeV1 marks tables that already have several primary key columns as
+// having a composite key, which is how they were always generated
+func upgradeV1(doc map[string]interface{}) error {
+	nodes, _ := doc["nodes"].([]interface{})
+	for _, n := range nodes {
+		node, ok := n.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		data, ok := node["data"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := data["compositePrimaryKey"]; ok {
+			continue
+		}
+		columns, _ := data["columns"].([]interface{})
+		primaryKeys := 0
+		for _, c := range columns {
+			if col, ok := c.(map[string]interface{}); ok && col["isPrimaryKey"] == true {
+				primaryKeys++
+			}
+		}
+		if primaryKeys > 1 {
+			data["compositePrimaryKey"] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package canvas
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Problem codes reported by Validate
+const (
+	ProblemDuplicateTable     = "duplicate_table"
+	ProblemDuplicateColumn    = "duplicate_column"
+	ProblemEmptyType          = "empty_type"
+	ProblemMultiplePrimaryKey = "multiple_primary_keys"
+	ProblemMissingNode        = "edge_missing_node"
+	ProblemMissingHandle      = "edge_missing_column"
+	ProblemDuplicateIndex     = "duplicate_index"
+	ProblemEmptyIndex         = "empty_index"
+	ProblemIndexColumn        = "index_missing_column"
+	ProblemIndexMethod        = "invalid_index_method"
+	ProblemIndexOrder         = "invalid_index_order"
+	ProblemEdgeAction         = "invalid_referential_action"
+	ProblemDefault            = "invalid_default"
+	ProblemDuplicateCheck     = "duplicate_check"
+	ProblemEmptyCheck         = "empty_check"
+	ProblemCheckColumn        = "check_missing_column"
+)
+
+var indexMethods = map[string]bool{
+	IndexMethodBTree:  true,
+	IndexMethodHash:   true,
+	IndexMethodGIN:    true,
+	IndexMethodGiST:   true,
+	IndexMethodSPGiST: true,
+	IndexMethodBRIN:   true,
+}
+
+var referentialActions = map[string]bool{
+	ActionNoAction:   true,
+	ActionRestrict:   true,
+	ActionCascade:    true,
+	ActionSetNull:    true,
+	ActionSetDefault: true,
+}
+
+// Problem is something wrong with a canvas. The IDs point the UI at the
+// node, column, index, check or edge to highlight.
+type Problem struct {
+	Code     string `json:"code"`
+	Message  string `json:"message"`
+	NodeID   string `json:"nodeId,omitempty"`
+	ColumnID string `json:"columnId,omitempty"`
+	IndexID  string `json:"indexId,omitempty"`
+	CheckID  string `json:"checkId,omitempty"`
+	EdgeID   string `json:"edgeId,omitempty"`
+}
+
+// Validate checks the canvas for problems that would make its schema
+// ambiguous or impossible to generate. Table and column names are compared
+// case-insensitively, as unquoted SQL identifiers are.
+func Validate(c *Canvas) []Problem {
+	problems := []Problem{}
+	columnsByNode := make(map[string]map[string]bool, len(c.Nodes))
+	tableNames := make(map[string]string)
+	indexNames := make(map[string]string)
+
+	for _, node := range c.Nodes {
+		columnIDs := make(map[string]bool, len(node.Data.Columns))
+		columnsByNode[node.ID] = columnIDs
+
+		if name := node.TableName(); name != "" {
+			key := strings.ToLower(name)
+			if first, ok := tableNames[key]; ok {
+				problems = append(problems, Problem{
+					Code:    ProblemDuplicateTable,
+					Message: fmt.Sprintf("Table name %q is already used by table %s", name, first),
+					NodeID:  node.ID,
+				})
+			} else {
+				tableNames[key] = node.ID
+			}
+		}
+
+		columnNames := make(map[string]bool, len(node.Data.Columns))
+		var primaryKeys []Column
+		for _, col := range node.Data.Columns {
+			columnIDs[col.ID] = true
+
+			name := strings.TrimSpace(col.Name)
+			if name == "" {
+				continue
+			}
+			key := strings.ToLower(name)
+			if columnNames[key] {
+				problems = append(problems, Problem{
+					Code:     ProblemDuplicateColumn,
+					Message:  fmt.Sprintf("Column name %q appears more than once in table %q", name, node.TableName()),
+					NodeID:   node.ID,
+					ColumnID: col.ID,
+				})
+			}
+			columnNames[key] = true
+
+			if strings.TrimSpace(col.Type) == "" {
+				problems = append(problems, Problem{
+					Code:     ProblemEmptyType,
+					Message:  fmt.Sprintf("Column %q in table %q has no type", name, node.TableName()),
+					NodeID:   node.ID,
+					ColumnID: col.ID,
+				})
+			}
+			if col.IsPrimaryKey {
+				primaryKeys = append(primaryKeys, col)
+			}
+			if message := defaultProblem(col.Default); message != "" {
+				problems = append(problems, Problem{
+					Code:     ProblemDefault,
+					Message:  fmt.Sprintf("Column %q in table %q %s", name, node.TableName(), message),
+					NodeID:   node.ID,
+					ColumnID: col.ID,
+				})
+			}
+		}
+
+		if composite := node.Data.CompositePrimaryKey; len(primaryKeys) > 1 && composite != nil && !*composite {
+			for _, col := range primaryKeys {
+				problems = append(problems, Problem{
+					Code:     ProblemMultiplePrimaryKey,
+					Message:  fmt.Sprintf("Table %q has %d primary key columns but is marked as having a single-column key", node.TableName(), len(primaryKeys)),
+					NodeID:   node.ID,
+					ColumnID: col.ID,
+				})
+			}
+		}
+
+		for _, index := range node.Data.Indexes {
+			problems = append(problems, validateIndex(node, index, columnIDs, indexNames)...)
+		}
+
+		checkNames := make(map[string]bool, len(node.Data.Checks))
+		for _, check := range node.Data.Checks {
+			problems = append(problems, validateCheck(node, check, columnIDs, columnNames, checkNames)...)
+		}
+	}
+
+	for _, edge := range c.Edges {
+		for _, pair := range edge.ColumnPairs() {
+			problems = append(problems, validateEdgeEnd(edge, edge.Source, pair.Source, columnsByNode)...)
+			problems = append(problems, validateEdgeEnd(edge, edge.Target, pair.Target, columnsByNode)...)
+		}
+		if edge.Data != nil {
+			problems = append(problems, validateEdgeAction(edge, "delete", edge.Data.OnDelete)...)
+			problems = append(problems, validateEdgeAction(edge, "update", edge.Data.OnUpdate)...)
+		}
+	}
+
+	return problems
+}
+
+func validateEdgeEnd(edge Edge, nodeID, columnID string, columnsByNode map[string]map[string]bool) []Problem {
+	columns, ok := columnsByNode[nodeID]
+	if !ok {
+		return []Problem{{
+			Code:    ProblemMissingNode,
+			Message: fmt.Sprintf("Relationship points at table %q, which doesn't exist", nodeID),
+			NodeID:  nodeID,
+			EdgeID:  edge.ID,
+		}}
+	}
+
+	if !columns[columnID] {
+		return []Problem{{
+			Code:     ProblemMissingHandle,
+			Message:  fmt.Sprintf("Relationship points at column %q, which doesn't exist in table %q", columnID, nodeID),
+			NodeID:   nodeID,
+			ColumnID: columnID,
+			EdgeID:   edge.ID,
+		}}
+	}
+	return nil
+}
+
+// defaultProblem describes what's wrong with a column default, or returns
+// empty when nothing is
+func defaultProblem(d *Default) string {
+	if d == nil {
+		return ""
+	}
+	switch d.Kind {
+	case DefaultLiteral, DefaultSequence:
+		return ""
+	case DefaultExpression:
+		if strings.TrimSpace(d.Value) == "" {
+			return "has an empty default expression"
+		}
+		return ""
+	default:
+		return fmt.Sprintf("has a default of unknown kind %q", d.Kind)
+	}
+}
+
+// validateCheck checks one of node's check constraints, including that every
+// column its expression names exists. columnNames holds the table's
+// lower-cased column names and checkNames the check names seen so far.
+func validateCheck(node Node, check Check, columnIDs, columnNames, checkNames map[string]bool) []Problem {
+	var problems []Problem
+	problem := func(code, message, columnID string) {
+		problems = append(problems, Problem{
+			Code:     code,
+			Message:  message,
+			NodeID:   node.ID,
+			ColumnID: columnID,
+			CheckID:  check.ID,
+		})
+	}
+
+	label := check.Name
+	if label == "" {
+		label = check.ID
+	}
+
+	if name := strings.TrimSpace(check.Name); name != "" {
+		key := strings.ToLower(name)
+		if checkNames[key] {
+			problem(ProblemDuplicateCheck, fmt.Sprintf("Check name %q appears more than once in table %q", name, node.TableName()), "")
+		}
+		checkNames[key] = true
+	}
+
+	if check.ColumnID != "" && !columnIDs[check.ColumnID] {
+		problem(ProblemCheckColumn, fmt.Sprintf("Check %q belongs to column %q, which doesn't exist in table %q", label, check.ColumnID, node.TableName()), check.ColumnID)
+	}
+
+	if strings.TrimSpace(check.Expression) == "" {
+		problem(ProblemEmptyCheck, fmt.Sprintf("Check %q in table %q has no expression", label, node.TableName()), "")
+		return problems
+	}
+	for _, name := range expressionColumns(check.Expression) {
+		if !columnNames[strings.ToLower(name)] {
+			problem(ProblemCheckColumn, fmt.Sprintf("Check %q refers to column %q, which doesn't exist in table %q", label, name, node.TableName()), "")
+		}
+	}
+	return problems
+}
+
+func validateEdgeAction(edge Edge, event, action string) []Problem {
+	if action == "" || referentialActions[action] {
+		return nil
+	}
+	return []Problem{{
+		Code:    ProblemEdgeAction,
+		Message: fmt.Sprintf("Relationship has unknown on %s action %q", event, action),
+		NodeID:  edge.Target,
+		EdgeID:  edge.ID,
+	}}
+}
+
+// validateIndex checks one of node's indexes. Index names share a namespace
+// across tables in PostgreSQL, so indexNames spans the whole canvas.
+func validateIndex(node Node, index Index, columnIDs map[string]bool, indexNames map[string]string) []Problem {
+	var problems []Problem
+	problem := func(code, message, columnID string) {
+		problems = append(problems, Problem{
+			Code:     code,
+			Message:  message,
+			NodeID:   node.ID,
+			ColumnID: columnID,
+			IndexID:  index.ID,
+		})
+	}
+
+	label := index.Name
+	if label == "" {
+		label = index.ID
+	}
+
+	if name := strings.TrimSpace(index.Name); name != "" {
+		key := strings.ToLower(name)
+		if first, ok := indexNames[key]; ok {
+			problem(ProblemDuplicateIndex, fmt.Sprintf("Index name %q is already used in table %s", name, first), "")
+		} else {
+			indexNames[key] = node.ID
+		}
+	}
+
+	if len(index.Columns) == 0 {
+		problem(ProblemEmptyIndex, fmt.Sprintf("Index %q in table %q has no columns", label, node.TableName()), "")
+	}
+	for _, col := range index.Columns {
+		if !columnIDs[col.ColumnID] {
+			problem(ProblemIndexColumn, fmt.Sprintf("Index %q points at column %q, which doesn't exist in table %q", label, col.ColumnID, node.TableName()), col.ColumnID)
+		}
+		if col.Order != "" && col.Order != OrderAsc && col.Order != OrderDesc {
+			problem(ProblemIndexOrder, fmt.Sprintf("Index %q has order %q; use %q or %q", label, col.Order, OrderAsc, OrderDesc), col.ColumnID)
+		}
+	}
+
+	if index.Method != "" && !indexMethods[strings.ToLower(index.Method)] {
+		problem(ProblemIndexMethod, fmt.Sprintf("Index %q uses unknown method %q", label, index.Method), "")
+	}
+	return problems
+}
@@ -0,0 +1,137 @@
+package compiler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+)
+
+// DefaultSchema is a column default. Kind is one of the canvas default kinds;
+// literal values are unquoted.
+type DefaultSchema struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value,omitempty"`
+}
+
+var numericLiteralRe = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+
+// numericTypes and booleanTypes are canonical types whose literal defaults
+// are written without quotes
+var (
+	numericTypes = map[string]bool{
+		"smallint": true, "integer": true, "bigint": true, "numeric": true,
+		"real": true, "double precision": true, "double": true,
+		"tinyint": true, "mediumint": true,
+	}
+	booleanTypes = map[string]bool{"boolean": true}
+)
+
+// timestampExpressions and uuidExpressions are the lower-cased spellings of
+// the current-time and random-UUID defaults that dialects rewrite
+var (
+	timestampExpressions = map[string]bool{
+		"now()": true, "current_timestamp": true, "current_timestamp()": true,
+		"localtimestamp": true, "transaction_timestamp()": true,
+	}
+	uuidExpressions = map[string]bool{
+		"gen_random_uuid()": true, "uuid_generate_v4()": true, "uuid()": true,
+	}
+)
+
+func normalizeExpression(expr string) string {
+	return strings.Join(strings.Fields(strings.ToLower(expr)), " ")
+}
+
+func isTimestampExpression(expr string) bool {
+	return timestampExpressions[normalizeExpression(expr)]
+}
+
+func isUUIDExpression(expr string) bool {
+	return uuidExpressions[normalizeExpression(expr)]
+}
+
+// literalSQL renders a literal default for a column of type colType. Numbers
+// and booleans go unquoted when the column holds them; anything else is a
+// quoted string.
+func literalSQL(value, colType string) string {
+	t := canonicalType(colType)
+	switch {
+	case numericTypes[t] && numericLiteralRe.MatchString(value):
+		return value
+	case booleanTypes[t] && (strings.EqualFold(value, "true") || strings.EqualFold(value, "false")):
+		return strings.ToUpper(value)
+	}
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// standardDefault renders a literal or expression default the way
+// PostgreSQL writes it. Sequence defaults have no DEFAULT clause; dialects
+// declare them through AutoIncrementClause.
+func standardDefault(col ColumnSchema) string {
+	if col.Default == nil {
+		return ""
+	}
+	switch col.Default.Kind {
+	case canvas.DefaultLiteral:
+		return literalSQL(col.Default.Value, col.Type)
+	case canvas.DefaultExpression:
+		return strings.TrimSpace(col.Default.Value)
+	}
+	return ""
+}
+
+// defaultClause is the " DEFAULT ..." suffix of a column definition, or
+// empty when the column has no default the dialect can declare
+func defaultClause(d Dialect, col ColumnSchema, colType string) string {
+	if value := d.ColumnDefault(col, colType); value != "" {
+		return " DEFAULT " + value
+	}
+	return ""
+}
+
+// prismaDefault renders a column default as a Prisma @default attribute.
+// Expressions Prisma has no function for are passed to the database with
+// dbgenerated.
+func prismaDefault(col ColumnSchema, prismaType string) string {
+	if col.AutoIncrement && (prismaType == "Int" || prismaType == "BigInt") {
+		return "@default(autoincrement())"
+	}
+	if col.Default == nil {
+		return ""
+	}
+	value := col.Default.Value
+	switch col.Default.Kind {
+	case canvas.DefaultLiteral:
+		switch {
+		case prismaType == "Boolean" && (strings.EqualFold(value, "true") || strings.EqualFold(value, "false")):
+			return fmt.Sprintf("@default(%s)", strings.ToLower(value))
+		case (prismaType == "Int" || prismaType == "BigInt" || prismaType == "Float" || prismaType == "Decimal") && numericLiteralRe.MatchString(value):
+			return fmt.Sprintf("@default(%s)", value)
+		case prismaType == "String" || prismaType == "Json":
+			return fmt.Sprintf("@default(%q)", value)
+		}
+		return fmt.Sprintf("@default(dbgenerated(%q))", literalSQL(value, col.Type))
+	case canvas.DefaultExpression:
+		if isTimestampExpression(value) {
+			return "@default(now())"
+		}
+		return fmt.Sprintf("@default(dbgenerated(%q))", strings.TrimSpace(value))
+	}
+	return ""
+}
+
+// defaultsEqual reports whether two column defaults are the same
+func defaultsEqual(a, b *DefaultSchema) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind {
+		return false
+	}
+	if a.Kind == canvas.DefaultExpression {
+		return normalizeExpression(a.Value) == normalizeExpression(b.Value)
+	}
+	return a.Kind == canvas.DefaultSequence || a.Value == b.Value
+}
@@ -0,0 +1,405 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect owns everything that differs between SQL targets: type names,
+// identifier quoting, constraint syntax and how statements are ordered.
+// New export targets are added by implementing it and calling RegisterDialect.
+type Dialect interface {
+	// Name is the canonical registry key, e.g. "postgres"
+	Name() string
+	// Preamble is emitted after the header comment, before any table
+	Preamble() string
+	QuoteIdent(name string) string
+	// ColumnType maps a canvas type to the target type. isKey reports whether
+	// the column is part of a primary key, unique constraint or relation.
+	ColumnType(col ColumnSchema, isKey bool) string
+	// ColumnDefault renders a literal or expression default as it follows
+	// DEFAULT for a column of type colType, or empty for none
+	ColumnDefault(col ColumnSchema, colType string) string
+	// AutoIncrementClause returns the clause that makes colType auto
+	// incrementing. When inlinePrimaryKey is true the clause already declares
+	// the primary key and the table-level PRIMARY KEY is omitted.
+	AutoIncrementClause(colType string) (clause string, inlinePrimaryKey bool)
+	// TableOptions is appended after the closing parenthesis of CREATE TABLE
+	TableOptions() string
+	ForeignKeyName(rel RelationSchema) string
+	// ForeignKeyActions renders the referential actions and deferrability
+	// that follow a foreign key's REFERENCES clause. Notes explain, as
+	// comments above the statement, the parts the dialect had to drop.
+	ForeignKeyActions(rel RelationSchema) (clause string, notes []string)
+	// IndexName names the index created for a foreign key's columns
+	IndexName(table string, columns []string) string
+	// CreateIndex renders CREATE INDEX for an index on table. Dialects drop,
+	// with a comment, the parts of the index they can't express.
+	CreateIndex(table string, index IndexSchema) string
+	// SupportsAlterForeignKeys reports whether foreign keys can be added with
+	// ALTER TABLE after every table exists. Dialects that can't get their
+	// foreign keys declared inside CREATE TABLE.
+	SupportsAlterForeignKeys() bool
+	// RequiresReferencedIndex reports whether the referenced side of a
+	// foreign key must be indexed before the constraint is created.
+	RequiresReferencedIndex() bool
+}
+
+var (
+	dialects     = make(map[string]Dialect)
+	dialectNames []string
+)
+
+// RegisterDialect makes d available to LookupDialect under its name and any
+// aliases.
+func RegisterDialect(d Dialect, aliases ...string) {
+	dialectNames = append(dialectNames, d.Name())
+	sort.Strings(dialectNames)
+	for _, name := range append([]string{d.Name()}, aliases...) {
+		dialects[strings.ToLower(name)] = d
+	}
+}
+
+// LookupDialect resolves a dialect by name or alias, case-insensitively
+func LookupDialect(name string) (Dialect, bool) {
+	d, ok := dialects[strings.ToLower(strings.TrimSpace(name))]
+	return d, ok
+}
+
+// DialectNames lists the canonical names of all registered dialects
+func DialectNames() []string {
+	return append([]string(nil), dialectNames...)
+}
+
+func init() {
+	RegisterDialect(PostgresDialect{}, "postgresql", "pg")
+	RegisterDialect(MySQLDialect{}, "mariadb")
+	RegisterDialect(SQLiteDialect{}, "sqlite3")
+}
+
+// DDLOptions tunes how GenerateDDLWithOptions lays out a schema
+type DDLOptions struct {
+	// InlineForeignKeys declares foreign keys inside CREATE TABLE. Only the
+	// foreign keys that close a dependency cycle are still added afterwards
+	// with ALTER TABLE. Dialects that can't alter foreign keys always inline.
+	InlineForeignKeys bool
+}
+
+// GenerateDialectSQL generates DDL for the given dialect from canvas data
+func GenerateDialectSQL(jsonData []byte, d Dialect) (string, error) {
+	return GenerateDialectSQLWithOptions(jsonData, d, DDLOptions{})
+}
+
+// GenerateDialectSQLWithOptions generates DDL for the given dialect from
+// canvas data using opts
+func GenerateDialectSQLWithOptions(jsonData []byte, d Dialect, opts DDLOptions) (string, error) {
+	schema, err := BuildSchema(jsonData)
+	if err != nil {
+		return "", err
+	}
+	return GenerateDDLWithOptions(schema, d, opts), nil
+}
+
+// GenerateDDL renders a normalized schema as DDL for the given dialect
+func GenerateDDL(schema *Schema, d Dialect) string {
+	return GenerateDDLWithOptions(schema, d, DDLOptions{})
+}
+
+// GenerateDDLWithOptions renders a normalized schema as DDL for the given
+// dialect. Tables are created in dependency order, referenced tables first.
+func GenerateDDLWithOptions(schema *Schema, d Dialect, opts DDLOptions) string {
+	canAlter := d.SupportsAlterForeignKeys()
+	inlineFKs := opts.InlineForeignKeys || !canAlter
+	order := orderTables(schema)
+
+	// afterTables reports whether relation i is added with ALTER TABLE once
+	// every table exists. Dialects that can't do that resolve references
+	// lazily, so cyclic foreign keys are declared inline there too.
+	afterTables := func(i int) bool {
+		return !inlineFKs || (order.cyclic[i] && canAlter)
+	}
+
+	keyed := make(map[string]bool)
+	fksByTable := make(map[string][]RelationSchema)
+	referencedByTable := make(map[string][]RelationSchema)
+	for i, rel := range schema.Relations {
+		for _, col := range rel.Columns {
+			keyed[strings.ToLower(rel.FromTable+"."+col.FromColumn)] = true
+			keyed[strings.ToLower(rel.ToTable+"."+col.ToColumn)] = true
+		}
+		if !afterTables(i) {
+			fksByTable[strings.ToLower(rel.ToTable)] = append(fksByTable[strings.ToLower(rel.ToTable)], rel)
+		}
+		referencedByTable[strings.ToLower(rel.FromTable)] = append(referencedByTable[strings.ToLower(rel.FromTable)], rel)
+	}
+	for _, table := range schema.Tables {
+		for _, idx := range table.Indexes {
+			for _, col := range idx.Columns {
+				keyed[strings.ToLower(table.Name+"."+col.Name)] = true
+			}
+		}
+	}
+
+	var sb strings.Builder
+	sb.WriteString("-- Generated by Skyforge\n\n")
+	if preamble := d.Preamble(); preamble != "" {
+		sb.WriteString(preamble + "\n\n")
+	}
+
+	indexes := make(map[string]struct{})
+	writeIndex := func(tableName string, index IndexSchema) {
+		key := strings.ToLower(index.Name)
+		if _, exists := indexes[key]; exists {
+			return
+		}
+		sb.WriteString(d.CreateIndex(tableName, index) + "\n\n")
+		indexes[key] = struct{}{}
+	}
+	// writeForeignKeyIndex indexes a relation's columns unless the table's
+	// primary key or one of its declared indexes already serves them
+	writeForeignKeyIndex := func(tableName string, columns []string) {
+		table := findTable(schema.Tables, tableName)
+		if table == nil || indexCovers(*table, columns) {
+			return
+		}
+		writeIndex(tableName, foreignKeyIndex(d, tableName, columns))
+	}
+
+	for _, table := range order.tables {
+		sb.WriteString(createTableStatement(d, table, keyed, fksByTable[strings.ToLower(table.Name)]) + "\n\n")
+		for _, idx := range table.Indexes {
+			writeIndex(table.Name, idx)
+		}
+
+		// The referenced side has to be indexed before any foreign key to it
+		// is declared, which for inline keys is the next CREATE TABLE
+		if d.RequiresReferencedIndex() {
+			for _, rel := range referencedByTable[strings.ToLower(table.Name)] {
+				if len(rel.Columns) == 1 {
+					if refCol := findColumn(schema.Tables, rel.FromTable, rel.Columns[0].FromColumn); refCol != nil && refCol.IsUnique {
+						continue
+					}
+				}
+				writeForeignKeyIndex(rel.FromTable, rel.FromColumns())
+			}
+		}
+	}
+
+	wroteCycleNote := false
+	for i, rel := range schema.Relations {
+		if afterTables(i) {
+			if order.cyclic[i] && inlineFKs && !wroteCycleNote {
+				sb.WriteString("-- Foreign keys that form a cycle between tables\n\n")
+				wroteCycleNote = true
+			}
+			_, notes := d.ForeignKeyActions(rel)
+			for _, note := range notes {
+				sb.WriteString("-- " + note + "\n")
+			}
+			sb.WriteString(fmt.Sprintf(
+				"ALTER TABLE %s\n  ADD CONSTRAINT %s\n  %s;\n\n",
+				d.QuoteIdent(cleanName(rel.ToTable)),
+				d.QuoteIdent(d.ForeignKeyName(rel)),
+				foreignKeyClause(d, rel),
+			))
+		}
+
+		writeForeignKeyIndex(rel.ToTable, rel.ToColumns())
+	}
+
+	return sb.String()
+}
+
+// createTableStatement renders CREATE TABLE for one table. keyed holds the
+// lower-cased "table.column" pairs that take part in relations and inlineFKs
+// the foreign keys declared inside the statement.
+func createTableStatement(d Dialect, table TableSchema, keyed map[string]bool, inlineFKs []RelationSchema) string {
+	pkCols := []string{}
+	for _, col := range table.Columns {
+		if col.IsPrimary {
+			pkCols = append(pkCols, d.QuoteIdent(cleanName(col.Name)))
+		}
+	}
+
+	inlinePK := false
+	defs := []string{}
+	for _, col := range table.Columns {
+		isKey := col.IsPrimary || col.IsUnique || keyed[strings.ToLower(table.Name+"."+col.Name)]
+		colType := d.ColumnType(col, isKey)
+		colDef := fmt.Sprintf("  %s %s", d.QuoteIdent(cleanName(col.Name)), colType)
+
+		autoClause, autoInlinePK := "", false
+		if col.AutoIncrement && col.IsPrimary && len(pkCols) == 1 {
+			autoClause, autoInlinePK = d.AutoIncrementClause(colType)
+		}
+
+		if autoInlinePK {
+			colDef += " " + autoClause
+			inlinePK = true
+		} else {
+			if col.NotNull || col.IsPrimary {
+				colDef += " NOT NULL"
+			}
+			// The sequence is an auto-increment column's default, so it
+			// can't have another
+			if autoClause != "" {
+				colDef += " " + autoClause
+			} else {
+				colDef += defaultClause(d, col, colType)
+			}
+		}
+		if col.IsUnique && !col.IsPrimary {
+			colDef += " UNIQUE"
+		}
+		defs = append(defs, colDef)
+	}
+
+	if len(pkCols) > 0 && !inlinePK {
+		defs = append(defs, fmt.Sprintf("  PRIMARY KEY (%s)", strings.Join(pkCols, ", ")))
+	}
+
+	for _, check := range table.Checks {
+		defs = append(defs, "  "+checkConstraint(d, check))
+	}
+
+	var sb strings.Builder
+	for _, rel := range inlineFKs {
+		defs = append(defs, fmt.Sprintf("  CONSTRAINT %s %s", d.QuoteIdent(d.ForeignKeyName(rel)), foreignKeyClause(d, rel)))
+		_, notes := d.ForeignKeyActions(rel)
+		for _, note := range notes {
+			sb.WriteString("-- " + note + "\n")
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", d.QuoteIdent(cleanName(table.Name))))
+	sb.WriteString(strings.Join(defs, ",\n"))
+	sb.WriteString("\n)")
+	if opts := d.TableOptions(); opts != "" {
+		sb.WriteString(" " + opts)
+	}
+	sb.WriteString(";")
+	return sb.String()
+}
+
+func foreignKeyClause(d Dialect, rel RelationSchema) string {
+	clause := fmt.Sprintf(
+		"FOREIGN KEY (%s) REFERENCES %s(%s)",
+		quoteColumns(d, rel.ToColumns()),
+		d.QuoteIdent(cleanName(rel.FromTable)),
+		quoteColumns(d, rel.FromColumns()),
+	)
+	if actions, _ := d.ForeignKeyActions(rel); actions != "" {
+		clause += " " + actions
+	}
+	return clause
+}
+
+// integerTypes are the canonical types that can be identity or
+// AUTO_INCREMENT columns
+var integerTypes = map[string]bool{
+	"smallint": true, "integer": true, "bigint": true, "tinyint": true, "mediumint": true,
+}
+
+// isIntegerType reports whether colType is an integer type, signed or not
+func isIntegerType(colType string) bool {
+	return integerTypes[strings.TrimSuffix(canonicalType(colType), " unsigned")]
+}
+
+// checkConstraint renders a check as a table constraint definition
+func checkConstraint(d Dialect, check CheckSchema) string {
+	return fmt.Sprintf("CONSTRAINT %s CHECK (%s)", d.QuoteIdent(check.Name), check.Expression)
+}
+
+// referentialClause renders ON DELETE, ON UPDATE and deferrability in the
+// standard syntax the dialects share
+func referentialClause(onDelete, onUpdate string, deferrable, initiallyDeferred bool) string {
+	var parts []string
+	if onDelete != "" {
+		parts = append(parts, "ON DELETE "+onDelete)
+	}
+	if onUpdate != "" {
+		parts = append(parts, "ON UPDATE "+onUpdate)
+	}
+	if deferrable || initiallyDeferred {
+		parts = append(parts, "DEFERRABLE")
+	}
+	if initiallyDeferred {
+		parts = append(parts, "INITIALLY DEFERRED")
+	}
+	return strings.Join(parts, " ")
+}
+
+func quoteColumns(d Dialect, columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = d.QuoteIdent(cleanName(col))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// foreignKeyIndex is the index created for a foreign key's columns
+func foreignKeyIndex(d Dialect, table string, columns []string) IndexSchema {
+	index := IndexSchema{Name: d.IndexName(table, columns)}
+	for _, col := range columns {
+		index.Columns = append(index.Columns, IndexColumnSchema{Name: col})
+	}
+	return index
+}
+
+// indexStatement holds the parts of a CREATE INDEX statement. Using is the
+// access method clause and comes out where the dialect expects it.
+type indexStatement struct {
+	Name        string
+	Table       string
+	Unique      bool
+	Columns     []string
+	UsingBefore string
+	UsingAfter  string
+	Where       string
+	// Notes are emitted as comments above the statement
+	Notes []string
+}
+
+func (s indexStatement) String() string {
+	var sb strings.Builder
+	for _, note := range s.Notes {
+		sb.WriteString("-- " + note + "\n")
+	}
+	sb.WriteString("CREATE ")
+	if s.Unique {
+		sb.WriteString("UNIQUE ")
+	}
+	sb.WriteString(fmt.Sprintf("INDEX %s ON %s", s.Name, s.Table))
+	if s.UsingBefore != "" {
+		sb.WriteString(" USING " + s.UsingBefore)
+	}
+	sb.WriteString(" (" + strings.Join(s.Columns, ", ") + ")")
+	if s.UsingAfter != "" {
+		sb.WriteString(" USING " + s.UsingAfter)
+	}
+	if s.Where != "" {
+		sb.WriteString(" WHERE " + s.Where)
+	}
+	sb.WriteString(";")
+	return sb.String()
+}
+
+// newIndexStatement fills in the parts of CREATE INDEX every built-in
+// dialect shares
+func newIndexStatement(d Dialect, name, table string, index IndexSchema) indexStatement {
+	columns := make([]string, len(index.Columns))
+	for i, col := range index.Columns {
+		columns[i] = d.QuoteIdent(cleanName(col.Name))
+		if col.Descending {
+			columns[i] += " DESC"
+		}
+	}
+	return indexStatement{
+		Name:    d.QuoteIdent(name),
+		Table:   d.QuoteIdent(cleanName(table)),
+		Unique:  index.Unique,
+		Columns: columns,
+		Where:   index.Where,
+	}
+}
@@ -0,0 +1,357 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SchemaDiff is the structured difference between two normalized schemas
+type SchemaDiff struct {
+	TablesAdded      []TableSchema    `json:"tablesAdded"`
+	TablesRemoved    []TableSchema    `json:"tablesRemoved"`
+	TablesChanged    []TableDiff      `json:"tablesChanged"`
+	RelationsAdded   []RelationSchema `json:"relationsAdded"`
+	RelationsRemoved []RelationSchema `json:"relationsRemoved"`
+}
+
+// TableDiff lists the changes of a table present in both schemas.
+// PreviousName is set when the table was renamed. An index whose definition
+// changed is listed as removed and added again.
+type TableDiff struct {
+	TableID        string         `json:"tableId"`
+	Table          string         `json:"table"`
+	PreviousName   string         `json:"previousName,omitempty"`
+	ColumnsAdded   []ColumnSchema `json:"columnsAdded"`
+	ColumnsRemoved []ColumnSchema `json:"columnsRemoved"`
+	ColumnsChanged []ColumnChange `json:"columnsChanged"`
+	IndexesAdded   []IndexSchema  `json:"indexesAdded"`
+	IndexesRemoved []IndexSchema  `json:"indexesRemoved"`
+	ChecksAdded    []CheckSchema  `json:"checksAdded"`
+	ChecksRemoved  []CheckSchema  `json:"checksRemoved"`
+}
+
+// ColumnChange describes a column present in both schemas whose definition
+// changed. Column is the new name; Before.Name holds the old one on renames.
+type ColumnChange struct {
+	ColumnID           string       `json:"columnId"`
+	Column             string       `json:"column"`
+	Before             ColumnSchema `json:"before"`
+	After              ColumnSchema `json:"after"`
+	NameChanged        bool         `json:"nameChanged"`
+	TypeChanged        bool         `json:"typeChanged"`
+	NullabilityChanged bool         `json:"nullabilityChanged"`
+	UniqueChanged      bool         `json:"uniqueChanged"`
+	PrimaryKeyChanged  bool         `json:"primaryKeyChanged"`
+	DefaultChanged     bool         `json:"defaultChanged"`
+}
+
+// HasChanges reports whether the diff contains anything at all
+func (d *SchemaDiff) HasChanges() bool {
+	return len(d.TablesAdded) > 0 || len(d.TablesRemoved) > 0 || len(d.TablesChanged) > 0 ||
+		len(d.RelationsAdded) > 0 || len(d.RelationsRemoved) > 0
+}
+
+// DiffCanvas diffs two raw canvas payloads
+func DiffCanvas(fromJSON, toJSON []byte) (*SchemaDiff, error) {
+	from, err := BuildSchema(fromJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source canvas: %w", err)
+	}
+	to, err := BuildSchema(toJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target canvas: %w", err)
+	}
+	return DiffSchemas(from, to), nil
+}
+
+// DiffSchemas compares two schemas. Tables and columns are matched by their
+// canvas IDs as well as by name, so an element whose ID survived but whose
+// name changed is reported as a rename instead of a drop and an add.
+func DiffSchemas(from, to *Schema) *SchemaDiff {
+	diff := &SchemaDiff{
+		TablesAdded:      []TableSchema{},
+		TablesRemoved:    []TableSchema{},
+		TablesChanged:    []TableDiff{},
+		RelationsAdded:   []RelationSchema{},
+		RelationsRemoved: []RelationSchema{},
+	}
+
+	tableMatch := matchElements(tableKeys(from.Tables), tableKeys(to.Tables))
+	matchedFrom := make(map[int]bool, len(tableMatch))
+
+	// renames maps old lower-cased "table" and "table.column" names to their
+	// new names so relations can be compared across renames.
+	renames := make(map[string]string)
+
+	for i, table := range to.Tables {
+		j, ok := tableMatch[i]
+		if !ok {
+			diff.TablesAdded = append(diff.TablesAdded, table)
+			continue
+		}
+		matchedFrom[j] = true
+		before := from.Tables[j]
+
+		renames[strings.ToLower(before.Name)] = table.Name
+		td := diffTable(before, table, renames)
+		if td != nil {
+			diff.TablesChanged = append(diff.TablesChanged, *td)
+		}
+	}
+
+	for j, table := range from.Tables {
+		if !matchedFrom[j] {
+			diff.TablesRemoved = append(diff.TablesRemoved, table)
+		}
+	}
+
+	fromRels := make(map[string]bool, len(from.Relations))
+	for _, rel := range from.Relations {
+		fromRels[relationKey(renameRelation(rel, renames))] = true
+	}
+	toRels := make(map[string]bool, len(to.Relations))
+	for _, rel := range to.Relations {
+		toRels[relationKey(rel)] = true
+		if !fromRels[relationKey(rel)] {
+			diff.RelationsAdded = append(diff.RelationsAdded, rel)
+		}
+	}
+	for _, rel := range from.Relations {
+		if !toRels[relationKey(renameRelation(rel, renames))] {
+			diff.RelationsRemoved = append(diff.RelationsRemoved, rel)
+		}
+	}
+
+	return diff
+}
+
+func diffTable(before, after TableSchema, renames map[string]string) *TableDiff {
+	td := TableDiff{
+		TableID:        after.ID,
+		Table:          after.Name,
+		ColumnsAdded:   []ColumnSchema{},
+		ColumnsRemoved: []ColumnSchema{},
+		ColumnsChanged: []ColumnChange{},
+		IndexesAdded:   []IndexSchema{},
+		IndexesRemoved: []IndexSchema{},
+		ChecksAdded:    []CheckSchema{},
+		ChecksRemoved:  []CheckSchema{},
+	}
+	if !strings.EqualFold(before.Name, after.Name) {
+		td.PreviousName = before.Name
+	}
+
+	columnMatch := matchElements(columnKeys(before.Columns), columnKeys(after.Columns))
+	matchedBefore := make(map[int]bool, len(columnMatch))
+
+	for i, col := range after.Columns {
+		j, ok := columnMatch[i]
+		if !ok {
+			td.ColumnsAdded = append(td.ColumnsAdded, col)
+			continue
+		}
+		matchedBefore[j] = true
+		old := before.Columns[j]
+
+		renames[strings.ToLower(before.Name+"."+old.Name)] = col.Name
+		if change := diffColumn(old, col); change != nil {
+			td.ColumnsChanged = append(td.ColumnsChanged, *change)
+		}
+	}
+
+	for j, col := range before.Columns {
+		if !matchedBefore[j] {
+			td.ColumnsRemoved = append(td.ColumnsRemoved, col)
+		}
+	}
+
+	beforeIndexes := make(map[string]bool, len(before.Indexes))
+	for _, idx := range before.Indexes {
+		beforeIndexes[indexKey(renameIndex(idx, before.Name, renames))] = true
+	}
+	afterIndexes := make(map[string]bool, len(after.Indexes))
+	for _, idx := range after.Indexes {
+		afterIndexes[indexKey(idx)] = true
+		if !beforeIndexes[indexKey(idx)] {
+			td.IndexesAdded = append(td.IndexesAdded, idx)
+		}
+	}
+	for _, idx := range before.Indexes {
+		if !afterIndexes[indexKey(renameIndex(idx, before.Name, renames))] {
+			td.IndexesRemoved = append(td.IndexesRemoved, idx)
+		}
+	}
+
+	beforeChecks := make(map[string]bool, len(before.Checks))
+	for _, check := range before.Checks {
+		beforeChecks[checkKey(check)] = true
+	}
+	afterChecks := make(map[string]bool, len(after.Checks))
+	for _, check := range after.Checks {
+		afterChecks[checkKey(check)] = true
+		if !beforeChecks[checkKey(check)] {
+			td.ChecksAdded = append(td.ChecksAdded, check)
+		}
+	}
+	for _, check := range before.Checks {
+		if !afterChecks[checkKey(check)] {
+			td.ChecksRemoved = append(td.ChecksRemoved, check)
+		}
+	}
+
+	if td.PreviousName == "" && len(td.ColumnsAdded) == 0 && len(td.ColumnsRemoved) == 0 && len(td.ColumnsChanged) == 0 &&
+		len(td.IndexesAdded) == 0 && len(td.IndexesRemoved) == 0 && len(td.ChecksAdded) == 0 && len(td.ChecksRemoved) == 0 {
+		return nil
+	}
+	return &td
+}
+
+// renameIndex rewrites an index of the old table in terms of the new column
+// names
+func renameIndex(idx IndexSchema, tableName string, renames map[string]string) IndexSchema {
+	out := idx
+	out.Columns = make([]IndexColumnSchema, len(idx.Columns))
+	for i, col := range idx.Columns {
+		out.Columns[i] = col
+		if name, ok := renames[strings.ToLower(tableName+"."+col.Name)]; ok {
+			out.Columns[i].Name = name
+		}
+	}
+	return out
+}
+
+// checkKey identifies a check by its name and expression, ignoring case and
+// spacing
+func checkKey(check CheckSchema) string {
+	return strings.ToLower(check.Name) + "|" + normalizeExpression(check.Expression)
+}
+
+// indexKey identifies an index by its whole definition
+func indexKey(idx IndexSchema) string {
+	var sb strings.Builder
+	sb.WriteString(idx.Name)
+	for _, col := range idx.Columns {
+		sb.WriteString("|" + col.Name)
+		if col.Descending {
+			sb.WriteString(" desc")
+		}
+	}
+	fmt.Fprintf(&sb, "|unique=%t|method=%s|where=%s", idx.Unique, idx.Method, idx.Where)
+	return strings.ToLower(sb.String())
+}
+
+func diffColumn(before, after ColumnSchema) *ColumnChange {
+	change := ColumnChange{
+		ColumnID:           after.ID,
+		Column:             after.Name,
+		Before:             before,
+		After:              after,
+		NameChanged:        !strings.EqualFold(before.Name, after.Name),
+		TypeChanged:        !strings.EqualFold(strings.TrimSpace(before.Type), strings.TrimSpace(after.Type)),
+		NullabilityChanged: before.NotNull != after.NotNull,
+		UniqueChanged:      before.IsUnique != after.IsUnique,
+		PrimaryKeyChanged:  before.IsPrimary != after.IsPrimary,
+		DefaultChanged:     !defaultsEqual(before.Default, after.Default),
+	}
+	if !change.NameChanged && !change.TypeChanged && !change.NullabilityChanged && !change.UniqueChanged && !change.PrimaryKeyChanged && !change.DefaultChanged {
+		return nil
+	}
+	return &change
+}
+
+// elementKey identifies a table or column for matching across versions
+type elementKey struct {
+	id   string
+	name string
+}
+
+func tableKeys(tables []TableSchema) []elementKey {
+	keys := make([]elementKey, len(tables))
+	for i, t := range tables {
+		keys[i] = elementKey{id: t.ID, name: strings.ToLower(t.Name)}
+	}
+	return keys
+}
+
+func columnKeys(columns []ColumnSchema) []elementKey {
+	keys := make([]elementKey, len(columns))
+	for i, c := range columns {
+		keys[i] = elementKey{id: c.ID, name: strings.ToLower(c.Name)}
+	}
+	return keys
+}
+
+// matchElements pairs each element of to with an element of from, returning
+// to-index -> from-index. Stable IDs decide first, so a renamed element keeps
+// its identity even when a new element takes over its old name. Names only
+// pair elements that have no ID or whose ID has no counterpart on the other
+// side.
+func matchElements(from, to []elementKey) map[int]int {
+	match := make(map[int]int)
+	used := make(map[int]bool)
+
+	pass := func(same func(a, b elementKey) bool) {
+		for i, t := range to {
+			if _, ok := match[i]; ok {
+				continue
+			}
+			for j, f := range from {
+				if !used[j] && same(f, t) {
+					match[i] = j
+					used[j] = true
+					break
+				}
+			}
+		}
+	}
+
+	fromIDs := make(map[string]bool, len(from))
+	for _, f := range from {
+		fromIDs[f.id] = f.id != ""
+	}
+	toIDs := make(map[string]bool, len(to))
+	for _, t := range to {
+		toIDs[t.id] = t.id != ""
+	}
+
+	pass(func(a, b elementKey) bool { return a.id != "" && a.id == b.id })
+	pass(func(a, b elementKey) bool { return a.name == b.name && !toIDs[a.id] && !fromIDs[b.id] })
+
+	return match
+}
+
+// renameRelation rewrites a relation from the old schema in terms of the new
+// table and column names.
+func renameRelation(rel RelationSchema, renames map[string]string) RelationSchema {
+	rename := func(key, fallback string) string {
+		if name, ok := renames[strings.ToLower(key)]; ok {
+			return name
+		}
+		return fallback
+	}
+	renamed := RelationSchema{
+		FromTable:         rename(rel.FromTable, rel.FromTable),
+		ToTable:           rename(rel.ToTable, rel.ToTable),
+		Columns:           make([]RelationColumn, len(rel.Columns)),
+		OnDelete:          rel.OnDelete,
+		OnUpdate:          rel.OnUpdate,
+		Deferrable:        rel.Deferrable,
+		InitiallyDeferred: rel.InitiallyDeferred,
+	}
+	for i, col := range rel.Columns {
+		renamed.Columns[i] = RelationColumn{
+			FromColumn: rename(rel.FromTable+"."+col.FromColumn, col.FromColumn),
+			ToColumn:   rename(rel.ToTable+"."+col.ToColumn, col.ToColumn),
+		}
+	}
+	return renamed
+}
+
+// relationKey identifies a relation by its columns and options, so changing
+// an action drops and re-adds the constraint
+func relationKey(rel RelationSchema) string {
+	return strings.ToLower(fmt.Sprintf("%s.(%s)->%s.(%s) %s",
+		rel.FromTable, strings.Join(rel.FromColumns(), ","),
+		rel.ToTable, strings.Join(rel.ToColumns(), ","),
+		referentialClause(rel.OnDelete, rel.OnUpdate, rel.Deferrable, rel.InitiallyDeferred)))
+}
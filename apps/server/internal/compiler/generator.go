@@ -1,44 +1,11 @@
 package compiler
 
 import (
-	"encoding/json"
 	"fmt"
 	"strings"
-)
-
-// Raw canvas payload (React Flow)
-type graphData struct {
-	Nodes []graphNode `json:"nodes"`
-	Edges []graphEdge `json:"edges"`
-}
-
-type graphNode struct {
-	ID   string        `json:"id"`
-	Data graphNodeData `json:"data"`
-}
-
-type graphNodeData struct {
-	Name    string       `json:"name"`
-	Label   string       `json:"label"`
-	Columns []ColumnData `json:"columns"`
-}
-
-type ColumnData struct {
-	ID           string   `json:"id"`
-	Name         string   `json:"name"`
-	Type         string   `json:"type"`
-	IsPrimaryKey bool     `json:"isPrimaryKey"`
-	IsUnique     bool     `json:"isUnique"`
-	IsNullable   bool     `json:"isNullable"`
-	Constraints  []string `json:"constraints"`
-}
 
-type graphEdge struct {
-	Source       string `json:"source"`
-	Target       string `json:"target"`
-	SourceHandle string `json:"sourceHandle"`
-	TargetHandle string `json:"targetHandle"`
-}
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+)
 
 // Normalized structures used by both standard and AI generators
 type Schema struct {
@@ -50,108 +17,124 @@ type TableSchema struct {
 	ID      string         `json:"id"`
 	Name    string         `json:"name"`
 	Columns []ColumnSchema `json:"columns"`
+	Indexes []IndexSchema  `json:"indexes,omitempty"`
+	Checks  []CheckSchema  `json:"checks,omitempty"`
 }
 
 type ColumnSchema struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Type        string `json:"type"`
-	NotNull     bool   `json:"notNull"`
-	IsUnique    bool   `json:"isUnique"`
-	IsPrimary   bool   `json:"isPrimary"`
-	DisplayType string `json:"displayType"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Type          string `json:"type"`
+	NotNull       bool   `json:"notNull"`
+	IsUnique      bool   `json:"isUnique"`
+	IsPrimary     bool   `json:"isPrimary"`
+	AutoIncrement bool   `json:"autoIncrement"`
+	DisplayType   string `json:"displayType"`
+	// Default is nil when the column has none. Sequence defaults also set
+	// AutoIncrement.
+	Default *DefaultSchema `json:"default,omitempty"`
 }
 
-type RelationSchema struct {
-	FromTable  string `json:"fromTable"`
-	FromColumn string `json:"fromColumn"`
-	ToTable    string `json:"toTable"`
-	ToColumn   string `json:"toColumn"`
+// IndexSchema is a declared index. Unnamed canvas indexes get a name derived
+// from their table and columns.
+type IndexSchema struct {
+	ID      string              `json:"id"`
+	Name    string              `json:"name"`
+	Columns []IndexColumnSchema `json:"columns"`
+	Unique  bool                `json:"unique"`
+	Where   string              `json:"where,omitempty"`
+	Method  string              `json:"method,omitempty"`
 }
 
-func GenerateSQL(jsonData []byte) (string, error) {
-	schema, err := BuildSchema(jsonData)
-	if err != nil {
-		return "", err
-	}
-
-	var sb strings.Builder
-	sb.WriteString("-- Generated by Skyforge\n\n")
-
-	for _, table := range schema.Tables {
-		tableName := cleanName(table.Name)
-		sb.WriteString(fmt.Sprintf("CREATE TABLE %s (\n", tableName))
+// CheckSchema is a CHECK constraint. Column names the column it was declared
+// on, if any. Unnamed canvas checks get PostgreSQL's default name.
+type CheckSchema struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Expression string `json:"expression"`
+	Column     string `json:"column,omitempty"`
+}
 
-		pkCols := []string{}
-		for i, col := range table.Columns {
-			colName := cleanName(col.Name)
-			colType := fallbackType(col.Type)
+type IndexColumnSchema struct {
+	Name       string `json:"name"`
+	Descending bool   `json:"descending,omitempty"`
+}
 
-			colDef := fmt.Sprintf("  %s %s", colName, colType)
-			if col.NotNull || col.IsPrimary {
-				colDef += " NOT NULL"
-			}
-			if col.IsUnique && !col.IsPrimary {
-				colDef += " UNIQUE"
-			}
+// RelationSchema is a foreign key: the "To" columns reference the "From"
+// columns, pair by pair in key order. The IDs are the canvas node IDs of
+// both ends.
+type RelationSchema struct {
+	FromTable   string           `json:"fromTable"`
+	ToTable     string           `json:"toTable"`
+	Columns     []RelationColumn `json:"columns"`
+	FromTableID string           `json:"fromTableId,omitempty"`
+	ToTableID   string           `json:"toTableId,omitempty"`
+	// OnDelete and OnUpdate are SQL referential actions such as "SET NULL".
+	// Empty leaves the database default.
+	OnDelete          string `json:"onDelete,omitempty"`
+	OnUpdate          string `json:"onUpdate,omitempty"`
+	Deferrable        bool   `json:"deferrable,omitempty"`
+	InitiallyDeferred bool   `json:"initiallyDeferred,omitempty"`
+}
 
-			if i < len(table.Columns)-1 || len(pkCols) > 0 {
-				colDef += ","
-			}
-			sb.WriteString(colDef + "\n")
+// referentialActions maps canvas referential actions to their SQL spelling
+var referentialActions = map[string]string{
+	canvas.ActionNoAction:   "NO ACTION",
+	canvas.ActionRestrict:   "RESTRICT",
+	canvas.ActionCascade:    "CASCADE",
+	canvas.ActionSetNull:    "SET NULL",
+	canvas.ActionSetDefault: "SET DEFAULT",
+}
 
-			if col.IsPrimary {
-				pkCols = append(pkCols, colName)
-			}
+// canvasAction is the canvas spelling of a SQL referential action, or empty
+// when action isn't one
+func canvasAction(action string) string {
+	action = strings.Join(strings.Fields(strings.ToUpper(action)), " ")
+	for name, sql := range referentialActions {
+		if sql == action {
+			return name
 		}
+	}
+	return ""
+}
 
-		if len(pkCols) > 0 {
-			sb.WriteString(fmt.Sprintf("  PRIMARY KEY (%s)\n", strings.Join(pkCols, ", ")))
-		}
+// RelationColumn is one column pair of a foreign key. The IDs are the canvas
+// column IDs.
+type RelationColumn struct {
+	FromColumn   string `json:"fromColumn"`
+	ToColumn     string `json:"toColumn"`
+	FromColumnID string `json:"fromColumnId,omitempty"`
+	ToColumnID   string `json:"toColumnId,omitempty"`
+}
 
-		sb.WriteString(");\n\n")
+// FromColumns lists the referenced column names in key order
+func (rel RelationSchema) FromColumns() []string {
+	names := make([]string, len(rel.Columns))
+	for i, col := range rel.Columns {
+		names[i] = col.FromColumn
 	}
+	return names
+}
 
-	indexes := make(map[string]struct{})
-	for _, rel := range schema.Relations {
-		constraint := fmt.Sprintf(
-			"fk_%s_%s_%s",
-			cleanName(rel.ToTable),
-			cleanName(rel.FromTable),
-			cleanName(rel.FromColumn),
-		)
-
-		sb.WriteString(fmt.Sprintf(
-			"ALTER TABLE %s\n  ADD CONSTRAINT %s\n  FOREIGN KEY (%s) REFERENCES %s(%s);\n\n",
-			cleanName(rel.ToTable),
-			constraint,
-			cleanName(rel.ToColumn),
-			cleanName(rel.FromTable),
-			cleanName(rel.FromColumn),
-		))
-
-		if targetCol := findColumn(schema.Tables, rel.ToTable, rel.ToColumn); targetCol != nil && !targetCol.IsPrimary {
-			idxName := fmt.Sprintf("idx_%s_%s_fk", cleanName(rel.ToTable), cleanName(rel.ToColumn))
-			if _, exists := indexes[idxName]; !exists {
-				sb.WriteString(fmt.Sprintf(
-					"CREATE INDEX %s ON %s (%s);\n\n",
-					idxName,
-					cleanName(rel.ToTable),
-					cleanName(rel.ToColumn),
-			))
-				indexes[idxName] = struct{}{}
-			}
-		}
+// ToColumns lists the referencing column names in key order
+func (rel RelationSchema) ToColumns() []string {
+	names := make([]string, len(rel.Columns))
+	for i, col := range rel.Columns {
+		names[i] = col.ToColumn
 	}
+	return names
+}
 
-	return sb.String(), nil
+// GenerateSQL generates PostgreSQL DDL from canvas data
+func GenerateSQL(jsonData []byte) (string, error) {
+	return GenerateDialectSQL(jsonData, PostgresDialect{})
 }
 
 // BuildSchema normalizes the raw canvas JSON into a deterministic structure that
 // can be reused by code and AI generators.
 func BuildSchema(jsonData []byte) (*Schema, error) {
-	var graph graphData
-	if err := json.Unmarshal(jsonData, &graph); err != nil {
+	graph, err := canvas.Decode(jsonData)
+	if err != nil {
 		return nil, err
 	}
 
@@ -164,10 +147,7 @@ func BuildSchema(jsonData []byte) (*Schema, error) {
 	columnMap := make(map[string]ColumnSchema)
 
 	for _, node := range graph.Nodes {
-		tableName := strings.TrimSpace(node.Data.Name)
-		if tableName == "" {
-			tableName = strings.TrimSpace(node.Data.Label)
-		}
+		tableName := node.TableName()
 		if tableName == "" {
 			tableName = fmt.Sprintf("table_%s", node.ID)
 		}
@@ -184,19 +164,29 @@ func BuildSchema(jsonData []byte) (*Schema, error) {
 			}
 
 			column := ColumnSchema{
-				ID:          col.ID,
-				Name:        col.Name,
-				Type:        fallbackType(col.Type),
-				NotNull:     isNotNull(col),
-				IsUnique:    col.IsUnique || hasConstraint(col, "UNQ"),
-				IsPrimary:   col.IsPrimaryKey,
-				DisplayType: displayType(col),
+				ID:            col.ID,
+				Name:          col.Name,
+				Type:          fallbackType(col.Type),
+				NotNull:       isNotNull(col),
+				IsUnique:      col.IsUnique || col.HasConstraint(canvas.ConstraintUnique),
+				IsPrimary:     col.IsPrimaryKey,
+				AutoIncrement: col.HasConstraint(canvas.ConstraintAutoIncrement) || isAutoIncrement(col.Type),
+				DisplayType:   displayType(col),
+			}
+			if col.Default != nil {
+				column.Default = &DefaultSchema{Kind: col.Default.Kind, Value: col.Default.Value}
+				if col.Default.Kind == canvas.DefaultSequence {
+					column.AutoIncrement = true
+				}
 			}
 
 			table.Columns = append(table.Columns, column)
 			columnMap[columnKey(node.ID, col.ID)] = column
 		}
 
+		table.Indexes = buildIndexes(node, table)
+		table.Checks = buildChecks(node, table)
+
 		tableMap[node.ID] = &table
 		schema.Tables = append(schema.Tables, table)
 	}
@@ -208,23 +198,200 @@ func BuildSchema(jsonData []byte) (*Schema, error) {
 			continue
 		}
 
-		sourceCol, okSourceCol := columnMap[columnKey(edge.Source, normalizeHandle(edge.SourceHandle))]
-		targetCol, okTargetCol := columnMap[columnKey(edge.Target, normalizeHandle(edge.TargetHandle))]
-		if !okSourceCol || !okTargetCol {
+		rel := RelationSchema{
+			FromTable:   sourceTable.Name,
+			ToTable:     targetTable.Name,
+			FromTableID: sourceTable.ID,
+			ToTableID:   targetTable.ID,
+		}
+		if edge.Data != nil {
+			rel.OnDelete = referentialActions[edge.Data.OnDelete]
+			rel.OnUpdate = referentialActions[edge.Data.OnUpdate]
+			rel.Deferrable = edge.Data.Deferrable || edge.Data.InitiallyDeferred
+			rel.InitiallyDeferred = edge.Data.InitiallyDeferred
+		}
+		// A key with a missing column can't be declared at all, so one
+		// dangling pair drops the whole relation
+		complete := true
+		for _, pair := range edge.ColumnPairs() {
+			sourceCol, okSourceCol := columnMap[columnKey(edge.Source, pair.Source)]
+			targetCol, okTargetCol := columnMap[columnKey(edge.Target, pair.Target)]
+			if !okSourceCol || !okTargetCol {
+				complete = false
+				break
+			}
+			rel.Columns = append(rel.Columns, RelationColumn{
+				FromColumn:   sourceCol.Name,
+				ToColumn:     targetCol.Name,
+				FromColumnID: sourceCol.ID,
+				ToColumnID:   targetCol.ID,
+			})
+		}
+		if !complete {
 			continue
 		}
 
-		schema.Relations = append(schema.Relations, RelationSchema{
-			FromTable:  sourceTable.Name,
-			FromColumn: sourceCol.Name,
-			ToTable:    targetTable.Name,
-			ToColumn:   targetCol.Name,
-		})
+		schema.Relations = append(schema.Relations, rel)
 	}
 
 	return schema, nil
 }
 
+// buildIndexes resolves node's canvas indexes against the table's columns.
+// Columns that no longer exist are dropped, and so are indexes left without
+// any column.
+func buildIndexes(node canvas.Node, table TableSchema) []IndexSchema {
+	var indexes []IndexSchema
+	for _, idx := range node.Data.Indexes {
+		index := IndexSchema{
+			ID:     idx.ID,
+			Name:   strings.TrimSpace(idx.Name),
+			Unique: idx.Unique,
+			Where:  strings.TrimSpace(idx.Where),
+			Method: strings.ToLower(strings.TrimSpace(idx.Method)),
+		}
+		for _, ic := range idx.Columns {
+			col, ok := node.Data.Column(ic.ColumnID)
+			if !ok || strings.TrimSpace(col.Name) == "" {
+				continue
+			}
+			index.Columns = append(index.Columns, IndexColumnSchema{
+				Name:       col.Name,
+				Descending: strings.EqualFold(ic.Order, canvas.OrderDesc),
+			})
+		}
+		if len(index.Columns) == 0 {
+			continue
+		}
+		if index.Name == "" {
+			index.Name = defaultIndexName(table.Name, index)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// buildChecks resolves node's check constraints. Checks without an
+// expression are dropped.
+func buildChecks(node canvas.Node, table TableSchema) []CheckSchema {
+	var checks []CheckSchema
+	used := make(map[string]bool)
+	for _, c := range node.Data.Checks {
+		if strings.TrimSpace(c.Name) != "" {
+			used[strings.ToLower(strings.TrimSpace(c.Name))] = true
+		}
+	}
+	for _, c := range node.Data.Checks {
+		check := CheckSchema{
+			ID:         c.ID,
+			Name:       strings.TrimSpace(c.Name),
+			Expression: strings.TrimSpace(c.Expression),
+		}
+		if check.Expression == "" {
+			continue
+		}
+		if col, ok := node.Data.Column(c.ColumnID); ok && strings.TrimSpace(col.Name) != "" {
+			check.Column = col.Name
+		}
+		if check.Name == "" {
+			check.Name = defaultCheckName(table.Name, check.Column, used)
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// defaultCheckName follows PostgreSQL: table_column_check for a column's
+// check, table_check otherwise, numbered when the name is taken
+func defaultCheckName(tableName, column string, used map[string]bool) string {
+	base := cleanName(tableName)
+	if column != "" {
+		base += "_" + cleanName(column)
+	}
+	base += "_check"
+	name := base
+	for n := 1; used[strings.ToLower(name)]; n++ {
+		name = fmt.Sprintf("%s%d", base, n)
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func defaultIndexName(tableName string, index IndexSchema) string {
+	prefix := "idx"
+	if index.Unique {
+		prefix = "uq"
+	}
+	parts := []string{prefix, cleanName(tableName)}
+	for _, col := range index.Columns {
+		parts = append(parts, cleanName(col.Name))
+	}
+	return strings.Join(parts, "_")
+}
+
+// ColumnNames lists the index's column names in order
+func (idx IndexSchema) ColumnNames() []string {
+	names := make([]string, len(idx.Columns))
+	for i, col := range idx.Columns {
+		names[i] = col.Name
+	}
+	return names
+}
+
+// indexCovers reports whether an index the table already has serves lookups
+// on columns: the primary key or a declared, non-partial index whose leading
+// columns are exactly those columns, in any order. A lone primary key column
+// always counts, as it did before composite keys.
+func indexCovers(table TableSchema, columns []string) bool {
+	if len(columns) == 0 {
+		return false
+	}
+	if len(columns) == 1 {
+		for _, col := range table.Columns {
+			if col.IsPrimary && strings.EqualFold(col.Name, columns[0]) {
+				return true
+			}
+		}
+	}
+	if leadingColumnsMatch(primaryKeyColumns(table), columns) {
+		return true
+	}
+	for _, idx := range table.Indexes {
+		if idx.Where == "" && leadingColumnsMatch(idx.ColumnNames(), columns) {
+			return true
+		}
+	}
+	return false
+}
+
+// leadingColumnsMatch reports whether the first len(columns) names of
+// indexed are the same set as columns
+func leadingColumnsMatch(indexed, columns []string) bool {
+	if len(indexed) < len(columns) {
+		return false
+	}
+	want := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		want[strings.ToLower(col)] = true
+	}
+	for _, col := range indexed[:len(columns)] {
+		if !want[strings.ToLower(col)] {
+			return false
+		}
+	}
+	return true
+}
+
+func primaryKeyColumns(table TableSchema) []string {
+	var names []string
+	for _, col := range table.Columns {
+		if col.IsPrimary {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
+
 func cleanName(s string) string {
 	s = strings.TrimSpace(s)
 	if s == "" {
@@ -245,40 +412,21 @@ func columnKey(nodeID, columnID string) string {
 	return fmt.Sprintf("%s:%s", nodeID, columnID)
 }
 
-func normalizeHandle(handle string) string {
-	if handle == "" {
-		return handle
-	}
-	handle = strings.TrimSuffix(handle, "-source")
-	handle = strings.TrimSuffix(handle, "-target")
-	return handle
-}
-
-func hasConstraint(col ColumnData, code string) bool {
-	code = strings.ToUpper(code)
-	for _, c := range col.Constraints {
-		if strings.EqualFold(c, code) {
-			return true
-		}
-	}
-	return false
-}
-
-func isNotNull(col ColumnData) bool {
+func isNotNull(col canvas.Column) bool {
 	if col.IsPrimaryKey {
 		return true
 	}
-	if hasConstraint(col, "NN") {
+	if col.HasConstraint(canvas.ConstraintNotNull) {
 		return true
 	}
 	return !col.IsNullable
 }
 
-func displayType(col ColumnData) string {
+func displayType(col canvas.Column) string {
 	switch {
 	case col.IsPrimaryKey:
 		return "primary_key"
-	case hasConstraint(col, "UNQ") || col.IsUnique:
+	case col.HasConstraint(canvas.ConstraintUnique) || col.IsUnique:
 		return "unique"
 	case isNotNull(col):
 		return "not_null"
@@ -324,6 +472,7 @@ func GeneratePrisma(jsonData []byte) (string, error) {
 	for _, table := range schema.Tables {
 		modelName := toPascalCase(table.Name)
 		sb.WriteString(fmt.Sprintf("model %s {\n", modelName))
+		pkCols := primaryKeyColumns(table)
 
 		for _, col := range table.Columns {
 			prismaType := sqlToPrismaType(col.Type)
@@ -333,24 +482,15 @@ func GeneratePrisma(jsonData []byte) (string, error) {
 			
 			// Add attributes
 			attrs := []string{}
-			if col.IsPrimary {
+			if col.IsPrimary && len(pkCols) == 1 {
 				attrs = append(attrs, "@id")
-				if strings.ToLower(col.Type) == "uuid" {
-					attrs = append(attrs, "@default(uuid())")
-				} else if isAutoIncrement(col.Type) {
-					attrs = append(attrs, "@default(autoincrement())")
-				}
+			}
+			if def := prismaDefault(col, prismaType); def != "" {
+				attrs = append(attrs, def)
 			}
 			if col.IsUnique && !col.IsPrimary {
 				attrs = append(attrs, "@unique")
 			}
-			if isTimestamp(col.Name, col.Type) {
-				if strings.Contains(strings.ToLower(col.Name), "created") {
-					attrs = append(attrs, "@default(now())")
-				} else if strings.Contains(strings.ToLower(col.Name), "updated") {
-					attrs = append(attrs, "@updatedAt")
-				}
-			}
 
 			if len(attrs) > 0 {
 				fieldDef += " " + strings.Join(attrs, " ")
@@ -374,12 +514,27 @@ func GeneratePrisma(jsonData []byte) (string, error) {
 				if rel.isIncoming {
 					// This table has a foreign key to another table
 					relModelName := toPascalCase(rel.relatedTable)
-					fieldName := strings.TrimSuffix(rel.localColumn, "_id")
-					if fieldName == rel.localColumn {
-						fieldName = rel.relatedTable
+					fieldName := rel.relatedTable
+					if len(rel.localColumns) == 1 {
+						if trimmed := strings.TrimSuffix(rel.localColumns[0], "_id"); trimmed != rel.localColumns[0] {
+							fieldName = trimmed
+						}
+					}
+					args := []string{
+						fmt.Sprintf("fields: [%s]", strings.Join(rel.localColumns, ", ")),
+						fmt.Sprintf("references: [%s]", strings.Join(rel.remoteColumns, ", ")),
+					}
+					if action, ok := prismaActions[rel.onDelete]; ok {
+						args = append(args, "onDelete: "+action)
 					}
-					sb.WriteString(fmt.Sprintf("  %s %s @relation(fields: [%s], references: [%s])\n", 
-						fieldName, relModelName, rel.localColumn, rel.remoteColumn))
+					if action, ok := prismaActions[rel.onUpdate]; ok {
+						args = append(args, "onUpdate: "+action)
+					}
+					if rel.deferrable {
+						sb.WriteString(fmt.Sprintf("  // The foreign key on (%s) is deferrable, which Prisma can't express; make it so in a migration\n",
+							strings.Join(rel.localColumns, ", ")))
+					}
+					sb.WriteString(fmt.Sprintf("  %s %s @relation(%s)\n", fieldName, relModelName, strings.Join(args, ", ")))
 				} else {
 					// Other tables have foreign keys pointing to this table
 					relModelName := toPascalCase(rel.relatedTable)
@@ -389,17 +544,80 @@ func GeneratePrisma(jsonData []byte) (string, error) {
 			}
 		}
 
+		if len(pkCols) > 1 || len(table.Indexes) > 0 || len(table.Checks) > 0 {
+			sb.WriteString("\n")
+			if len(pkCols) > 1 {
+				sb.WriteString(fmt.Sprintf("  @@id([%s])\n", strings.Join(pkCols, ", ")))
+			}
+			for _, idx := range table.Indexes {
+				sb.WriteString(prismaIndex(idx) + "\n")
+			}
+			// Prisma has no check constraints
+			for _, check := range table.Checks {
+				sb.WriteString(fmt.Sprintf("  // Check %s (%s) can't be expressed in Prisma; create it in a migration\n", check.Name, check.Expression))
+			}
+		}
+
 		sb.WriteString("}\n\n")
 	}
 
 	return sb.String(), nil
 }
 
+// prismaIndexTypes maps index methods to Prisma's @@index type argument
+var prismaIndexTypes = map[string]string{
+	canvas.IndexMethodHash:   "Hash",
+	canvas.IndexMethodGIN:    "Gin",
+	canvas.IndexMethodGiST:   "Gist",
+	canvas.IndexMethodSPGiST: "SpGist",
+	canvas.IndexMethodBRIN:   "Brin",
+}
+
+// prismaIndex renders an index as an @@index or @@unique attribute. Prisma
+// can't express partial indexes, or unique indexes with a non-default
+// method, so those become a comment to add them in a migration instead.
+func prismaIndex(idx IndexSchema) string {
+	fields := make([]string, len(idx.Columns))
+	for i, col := range idx.Columns {
+		fields[i] = col.Name
+		if col.Descending {
+			fields[i] += "(sort: Desc)"
+		}
+	}
+
+	indexType, hasType := prismaIndexTypes[idx.Method]
+	if idx.Where != "" || (idx.Unique && hasType) {
+		return fmt.Sprintf("  // Index %s on (%s) can't be expressed in Prisma; create it in a migration", idx.Name, strings.Join(idx.ColumnNames(), ", "))
+	}
+
+	args := []string{"[" + strings.Join(fields, ", ") + "]", fmt.Sprintf("map: %q", idx.Name)}
+	if idx.Unique {
+		return fmt.Sprintf("  @@unique(%s)", strings.Join(args, ", "))
+	}
+	if hasType {
+		args = append(args, "type: "+indexType)
+	}
+	return fmt.Sprintf("  @@index(%s)", strings.Join(args, ", "))
+}
+
+// prismaActions maps SQL referential actions to Prisma's
+var prismaActions = map[string]string{
+	"NO ACTION":   "NoAction",
+	"RESTRICT":    "Restrict",
+	"CASCADE":     "Cascade",
+	"SET NULL":    "SetNull",
+	"SET DEFAULT": "SetDefault",
+}
+
 type relationInfo struct {
-	relatedTable string
-	localColumn  string
-	remoteColumn string
-	isIncoming   bool // true if this table has the FK, false if other table has FK to this
+	relatedTable  string
+	localColumns  []string
+	remoteColumns []string
+	isIncoming    bool // true if this table has the FK, false if other table has FK to this
+	// The referential options only apply to the side holding the key
+	onDelete   string
+	onUpdate   string
+	deferrable bool
 }
 
 func buildRelationMap(schema *Schema) map[string][]relationInfo {
@@ -408,18 +626,21 @@ func buildRelationMap(schema *Schema) map[string][]relationInfo {
 	for _, rel := range schema.Relations {
 		// The "To" table has the foreign key
 		result[rel.ToTable] = append(result[rel.ToTable], relationInfo{
-			relatedTable: rel.FromTable,
-			localColumn:  rel.ToColumn,
-			remoteColumn: rel.FromColumn,
-			isIncoming:   true,
+			relatedTable:  rel.FromTable,
+			localColumns:  rel.ToColumns(),
+			remoteColumns: rel.FromColumns(),
+			isIncoming:    true,
+			onDelete:      rel.OnDelete,
+			onUpdate:      rel.OnUpdate,
+			deferrable:    rel.Deferrable,
 		})
 
 		// The "From" table is referenced
 		result[rel.FromTable] = append(result[rel.FromTable], relationInfo{
-			relatedTable: rel.ToTable,
-			localColumn:  rel.FromColumn,
-			remoteColumn: rel.ToColumn,
-			isIncoming:   false,
+			relatedTable:  rel.ToTable,
+			localColumns:  rel.FromColumns(),
+			remoteColumns: rel.ToColumns(),
+			isIncoming:    false,
 		})
 	}
 
@@ -480,10 +701,3 @@ func isAutoIncrement(sqlType string) bool {
 	return sqlType == "serial" || sqlType == "bigserial" || sqlType == "smallserial"
 }
 
-func isTimestamp(colName, colType string) bool {
-	colType = strings.ToLower(colType)
-	colName = strings.ToLower(colName)
-	return strings.Contains(colType, "timestamp") || 
-		strings.Contains(colName, "created_at") || 
-		strings.Contains(colName, "updated_at")
-}
@@ -0,0 +1,406 @@
+package compiler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// LintSeverity ranks how serious a lint finding is
+type LintSeverity string
+
+const (
+	LintError   LintSeverity = "error"
+	LintWarning LintSeverity = "warning"
+	LintInfo    LintSeverity = "info"
+)
+
+// LintRule is one schema check. Rules are enabled unless a project turns
+// them off.
+type LintRule struct {
+	ID          string       `json:"id"`
+	Severity    LintSeverity `json:"severity"`
+	Description string       `json:"description"`
+
+	check func(schema *Schema) []LintResult
+}
+
+// LintResult is a single finding. NodeID and ColumnID are the canvas IDs of
+// the table and column it is about; ColumnID is empty for table findings.
+type LintResult struct {
+	RuleID   string       `json:"ruleId"`
+	Severity LintSeverity `json:"severity"`
+	Message  string       `json:"message"`
+	NodeID   string       `json:"nodeId"`
+	ColumnID string       `json:"columnId,omitempty"`
+}
+
+var lintRules = []LintRule{
+	{
+		ID:          "no-primary-key",
+		Severity:    LintWarning,
+		Description: "Tables should have a primary key",
+		check:       lintNoPrimaryKey,
+	},
+	{
+		ID:          "fk-type-mismatch",
+		Severity:    LintError,
+		Description: "Foreign key columns should have the same type as the column they reference",
+		check:       lintForeignKeyTypeMismatch,
+	},
+	{
+		ID:          "fk-missing-index",
+		Severity:    LintInfo,
+		Description: "Foreign key columns should be indexed",
+		check:       lintForeignKeyMissingIndex,
+	},
+	{
+		ID:          "reserved-word",
+		Severity:    LintWarning,
+		Description: "Table and column names should not be SQL reserved words",
+		check:       lintReservedWords,
+	},
+	{
+		ID:          "mixed-naming",
+		Severity:    LintWarning,
+		Description: "Names should consistently use either snake_case or camelCase",
+		check:       lintMixedNaming,
+	},
+	{
+		ID:          "nullable-unique",
+		Severity:    LintWarning,
+		Description: "Unique columns should be NOT NULL, since NULLs never conflict",
+		check:       lintNullableUnique,
+	},
+	{
+		ID:          "isolated-table",
+		Severity:    LintInfo,
+		Description: "Tables should be related to at least one other table",
+		check:       lintIsolatedTables,
+	},
+}
+
+// LintRules lists every lint rule in the order Lint runs them
+func LintRules() []LintRule {
+	return append([]LintRule(nil), lintRules...)
+}
+
+// IsLintRule reports whether id names a lint rule
+func IsLintRule(id string) bool {
+	for _, rule := range lintRules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Lint runs the lint rules against schema. enabled overrides whether a rule
+// runs; rules missing from it are enabled.
+func Lint(schema *Schema, enabled map[string]bool) []LintResult {
+	results := []LintResult{}
+	for _, rule := range lintRules {
+		if on, ok := enabled[rule.ID]; ok && !on {
+			continue
+		}
+		for _, result := range rule.check(schema) {
+			result.RuleID = rule.ID
+			result.Severity = rule.Severity
+			results = append(results, result)
+		}
+	}
+	return results
+}
+
+func lintNoPrimaryKey(schema *Schema) []LintResult {
+	var results []LintResult
+	for _, table := range schema.Tables {
+		hasPrimary := false
+		for _, col := range table.Columns {
+			if col.IsPrimary {
+				hasPrimary = true
+				break
+			}
+		}
+		if !hasPrimary {
+			results = append(results, LintResult{
+				Message: fmt.Sprintf("Table %q has no primary key", table.Name),
+				NodeID:  table.ID,
+			})
+		}
+	}
+	return results
+}
+
+func lintForeignKeyTypeMismatch(schema *Schema) []LintResult {
+	var results []LintResult
+	for _, rel := range schema.Relations {
+		for _, col := range rel.Columns {
+			fkCol := findColumn(schema.Tables, rel.ToTable, col.ToColumn)
+			refCol := findColumn(schema.Tables, rel.FromTable, col.FromColumn)
+			if fkCol == nil || refCol == nil {
+				continue
+			}
+			if canonicalType(fkCol.Type) == canonicalType(refCol.Type) {
+				continue
+			}
+			results = append(results, LintResult{
+				Message: fmt.Sprintf("%s.%s is %s but references %s.%s, which is %s",
+					rel.ToTable, col.ToColumn, fkCol.Type, rel.FromTable, col.FromColumn, refCol.Type),
+				NodeID:   rel.ToTableID,
+				ColumnID: col.ToColumnID,
+			})
+		}
+	}
+	return results
+}
+
+func lintForeignKeyMissingIndex(schema *Schema) []LintResult {
+	var results []LintResult
+	seen := make(map[string]bool)
+	for _, rel := range schema.Relations {
+		table := findTable(schema.Tables, rel.ToTable)
+		if table == nil || indexCovers(*table, rel.ToColumns()) {
+			continue
+		}
+		if len(rel.Columns) == 1 {
+			if fkCol := findColumn(schema.Tables, rel.ToTable, rel.Columns[0].ToColumn); fkCol == nil || fkCol.IsUnique {
+				continue
+			}
+		}
+		key := strings.ToLower(rel.ToTable + "." + strings.Join(rel.ToColumns(), ","))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		message := fmt.Sprintf("Foreign key column %s.%s has no index", rel.ToTable, rel.Columns[0].ToColumn)
+		if len(rel.Columns) > 1 {
+			message = fmt.Sprintf("Foreign key columns %s.(%s) have no index", rel.ToTable, strings.Join(rel.ToColumns(), ", "))
+		}
+		results = append(results, LintResult{
+			Message:  message,
+			NodeID:   rel.ToTableID,
+			ColumnID: rel.Columns[0].ToColumnID,
+		})
+	}
+	return results
+}
+
+// reservedWords are the PostgreSQL reserved key words, plus the few that
+// MySQL and SQLite also reserve and are common as column names
+var reservedWords = map[string]bool{
+	"all": true, "analyse": true, "analyze": true, "and": true, "any": true,
+	"array": true, "as": true, "asc": true, "asymmetric": true,
+	"authorization": true, "binary": true, "both": true, "case": true,
+	"cast": true, "check": true, "collate": true, "collation": true,
+	"column": true, "concurrently": true, "constraint": true, "create": true,
+	"cross": true, "current_catalog": true, "current_date": true,
+	"current_role": true, "current_schema": true, "current_time": true,
+	"current_timestamp": true, "current_user": true, "default": true,
+	"deferrable": true, "desc": true, "distinct": true, "do": true,
+	"else": true, "end": true, "except": true, "false": true, "fetch": true,
+	"for": true, "foreign": true, "freeze": true, "from": true, "full": true,
+	"grant": true, "group": true, "having": true, "ilike": true, "in": true,
+	"index": true, "initially": true, "inner": true, "intersect": true,
+	"into": true, "is": true, "isnull": true, "join": true, "key": true,
+	"lateral": true, "leading": true, "left": true, "like": true,
+	"limit": true, "localtime": true, "localtimestamp": true,
+	"natural": true, "not": true, "notnull": true, "null": true,
+	"offset": true, "on": true, "only": true, "or": true, "order": true,
+	"outer": true, "overlaps": true, "placing": true, "primary": true,
+	"references": true, "returning": true, "right": true, "select": true,
+	"session_user": true, "similar": true, "some": true, "symmetric": true,
+	"table": true, "tablesample": true, "then": true, "to": true,
+	"trailing": true, "true": true, "union": true, "unique": true,
+	"user": true, "using": true, "variadic": true, "verbose": true,
+	"when": true, "where": true, "window": true, "with": true,
+}
+
+func lintReservedWords(schema *Schema) []LintResult {
+	var results []LintResult
+	for _, table := range schema.Tables {
+		if reservedWords[strings.ToLower(table.Name)] {
+			results = append(results, LintResult{
+				Message: fmt.Sprintf("Table name %q is a reserved word", table.Name),
+				NodeID:  table.ID,
+			})
+		}
+		for _, col := range table.Columns {
+			if reservedWords[strings.ToLower(col.Name)] {
+				results = append(results, LintResult{
+					Message:  fmt.Sprintf("Column name %s.%s is a reserved word", table.Name, col.Name),
+					NodeID:   table.ID,
+					ColumnID: col.ID,
+				})
+			}
+		}
+	}
+	return results
+}
+
+type namingStyle int
+
+const (
+	namingNeutral namingStyle = iota
+	namingSnake
+	namingCamel
+)
+
+func (s namingStyle) String() string {
+	if s == namingCamel {
+		return "camelCase"
+	}
+	return "snake_case"
+}
+
+var (
+	snakeCasePattern = regexp.MustCompile(`^[a-z0-9]+(_[a-z0-9]+)+$`)
+	camelCasePattern = regexp.MustCompile(`^[A-Za-z][a-z0-9]*([A-Z][a-z0-9]*)+$`)
+
+	typeModifierPattern = regexp.MustCompile(`\([^)]*\)`)
+)
+
+// identifierStyle classifies a name. Single lower-case words fit both styles
+// and are neutral.
+func identifierStyle(name string) namingStyle {
+	switch {
+	case snakeCasePattern.MatchString(name):
+		return namingSnake
+	case camelCasePattern.MatchString(name):
+		return namingCamel
+	default:
+		return namingNeutral
+	}
+}
+
+// lintMixedNaming flags the names that don't follow the schema's dominant
+// naming style
+func lintMixedNaming(schema *Schema) []LintResult {
+	counts := make(map[namingStyle]int)
+	for _, table := range schema.Tables {
+		counts[identifierStyle(table.Name)]++
+		for _, col := range table.Columns {
+			counts[identifierStyle(col.Name)]++
+		}
+	}
+	if counts[namingSnake] == 0 || counts[namingCamel] == 0 {
+		return nil
+	}
+
+	dominant, minority := namingSnake, namingCamel
+	if counts[namingCamel] > counts[namingSnake] {
+		dominant, minority = namingCamel, namingSnake
+	}
+
+	var results []LintResult
+	for _, table := range schema.Tables {
+		if identifierStyle(table.Name) == minority {
+			results = append(results, LintResult{
+				Message: fmt.Sprintf("Table name %q uses %s but most names use %s", table.Name, minority, dominant),
+				NodeID:  table.ID,
+			})
+		}
+		for _, col := range table.Columns {
+			if identifierStyle(col.Name) == minority {
+				results = append(results, LintResult{
+					Message:  fmt.Sprintf("Column name %s.%s uses %s but most names use %s", table.Name, col.Name, minority, dominant),
+					NodeID:   table.ID,
+					ColumnID: col.ID,
+				})
+			}
+		}
+	}
+	return results
+}
+
+func lintNullableUnique(schema *Schema) []LintResult {
+	var results []LintResult
+	for _, table := range schema.Tables {
+		for _, col := range table.Columns {
+			if col.IsUnique && !col.IsPrimary && !col.NotNull {
+				results = append(results, LintResult{
+					Message:  fmt.Sprintf("Unique column %s.%s is nullable", table.Name, col.Name),
+					NodeID:   table.ID,
+					ColumnID: col.ID,
+				})
+			}
+		}
+		for _, idx := range table.Indexes {
+			if !idx.Unique {
+				continue
+			}
+			for _, ic := range idx.Columns {
+				col := findColumn(schema.Tables, table.Name, ic.Name)
+				if col == nil || col.IsPrimary || col.NotNull {
+					continue
+				}
+				results = append(results, LintResult{
+					Message:  fmt.Sprintf("Column %s.%s in unique index %s is nullable", table.Name, col.Name, idx.Name),
+					NodeID:   table.ID,
+					ColumnID: col.ID,
+				})
+			}
+		}
+	}
+	return results
+}
+
+// lintIsolatedTables flags tables without relations. A schema with a single
+// table has nothing to relate it to, so it's left alone.
+func lintIsolatedTables(schema *Schema) []LintResult {
+	if len(schema.Tables) < 2 {
+		return nil
+	}
+
+	related := make(map[string]bool)
+	for _, rel := range schema.Relations {
+		related[strings.ToLower(rel.FromTable)] = true
+		related[strings.ToLower(rel.ToTable)] = true
+	}
+
+	var results []LintResult
+	for _, table := range schema.Tables {
+		if !related[strings.ToLower(table.Name)] {
+			results = append(results, LintResult{
+				Message: fmt.Sprintf("Table %q has no relations", table.Name),
+				NodeID:  table.ID,
+			})
+		}
+	}
+	return results
+}
+
+// typeAliases maps type spellings to one canonical name so equivalent types
+// compare equal. Serial types compare equal to the integer they produce.
+var typeAliases = map[string]string{
+	"int":                         "integer",
+	"int4":                        "integer",
+	"serial":                      "integer",
+	"serial4":                     "integer",
+	"int8":                        "bigint",
+	"bigserial":                   "bigint",
+	"serial8":                     "bigint",
+	"int2":                        "smallint",
+	"smallserial":                 "smallint",
+	"serial2":                     "smallint",
+	"bool":                        "boolean",
+	"character varying":           "varchar",
+	"character":                   "char",
+	"float4":                      "real",
+	"float8":                      "double precision",
+	"float":                       "double precision",
+	"decimal":                     "numeric",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+}
+
+// canonicalType normalizes a column type for comparison. Length and
+// precision modifiers are ignored.
+func canonicalType(t string) string {
+	t = typeModifierPattern.ReplaceAllString(strings.ToLower(t), "")
+	t = strings.Join(strings.Fields(t), " ")
+	if alias, ok := typeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
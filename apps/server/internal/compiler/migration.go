@@ -0,0 +1,572 @@
+package compiler
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Migration is an ordered set of PostgreSQL statements that move a database
+// from one schema to another, plus the statements that undo it.
+type Migration struct {
+	Up       []string           `json:"up"`
+	Down     []string           `json:"down"`
+	Warnings []MigrationWarning `json:"warnings"`
+}
+
+// MigrationWarning flags an operation in the up migration that can lose data
+// or fail against existing rows.
+type MigrationWarning struct {
+	Table   string `json:"table"`
+	Column  string `json:"column,omitempty"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Warning kinds reported by GenerateMigration
+const (
+	WarningDropTable        = "drop_table"
+	WarningDropColumn       = "drop_column"
+	WarningNarrowType       = "narrow_type"
+	WarningNotNullNoDefault = "not_null_without_default"
+)
+
+// GenerateMigration builds the up and down migration between two schemas.
+// The down migration is the up migration of the reversed diff.
+func GenerateMigration(from, to *Schema) *Migration {
+	up, warnings := migrationStatements(from, to)
+	down, _ := migrationStatements(to, from)
+	return &Migration{
+		Up:       up,
+		Down:     down,
+		Warnings: warnings,
+	}
+}
+
+// GenerateCanvasMigration builds a migration between two raw canvas payloads
+func GenerateCanvasMigration(fromJSON, toJSON []byte) (*Migration, error) {
+	from, err := BuildSchema(fromJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source canvas: %w", err)
+	}
+	to, err := BuildSchema(toJSON)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target canvas: %w", err)
+	}
+	return GenerateMigration(from, to), nil
+}
+
+// Goose renders the migration as a single goose migration file. Statements
+// are left as plain ;-terminated SQL, so goose runs them one at a time and
+// reports the one that fails.
+func (m *Migration) Goose() string {
+	var sb strings.Builder
+	sb.WriteString("-- Generated by Skyforge\n")
+	writeWarningComments(&sb, m.Warnings)
+	sb.WriteString("\n-- +goose Up\n")
+	sb.WriteString(joinStatements(gooseStatements(m.Up)))
+	sb.WriteString("\n-- +goose Down\n")
+	sb.WriteString(joinStatements(gooseStatements(m.Down)))
+	return sb.String()
+}
+
+// gooseStatements wraps statements with a dollar-quoted body, such as a
+// function, in StatementBegin/End: goose would otherwise split them at the
+// semicolons inside the body
+func gooseStatements(stmts []string) []string {
+	wrapped := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		if strings.Contains(stmt, "$$") {
+			stmt = "-- +goose StatementBegin\n" + stmt + "\n-- +goose StatementEnd"
+		}
+		wrapped[i] = stmt
+	}
+	return wrapped
+}
+
+// UpSQL renders the up migration as a plain SQL file
+func (m *Migration) UpSQL() string {
+	var sb strings.Builder
+	sb.WriteString("-- Generated by Skyforge\n")
+	writeWarningComments(&sb, m.Warnings)
+	sb.WriteString("\n")
+	sb.WriteString(joinStatements(m.Up))
+	return sb.String()
+}
+
+// DownSQL renders the down migration as a plain SQL file
+func (m *Migration) DownSQL() string {
+	return "-- Generated by Skyforge\n\n" + joinStatements(m.Down)
+}
+
+func writeWarningComments(sb *strings.Builder, warnings []MigrationWarning) {
+	for _, w := range warnings {
+		sb.WriteString("-- WARNING: " + w.Message + "\n")
+	}
+}
+
+func joinStatements(stmts []string) string {
+	if len(stmts) == 0 {
+		return "-- no changes\n"
+	}
+	return strings.Join(stmts, "\n\n") + "\n"
+}
+
+// migrationStatements orders the statements so every step is valid on its
+// own: foreign keys are dropped first and added last, new tables exist before
+// columns change, and removed tables go after everything that pointed at them.
+// Foreign keys referencing a primary key that is rebuilt depend on it, so
+// they're dropped and recreated around it.
+func migrationStatements(from, to *Schema) ([]string, []MigrationWarning) {
+	d := PostgresDialect{}
+	diff := DiffSchemas(from, to)
+	stmts := []string{}
+	warnings := []MigrationWarning{}
+
+	keyed := make(map[string]bool)
+	for _, rel := range to.Relations {
+		for _, col := range rel.Columns {
+			keyed[strings.ToLower(rel.FromTable+"."+col.FromColumn)] = true
+			keyed[strings.ToLower(rel.ToTable+"."+col.ToColumn)] = true
+		}
+	}
+
+	// Relations kept across the diff, keyed by their new form
+	renamedRelations := make(map[string]RelationSchema, len(from.Relations))
+	for _, rel := range from.Relations {
+		renamedRelations[relationKey(renameRelation(rel, diffRenames(diff)))] = rel
+	}
+
+	// Tables whose primary key is dropped and re-added, by their old name
+	pkRebuilt := make(map[string]*TableSchema)
+	for _, td := range diff.TablesChanged {
+		if before := findTable(from.Tables, previousTableName(td)); before != nil && hasPrimaryKey(*before) && primaryKeyChanged(td) {
+			pkRebuilt[strings.ToLower(before.Name)] = before
+		}
+	}
+	rebuiltRelations := make(map[string]bool)
+	for _, rel := range to.Relations {
+		old, ok := renamedRelations[relationKey(rel)]
+		if !ok {
+			continue
+		}
+		if before := pkRebuilt[strings.ToLower(old.FromTable)]; before != nil && referencesPrimaryKey(*before, old.FromColumns()) {
+			rebuiltRelations[relationKey(rel)] = true
+			stmts = append(stmts, dropForeignKeyStatement(d, old))
+		}
+	}
+
+	for _, rel := range diff.RelationsRemoved {
+		stmts = append(stmts, dropForeignKeyStatement(d, rel))
+		stmts = append(stmts, fmt.Sprintf("DROP INDEX IF EXISTS %s;", d.QuoteIdent(d.IndexName(rel.ToTable, rel.ToColumns()))))
+	}
+
+	// Renames go before anything else touches the tables, so a new table may
+	// reuse a name that was just vacated. PostgreSQL doesn't rename the
+	// constraints and indexes named after a table, so those follow along.
+	for _, td := range diff.TablesChanged {
+		if td.PreviousName == "" {
+			continue
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s RENAME TO %s;", d.QuoteIdent(cleanName(td.PreviousName)), d.QuoteIdent(cleanName(td.Table))))
+		if before := findTable(from.Tables, td.PreviousName); before != nil && hasPrimaryKey(*before) {
+			stmts = append(stmts, renameConstraintStatement(d, td.Table, primaryKeyName(td.PreviousName), primaryKeyName(td.Table)))
+		}
+	}
+	for _, td := range diff.TablesChanged {
+		stmts = append(stmts, renameColumnStatements(d, td, findTable(from.Tables, previousTableName(td)), findTable(to.Tables, td.Table))...)
+	}
+
+	for _, rel := range to.Relations {
+		old, ok := renamedRelations[relationKey(rel)]
+		if !ok {
+			continue
+		}
+		if oldName, newName := d.ForeignKeyName(old), d.ForeignKeyName(rel); oldName != newName && !rebuiltRelations[relationKey(rel)] {
+			stmts = append(stmts, renameConstraintStatement(d, rel.ToTable, oldName, newName))
+		}
+		if oldName, newName := d.IndexName(old.ToTable, old.ToColumns()), d.IndexName(rel.ToTable, rel.ToColumns()); oldName != newName {
+			stmts = append(stmts, fmt.Sprintf("ALTER INDEX IF EXISTS %s RENAME TO %s;", d.QuoteIdent(oldName), d.QuoteIdent(newName)))
+		}
+	}
+
+	for _, td := range diff.TablesChanged {
+		for _, idx := range td.IndexesRemoved {
+			stmts = append(stmts, fmt.Sprintf("DROP INDEX IF EXISTS %s;", d.QuoteIdent(idx.Name)))
+		}
+		for _, check := range td.ChecksRemoved {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", d.QuoteIdent(cleanName(td.Table)), d.QuoteIdent(check.Name)))
+		}
+	}
+
+	for _, table := range diff.TablesAdded {
+		stmts = append(stmts, createTableStatement(d, table, keyed, nil))
+		for _, idx := range table.Indexes {
+			stmts = append(stmts, d.CreateIndex(table.Name, idx))
+		}
+	}
+
+	for _, td := range diff.TablesChanged {
+		tableStmts, tableWarnings := alterTableStatements(d, td, findTable(from.Tables, previousTableName(td)), findTable(to.Tables, td.Table))
+		stmts = append(stmts, tableStmts...)
+		warnings = append(warnings, tableWarnings...)
+		for _, idx := range td.IndexesAdded {
+			stmts = append(stmts, d.CreateIndex(td.Table, idx))
+		}
+		for _, check := range td.ChecksAdded {
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD %s;", d.QuoteIdent(cleanName(td.Table)), checkConstraint(d, check)))
+		}
+	}
+
+	for _, table := range diff.TablesRemoved {
+		stmts = append(stmts, fmt.Sprintf("DROP TABLE IF EXISTS %s;", d.QuoteIdent(cleanName(table.Name))))
+		warnings = append(warnings, MigrationWarning{
+			Table:   table.Name,
+			Kind:    WarningDropTable,
+			Message: fmt.Sprintf("dropping table %s deletes all of its rows", table.Name),
+		})
+	}
+
+	for _, rel := range to.Relations {
+		if rebuiltRelations[relationKey(rel)] {
+			stmts = append(stmts, addForeignKeyStatement(d, rel))
+		}
+	}
+	for _, rel := range diff.RelationsAdded {
+		stmts = append(stmts, addForeignKeyStatement(d, rel))
+		if table := findTable(to.Tables, rel.ToTable); table != nil && !indexCovers(*table, rel.ToColumns()) {
+			stmts = append(stmts, d.CreateIndex(rel.ToTable, foreignKeyIndex(d, rel.ToTable, rel.ToColumns())))
+		}
+	}
+
+	return stmts, warnings
+}
+
+func alterTableStatements(d Dialect, td TableDiff, before, after *TableSchema) ([]string, []MigrationWarning) {
+	stmts := []string{}
+	warnings := []MigrationWarning{}
+	table := d.QuoteIdent(cleanName(td.Table))
+
+	pkChanged := primaryKeyChanged(td)
+	if pkChanged && before != nil && hasPrimaryKey(*before) {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", table, d.QuoteIdent(primaryKeyName(td.Table))))
+	}
+
+	for _, col := range td.ColumnsAdded {
+		colType := d.ColumnType(col, col.IsPrimary || col.IsUnique)
+		def := fmt.Sprintf("%s %s", d.QuoteIdent(cleanName(col.Name)), colType)
+		if col.NotNull || col.IsPrimary {
+			def += " NOT NULL"
+			if !col.AutoIncrement && col.Default == nil {
+				warnings = append(warnings, MigrationWarning{
+					Table:   td.Table,
+					Column:  col.Name,
+					Kind:    WarningNotNullNoDefault,
+					Message: fmt.Sprintf("adding NOT NULL column %s.%s without a default fails if the table has rows", td.Table, col.Name),
+				})
+			}
+		}
+		// As in CREATE TABLE, an auto-increment column has no other default
+		autoClause := ""
+		if col.AutoIncrement {
+			autoClause, _ = d.AutoIncrementClause(colType)
+		}
+		if autoClause != "" {
+			def += " " + autoClause
+		} else {
+			def += defaultClause(d, col, colType)
+		}
+		if col.IsUnique && !col.IsPrimary {
+			def += " UNIQUE"
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table, def))
+	}
+
+	for _, change := range td.ColumnsChanged {
+		column := d.QuoteIdent(cleanName(change.Column))
+
+		if change.TypeChanged {
+			newType := columnStorageType(d.ColumnType(change.After, false))
+			stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s USING %s::%s;", table, column, newType, column, newType))
+			if isNarrowingType(change.Before.Type, change.After.Type) {
+				warnings = append(warnings, MigrationWarning{
+					Table:   td.Table,
+					Column:  change.Column,
+					Kind:    WarningNarrowType,
+					Message: fmt.Sprintf("changing %s.%s from %s to %s can truncate or reject existing values", td.Table, change.Column, change.Before.Type, change.After.Type),
+				})
+			}
+		}
+
+		if change.NullabilityChanged {
+			if change.After.NotNull {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET NOT NULL;", table, column))
+				warnings = append(warnings, MigrationWarning{
+					Table:   td.Table,
+					Column:  change.Column,
+					Kind:    WarningNotNullNoDefault,
+					Message: fmt.Sprintf("setting %s.%s NOT NULL fails if existing rows contain NULL", td.Table, change.Column),
+				})
+			} else if !change.After.IsPrimary {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP NOT NULL;", table, column))
+			}
+		}
+
+		// Sequence defaults belong to the column's identity, which this
+		// doesn't convert; literal and expression defaults are swapped in place
+		if change.DefaultChanged && !change.After.AutoIncrement && !change.Before.AutoIncrement {
+			if value := d.ColumnDefault(change.After, d.ColumnType(change.After, false)); value != "" {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s SET DEFAULT %s;", table, column, value))
+			} else {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s DROP DEFAULT;", table, column))
+			}
+		}
+
+		if change.UniqueChanged {
+			constraint := d.QuoteIdent(uniqueConstraintName(td.Table, change.Column))
+			if change.After.IsUnique {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s UNIQUE (%s);", table, constraint, column))
+			} else {
+				stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;", table, constraint))
+			}
+		}
+	}
+
+	for _, col := range td.ColumnsRemoved {
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s DROP COLUMN IF EXISTS %s;", table, d.QuoteIdent(cleanName(col.Name))))
+		warnings = append(warnings, MigrationWarning{
+			Table:   td.Table,
+			Column:  col.Name,
+			Kind:    WarningDropColumn,
+			Message: fmt.Sprintf("dropping column %s.%s deletes its data", td.Table, col.Name),
+		})
+	}
+
+	if pkChanged && after != nil && hasPrimaryKey(*after) {
+		pkCols := []string{}
+		for _, col := range after.Columns {
+			if col.IsPrimary {
+				pkCols = append(pkCols, d.QuoteIdent(cleanName(col.Name)))
+			}
+		}
+		stmts = append(stmts, fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT %s PRIMARY KEY (%s);", table, d.QuoteIdent(primaryKeyName(td.Table)), strings.Join(pkCols, ", ")))
+	}
+
+	return stmts, warnings
+}
+
+// renameColumnStatements renames the columns of a table whose IDs survived a
+// name change, along with the unique constraints named after them.
+func renameColumnStatements(d Dialect, td TableDiff, before, after *TableSchema) []string {
+	stmts := []string{}
+	table := d.QuoteIdent(cleanName(td.Table))
+
+	oldNames := make(map[string]string)
+	for _, change := range td.ColumnsChanged {
+		if !change.NameChanged {
+			continue
+		}
+		oldNames[strings.ToLower(change.Column)] = change.Before.Name
+		stmts = append(stmts, fmt.Sprintf(
+			"ALTER TABLE %s RENAME COLUMN %s TO %s;",
+			table,
+			d.QuoteIdent(cleanName(change.Before.Name)),
+			d.QuoteIdent(cleanName(change.Column)),
+		))
+	}
+
+	if before == nil || after == nil {
+		return stmts
+	}
+	for _, col := range after.Columns {
+		if !col.IsUnique || col.IsPrimary {
+			continue
+		}
+		oldName := col.Name
+		if name, ok := oldNames[strings.ToLower(col.Name)]; ok {
+			oldName = name
+		}
+		old := findColumn([]TableSchema{*before}, before.Name, oldName)
+		if old == nil || !old.IsUnique || old.IsPrimary {
+			continue
+		}
+		if oldConstraint, newConstraint := uniqueConstraintName(before.Name, oldName), uniqueConstraintName(td.Table, col.Name); oldConstraint != newConstraint {
+			stmts = append(stmts, renameConstraintStatement(d, td.Table, oldConstraint, newConstraint))
+		}
+	}
+
+	return stmts
+}
+
+func dropForeignKeyStatement(d Dialect, rel RelationSchema) string {
+	return fmt.Sprintf(
+		"ALTER TABLE %s DROP CONSTRAINT IF EXISTS %s;",
+		d.QuoteIdent(cleanName(rel.ToTable)),
+		d.QuoteIdent(d.ForeignKeyName(rel)),
+	)
+}
+
+func addForeignKeyStatement(d Dialect, rel RelationSchema) string {
+	return fmt.Sprintf(
+		"ALTER TABLE %s\n  ADD CONSTRAINT %s\n  %s;",
+		d.QuoteIdent(cleanName(rel.ToTable)),
+		d.QuoteIdent(d.ForeignKeyName(rel)),
+		foreignKeyClause(d, rel),
+	)
+}
+
+// primaryKeyChanged reports whether a column joins or leaves the table's
+// primary key
+func primaryKeyChanged(td TableDiff) bool {
+	for _, change := range td.ColumnsChanged {
+		if change.PrimaryKeyChanged {
+			return true
+		}
+	}
+	for _, col := range append(append([]ColumnSchema{}, td.ColumnsAdded...), td.ColumnsRemoved...) {
+		if col.IsPrimary {
+			return true
+		}
+	}
+	return false
+}
+
+// referencesPrimaryKey reports whether every referenced column is part of
+// the table's primary key, which a foreign key then depends on
+func referencesPrimaryKey(table TableSchema, columns []string) bool {
+	for _, name := range columns {
+		col := findColumn([]TableSchema{table}, table.Name, name)
+		if col == nil || !col.IsPrimary {
+			return false
+		}
+	}
+	return true
+}
+
+func renameConstraintStatement(d Dialect, table, oldName, newName string) string {
+	return fmt.Sprintf("ALTER TABLE %s RENAME CONSTRAINT %s TO %s;", d.QuoteIdent(cleanName(table)), d.QuoteIdent(oldName), d.QuoteIdent(newName))
+}
+
+// previousTableName is the table's name in the source schema
+func previousTableName(td TableDiff) string {
+	if td.PreviousName != "" {
+		return td.PreviousName
+	}
+	return td.Table
+}
+
+// diffRenames rebuilds the old-name -> new-name map for the tables and
+// columns a diff matched across renames.
+func diffRenames(diff *SchemaDiff) map[string]string {
+	renames := make(map[string]string)
+	for _, td := range diff.TablesChanged {
+		oldTable := previousTableName(td)
+		renames[strings.ToLower(oldTable)] = td.Table
+		for _, change := range td.ColumnsChanged {
+			if change.NameChanged {
+				renames[strings.ToLower(oldTable+"."+change.Before.Name)] = change.Column
+			}
+		}
+	}
+	return renames
+}
+
+// primaryKeyName and uniqueConstraintName follow PostgreSQL's default naming,
+// which is what inline PRIMARY KEY / UNIQUE in GenerateSQL produce.
+func primaryKeyName(table string) string {
+	return cleanName(table) + "_pkey"
+}
+
+func uniqueConstraintName(table, column string) string {
+	return cleanName(table) + "_" + cleanName(column) + "_key"
+}
+
+// columnStorageType resolves serial pseudo-types, which are only valid in
+// CREATE TABLE / ADD COLUMN, to the integer type backing them.
+func columnStorageType(t string) string {
+	switch strings.ToLower(t) {
+	case "serial":
+		return "integer"
+	case "bigserial":
+		return "bigint"
+	case "smallserial":
+		return "smallint"
+	default:
+		return t
+	}
+}
+
+func hasPrimaryKey(table TableSchema) bool {
+	for _, col := range table.Columns {
+		if col.IsPrimary {
+			return true
+		}
+	}
+	return false
+}
+
+func findTable(tables []TableSchema, name string) *TableSchema {
+	for i := range tables {
+		if strings.EqualFold(tables[i].Name, name) {
+			return &tables[i]
+		}
+	}
+	return nil
+}
+
+var typeLengthRe = regexp.MustCompile(`^([a-z ]+?)\s*\(\s*(\d+)\s*(?:,\s*(\d+)\s*)?\)$`)
+
+// integerRank orders integer types by width so narrowing can be detected
+var integerRank = map[string]int{
+	"smallint": 1, "int2": 1, "smallserial": 1,
+	"integer": 2, "int": 2, "int4": 2, "serial": 2,
+	"bigint": 3, "int8": 3, "bigserial": 3,
+}
+
+// isNarrowingType reports whether converting a column from one type to
+// another can lose or reject existing values. Unknown conversions are treated
+// as narrowing unless the target is unbounded text.
+func isNarrowingType(fromType, toType string) bool {
+	from := strings.ToLower(strings.TrimSpace(fromType))
+	to := strings.ToLower(strings.TrimSpace(toType))
+	if from == to || to == "text" {
+		return false
+	}
+
+	fromBase, fromLen, fromScale := splitTypeLength(from)
+	toBase, toLen, toScale := splitTypeLength(to)
+
+	if fromRank, ok := integerRank[fromBase]; ok {
+		if toRank, ok := integerRank[toBase]; ok {
+			return toRank < fromRank
+		}
+	}
+
+	if fromBase == toBase || (isCharType(fromBase) && isCharType(toBase)) {
+		switch {
+		case toLen == 0:
+			return false
+		case fromLen == 0:
+			return true
+		default:
+			return toLen < fromLen || toScale < fromScale
+		}
+	}
+
+	return true
+}
+
+func splitTypeLength(t string) (string, int, int) {
+	m := typeLengthRe.FindStringSubmatch(t)
+	if m == nil {
+		return t, 0, 0
+	}
+	length, _ := strconv.Atoi(m[2])
+	scale, _ := strconv.Atoi(m[3])
+	return strings.TrimSpace(m[1]), length, scale
+}
+
+func isCharType(base string) bool {
+	return base == "varchar" || base == "character varying" || base == "char" || base == "character" || base == "text"
+}
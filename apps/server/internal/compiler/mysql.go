@@ -0,0 +1,208 @@
+package compiler
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+)
+
+// mysqlMaxIdentifierLength is the longest identifier MySQL/MariaDB accept for
+// table, column, index and constraint names.
+const mysqlMaxIdentifierLength = 64
+
+const mysqlTableOptions = "ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci"
+
+var typeParamsRe = regexp.MustCompile(`\(([^)]*)\)`)
+
+// MySQLDialect emits MySQL 8 / MariaDB DDL for InnoDB: backtick quoting,
+// AUTO_INCREMENT, utf8mb4 tables and length-limited constraint names.
+type MySQLDialect struct{}
+
+// GenerateMySQL generates MySQL 8 / MariaDB DDL from canvas data
+func GenerateMySQL(jsonData []byte) (string, error) {
+	return GenerateDialectSQL(jsonData, MySQLDialect{})
+}
+
+func (MySQLDialect) Name() string { return "mysql" }
+
+func (MySQLDialect) Preamble() string { return "" }
+
+func (MySQLDialect) QuoteIdent(name string) string { return quoteMySQL(name) }
+
+// ColumnType maps to indexable types for key columns, since InnoDB can't index
+// TEXT/BLOB columns without a prefix length.
+func (MySQLDialect) ColumnType(col ColumnSchema, isKey bool) string {
+	return mysqlType(col.Type, isKey)
+}
+
+// ColumnDefault rewrites the current-time and UUID functions to MySQL's.
+// MySQL only accepts other expressions, and any default on TEXT, BLOB and
+// JSON columns, in parentheses.
+func (MySQLDialect) ColumnDefault(col ColumnSchema, colType string) string {
+	if col.Default == nil {
+		return ""
+	}
+	switch col.Default.Kind {
+	case canvas.DefaultLiteral:
+		value := literalSQL(col.Default.Value, col.Type)
+		if strings.HasSuffix(colType, "TEXT") || strings.HasSuffix(colType, "BLOB") || colType == "JSON" {
+			return "(" + value + ")"
+		}
+		return value
+	case canvas.DefaultExpression:
+		switch expr := strings.TrimSpace(col.Default.Value); {
+		case isTimestampExpression(expr):
+			return "CURRENT_TIMESTAMP"
+		case isUUIDExpression(expr):
+			return "(UUID())"
+		default:
+			return "(" + expr + ")"
+		}
+	}
+	return ""
+}
+
+// AutoIncrementClause is empty for non-integer types
+func (MySQLDialect) AutoIncrementClause(colType string) (string, bool) {
+	if !isIntegerType(colType) {
+		return "", false
+	}
+	return "AUTO_INCREMENT", false
+}
+
+func (MySQLDialect) TableOptions() string { return mysqlTableOptions }
+
+func (MySQLDialect) ForeignKeyName(rel RelationSchema) string {
+	return mysqlIdentifier(PostgresDialect{}.ForeignKeyName(rel))
+}
+
+// ForeignKeyActions drops what InnoDB rejects: SET DEFAULT, which it parses
+// but refuses, and deferral, since it checks every foreign key immediately.
+func (d MySQLDialect) ForeignKeyActions(rel RelationSchema) (string, []string) {
+	var notes []string
+	onDelete, onUpdate := rel.OnDelete, rel.OnUpdate
+	if onDelete == "SET DEFAULT" {
+		notes = append(notes, fmt.Sprintf("InnoDB doesn't support ON DELETE SET DEFAULT; %s uses the default action", d.ForeignKeyName(rel)))
+		onDelete = ""
+	}
+	if onUpdate == "SET DEFAULT" {
+		notes = append(notes, fmt.Sprintf("InnoDB doesn't support ON UPDATE SET DEFAULT; %s uses the default action", d.ForeignKeyName(rel)))
+		onUpdate = ""
+	}
+	if rel.Deferrable || rel.InitiallyDeferred {
+		notes = append(notes, fmt.Sprintf("MySQL can't defer foreign key checks; %s is checked immediately", d.ForeignKeyName(rel)))
+	}
+	return referentialClause(onDelete, onUpdate, false, false), notes
+}
+
+func (MySQLDialect) IndexName(table string, columns []string) string {
+	return mysqlIdentifier(PostgresDialect{}.IndexName(table, columns))
+}
+
+// CreateIndex keeps B-tree and hash methods, the only ones InnoDB knows.
+// MySQL has no partial indexes, so a predicate is dropped and the index
+// covers every row.
+func (d MySQLDialect) CreateIndex(table string, index IndexSchema) string {
+	stmt := newIndexStatement(d, mysqlIdentifier(index.Name), table, index)
+	switch index.Method {
+	case "":
+	case canvas.IndexMethodBTree, canvas.IndexMethodHash:
+		stmt.UsingAfter = strings.ToUpper(index.Method)
+	default:
+		stmt.Notes = append(stmt.Notes, fmt.Sprintf("MySQL has no %s indexes; created as a B-tree index", index.Method))
+	}
+	if index.Where != "" {
+		stmt.Notes = append(stmt.Notes, fmt.Sprintf("MySQL doesn't support partial indexes; dropped WHERE %s", index.Where))
+		stmt.Where = ""
+	}
+	return stmt.String()
+}
+
+func (MySQLDialect) SupportsAlterForeignKeys() bool { return true }
+
+// RequiresReferencedIndex is true because InnoDB rejects a foreign key whose
+// referenced columns aren't the leading columns of some index.
+func (MySQLDialect) RequiresReferencedIndex() bool { return true }
+
+// quoteMySQL wraps an identifier in backticks, escaping embedded backticks
+func quoteMySQL(name string) string {
+	return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+}
+
+// mysqlIdentifier shortens generated names that exceed MySQL's identifier
+// limit, keeping them unique with a short hash of the full name.
+func mysqlIdentifier(name string) string {
+	if len(name) <= mysqlMaxIdentifierLength {
+		return name
+	}
+	sum := sha1.Sum([]byte(name))
+	suffix := hex.EncodeToString(sum[:])[:8]
+	return name[:mysqlMaxIdentifierLength-len(suffix)-1] + "_" + suffix
+}
+
+// mysqlType maps a canvas (PostgreSQL flavoured) type to its MySQL equivalent.
+// Columns used in keys can't be TEXT/BLOB, so they fall back to VARCHAR/VARBINARY.
+func mysqlType(canvasType string, isKey bool) string {
+	t := strings.ToLower(strings.TrimSpace(canvasType))
+	params := ""
+	if m := typeParamsRe.FindStringSubmatch(t); len(m) == 2 {
+		params = "(" + strings.ReplaceAll(m[1], " ", "") + ")"
+		t = strings.TrimSpace(typeParamsRe.ReplaceAllString(t, ""))
+	}
+
+	switch t {
+	case "uuid":
+		return "CHAR(36)"
+	case "serial", "integer", "int", "int4":
+		return "INT"
+	case "bigserial", "bigint", "int8":
+		return "BIGINT"
+	case "smallserial", "smallint", "int2":
+		return "SMALLINT"
+	case "boolean", "bool":
+		return "TINYINT(1)"
+	case "varchar", "character varying":
+		if params == "" {
+			params = "(255)"
+		}
+		return "VARCHAR" + params
+	case "char", "character":
+		if params == "" {
+			params = "(1)"
+		}
+		return "CHAR" + params
+	case "text", "citext":
+		if isKey {
+			return "VARCHAR(255)"
+		}
+		return "TEXT"
+	case "decimal", "numeric":
+		if params == "" {
+			params = "(10,2)"
+		}
+		return "DECIMAL" + params
+	case "real", "float", "float4":
+		return "FLOAT"
+	case "double precision", "double", "float8":
+		return "DOUBLE"
+	case "timestamp", "timestamptz", "timestamp with time zone", "timestamp without time zone", "datetime":
+		return "DATETIME" + params
+	case "date":
+		return "DATE"
+	case "time", "timetz", "time with time zone", "time without time zone":
+		return "TIME" + params
+	case "json", "jsonb":
+		return "JSON"
+	case "bytea", "blob":
+		if isKey {
+			return "VARBINARY(255)"
+		}
+		return "BLOB"
+	default:
+		return strings.ToUpper(t) + params
+	}
+}
@@ -0,0 +1,155 @@
+package compiler
+
+import "strings"
+
+// tableOrder is the order tables can be created in so that every foreign key
+// points at a table that already exists.
+type tableOrder struct {
+	tables []TableSchema
+	// cyclic marks, by index into Schema.Relations, the relations between
+	// distinct tables of a dependency cycle. No order satisfies them, so
+	// they have to be added once every table exists.
+	cyclic map[int]bool
+}
+
+// isSelfReference reports whether rel points back at its own table. A table
+// may reference itself inside its own CREATE TABLE, so self references never
+// constrain the order.
+func isSelfReference(rel RelationSchema) bool {
+	return strings.EqualFold(rel.FromTable, rel.ToTable)
+}
+
+// orderTables sorts the tables so referenced tables come before the tables
+// that reference them. Cycles are found as strongly connected components of
+// the reference graph; the relations inside them are marked cyclic and left
+// out of the ordering. Ties keep canvas order, so an unrelated schema comes
+// out unchanged.
+func orderTables(schema *Schema) tableOrder {
+	// deps maps a lower-cased table name to the tables it references
+	deps := make(map[string][]string)
+	for _, rel := range schema.Relations {
+		if isSelfReference(rel) || !hasTable(schema.Tables, rel.FromTable) {
+			continue
+		}
+		child := strings.ToLower(rel.ToTable)
+		deps[child] = append(deps[child], strings.ToLower(rel.FromTable))
+	}
+
+	component := stronglyConnected(schema.Tables, deps)
+	order := tableOrder{
+		tables: make([]TableSchema, 0, len(schema.Tables)),
+		cyclic: make(map[int]bool),
+	}
+	for i, rel := range schema.Relations {
+		if isSelfReference(rel) {
+			continue
+		}
+		child, parent := strings.ToLower(rel.ToTable), strings.ToLower(rel.FromTable)
+		if c, ok := component[child]; ok && c == component[parent] {
+			order.cyclic[i] = true
+		}
+	}
+
+	created := make(map[string]bool)
+	emitted := make([]bool, len(schema.Tables))
+	for len(order.tables) < len(schema.Tables) {
+		progress := false
+		for i, table := range schema.Tables {
+			if emitted[i] {
+				continue
+			}
+			key := strings.ToLower(table.Name)
+			ready := true
+			for _, parent := range deps[key] {
+				if !created[parent] && component[parent] != component[key] {
+					ready = false
+					break
+				}
+			}
+			if !ready {
+				continue
+			}
+			order.tables = append(order.tables, table)
+			emitted[i] = true
+			created[key] = true
+			progress = true
+			// Restart so an earlier table unblocked by this one keeps its
+			// canvas position relative to later ones
+			break
+		}
+		if !progress {
+			// Unreachable once cycles are removed, but never drop a table
+			for i, table := range schema.Tables {
+				if !emitted[i] {
+					order.tables = append(order.tables, table)
+					emitted[i] = true
+				}
+			}
+		}
+	}
+
+	return order
+}
+
+// stronglyConnected assigns every table name a component number using
+// Tarjan's algorithm. Tables share a number exactly when they reference each
+// other through a cycle.
+func stronglyConnected(tables []TableSchema, deps map[string][]string) map[string]int {
+	var (
+		index     = make(map[string]int)
+		lowlink   = make(map[string]int)
+		onStack   = make(map[string]bool)
+		stack     []string
+		component = make(map[string]int)
+		next      int
+		count     int
+	)
+
+	var visit func(name string)
+	visit = func(name string) {
+		index[name] = next
+		lowlink[name] = next
+		next++
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range deps[name] {
+			if _, seen := index[dep]; !seen {
+				visit(dep)
+				lowlink[name] = min(lowlink[name], lowlink[dep])
+			} else if onStack[dep] {
+				lowlink[name] = min(lowlink[name], index[dep])
+			}
+		}
+
+		if lowlink[name] == index[name] {
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component[top] = count
+				if top == name {
+					break
+				}
+			}
+			count++
+		}
+	}
+
+	for _, table := range tables {
+		name := strings.ToLower(table.Name)
+		if _, seen := index[name]; !seen {
+			visit(name)
+		}
+	}
+	return component
+}
+
+func hasTable(tables []TableSchema, name string) bool {
+	for _, t := range tables {
+		if strings.EqualFold(t.Name, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+)
+
+// PostgresDialect emits PostgreSQL DDL. Canvas types are PostgreSQL types
+// already, so they pass through untouched and identifiers are left unquoted.
+type PostgresDialect struct{}
+
+func (PostgresDialect) Name() string { return "postgres" }
+
+func (PostgresDialect) Preamble() string { return "" }
+
+func (PostgresDialect) QuoteIdent(name string) string { return name }
+
+func (PostgresDialect) ColumnType(col ColumnSchema, isKey bool) string {
+	return fallbackType(col.Type)
+}
+
+// ColumnDefault is empty for serial types, whose sequence is their default
+func (PostgresDialect) ColumnDefault(col ColumnSchema, colType string) string {
+	if isAutoIncrement(colType) {
+		return ""
+	}
+	return standardDefault(col)
+}
+
+// AutoIncrementClause is empty for serial types, which already carry their
+// sequence, and for non-integer types, which can't be identity columns;
+// other integer columns become identity columns
+func (PostgresDialect) AutoIncrementClause(colType string) (string, bool) {
+	if isAutoIncrement(colType) || !isIntegerType(colType) {
+		return "", false
+	}
+	return "GENERATED BY DEFAULT AS IDENTITY", false
+}
+
+func (PostgresDialect) TableOptions() string { return "" }
+
+func (PostgresDialect) ForeignKeyName(rel RelationSchema) string {
+	return fmt.Sprintf(
+		"fk_%s_%s_%s",
+		cleanName(rel.ToTable),
+		cleanName(rel.FromTable),
+		joinNames(rel.FromColumns()),
+	)
+}
+
+func (PostgresDialect) ForeignKeyActions(rel RelationSchema) (string, []string) {
+	return referentialClause(rel.OnDelete, rel.OnUpdate, rel.Deferrable, rel.InitiallyDeferred), nil
+}
+
+func (PostgresDialect) IndexName(table string, columns []string) string {
+	return fmt.Sprintf("idx_%s_%s_fk", cleanName(table), joinNames(columns))
+}
+
+// joinNames joins cleaned names with underscores for use inside a
+// generated identifier
+func joinNames(names []string) string {
+	cleaned := make([]string, len(names))
+	for i, name := range names {
+		cleaned[i] = cleanName(name)
+	}
+	return strings.Join(cleaned, "_")
+}
+
+func (d PostgresDialect) CreateIndex(table string, index IndexSchema) string {
+	stmt := newIndexStatement(d, index.Name, table, index)
+	if index.Method != "" && index.Method != canvas.IndexMethodBTree {
+		stmt.UsingBefore = index.Method
+	}
+	return stmt.String()
+}
+
+func (PostgresDialect) SupportsAlterForeignKeys() bool { return true }
+
+func (PostgresDialect) RequiresReferencedIndex() bool { return false }
@@ -3,11 +3,38 @@ package compiler
 import (
 	"regexp"
 	"strings"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
 )
 
 type SQLTable struct {
 	Name    string
 	Columns []SQLColumn
+	Indexes []SQLIndex
+	Checks  []SQLCheck
+}
+
+// SQLCheck is a CHECK constraint. Column names the column it was declared on,
+// if any.
+type SQLCheck struct {
+	Name       string
+	Expression string
+	Column     string
+}
+
+// SQLIndex is an index from CREATE INDEX or an INDEX, KEY or multi-column
+// UNIQUE definition inside CREATE TABLE
+type SQLIndex struct {
+	Name    string
+	Columns []SQLIndexColumn
+	Unique  bool
+	Where   string
+	Method  string
+}
+
+type SQLIndexColumn struct {
+	Name       string
+	Descending bool
 }
 
 type SQLColumn struct {
@@ -20,15 +47,30 @@ type SQLColumn struct {
 	RefTable     string
 	RefColumn    string
 	Constraints  []string
-	DefaultValue string
+	Default      *SQLColumnDefault
 }
 
+// SQLColumnDefault is a column's DEFAULT clause sorted into one of the canvas
+// default kinds. Literal values are unquoted.
+type SQLColumnDefault struct {
+	Kind  string
+	Value string
+}
+
+// SQLForeignKey is a foreign key from FromTable's columns to ToTable's,
+// paired by position
 type SQLForeignKey struct {
-	FromTable  string
-	FromColumn string
-	ToTable    string
-	ToColumn   string
-	Name       string
+	FromTable   string
+	FromColumns []string
+	ToTable     string
+	ToColumns   []string
+	Name        string
+	// OnDelete and OnUpdate are upper-cased referential actions, empty when
+	// the statement leaves them out
+	OnDelete          string
+	OnUpdate          string
+	Deferrable        bool
+	InitiallyDeferred bool
 }
 
 // ParseSQL parses SQL CREATE TABLE statements and extracts schema information
@@ -46,6 +88,18 @@ func ParseSQL(sqlContent string) ([]SQLTable, []SQLForeignKey, error) {
 	// Split by semicolons to get individual statements
 	statements := splitSQLStatements(sqlContent)
 
+	// CREATE INDEX may come before or after its table
+	type tableIndex struct {
+		table string
+		index SQLIndex
+	}
+	var standaloneIndexes []tableIndex
+	type tableCheck struct {
+		table string
+		check SQLCheck
+	}
+	var standaloneChecks []tableCheck
+
 	for _, stmt := range statements {
 		stmt = strings.TrimSpace(stmt)
 		upperStmt := strings.ToUpper(stmt)
@@ -57,15 +111,65 @@ func ParseSQL(sqlContent string) ([]SQLTable, []SQLForeignKey, error) {
 				foreignKeys = append(foreignKeys, fks...)
 			}
 		} else if strings.HasPrefix(upperStmt, "ALTER TABLE") {
-			// Parse ALTER TABLE ADD CONSTRAINT for foreign keys
+			// Parse ALTER TABLE ADD CONSTRAINT for foreign keys and checks
 			fk := parseAlterTableFK(stmt)
 			if fk != nil {
 				foreignKeys = append(foreignKeys, *fk)
+			} else if tableName, check := parseAlterTableCheck(stmt); check != nil {
+				standaloneChecks = append(standaloneChecks, tableCheck{table: tableName, check: *check})
+			}
+		} else if createIndexRe.MatchString(stmt) {
+			if tableName, index := parseCreateIndex(stmt); index != nil {
+				standaloneIndexes = append(standaloneIndexes, tableIndex{table: tableName, index: *index})
+			}
+		}
+	}
+
+	for _, ti := range standaloneIndexes {
+		for i := range tables {
+			if strings.EqualFold(tables[i].Name, ti.table) {
+				tables[i].Indexes = append(tables[i].Indexes, ti.index)
+				break
+			}
+		}
+	}
+	for _, tc := range standaloneChecks {
+		for i := range tables {
+			if strings.EqualFold(tables[i].Name, tc.table) {
+				tables[i].Checks = append(tables[i].Checks, tc.check)
+				break
 			}
 		}
 	}
 
-	return tables, foreignKeys, nil
+	return tables, resolveForeignKeys(tables, foreignKeys), nil
+}
+
+// resolveForeignKeys points references without a column list at the
+// referenced table's primary key, and drops keys whose two sides don't pair
+// up column for column
+func resolveForeignKeys(tables []SQLTable, foreignKeys []SQLForeignKey) []SQLForeignKey {
+	resolved := make([]SQLForeignKey, 0, len(foreignKeys))
+	for _, fk := range foreignKeys {
+		if len(fk.ToColumns) == 0 {
+			for _, table := range tables {
+				if !strings.EqualFold(table.Name, fk.ToTable) {
+					continue
+				}
+				for _, col := range table.Columns {
+					if col.IsPrimaryKey {
+						fk.ToColumns = append(fk.ToColumns, col.Name)
+					}
+				}
+				break
+			}
+		}
+		if len(fk.FromColumns) == 0 || len(fk.FromColumns) != len(fk.ToColumns) {
+			continue
+		}
+		resolved = append(resolved, fk)
+	}
+	return resolved
 }
 
 func removeSQLComments(sql string) string {
@@ -143,26 +247,11 @@ func parseCreateTable(stmt string) (SQLTable, []SQLForeignKey) {
 	}
 
 	// Find matching closing parenthesis
-	parenCount := 0
-	endIdx := -1
-	for i := startIdx; i < len(stmt); i++ {
-		if stmt[i] == '(' {
-			parenCount++
-		} else if stmt[i] == ')' {
-			parenCount--
-			if parenCount == 0 {
-				endIdx = i
-				break
-			}
-		}
-	}
-
-	if endIdx == -1 {
+	content, _, ok := parenthesized(stmt[startIdx:])
+	if !ok {
 		return table, foreignKeys
 	}
 
-	content := stmt[startIdx+1 : endIdx]
-
 	// Parse column definitions and constraints
 	definitions := splitColumnDefinitions(content)
 	primaryKeys := []string{}
@@ -175,41 +264,50 @@ func parseCreateTable(stmt string) (SQLTable, []SQLForeignKey) {
 		}
 
 		upperDef := strings.ToUpper(def)
+		constraintName, body := splitConstraintName(def)
+		upperBody := strings.ToUpper(body)
 
 		// Check for PRIMARY KEY constraint (table-level)
-		if strings.HasPrefix(upperDef, "PRIMARY KEY") {
+		if strings.HasPrefix(upperBody, "PRIMARY KEY") {
 			pkRe := regexp.MustCompile(`(?i)PRIMARY\s+KEY\s*\(([^)]+)\)`)
-			pkMatches := pkRe.FindStringSubmatch(def)
+			pkMatches := pkRe.FindStringSubmatch(body)
 			if len(pkMatches) >= 2 {
-				cols := strings.Split(pkMatches[1], ",")
-				for _, col := range cols {
-					col = cleanIdentifier(col)
-					if col != "" {
-						primaryKeys = append(primaryKeys, col)
-					}
-				}
+				primaryKeys = append(primaryKeys, splitIdentifierList(pkMatches[1])...)
 			}
 			continue
 		}
 
-		// Check for UNIQUE constraint (table-level)
-		if strings.HasPrefix(upperDef, "UNIQUE") {
-			uniqueRe := regexp.MustCompile(`(?i)UNIQUE\s*(?:KEY|INDEX)?\s*(?:\w+\s*)?\(([^)]+)\)`)
-			uniqueMatches := uniqueRe.FindStringSubmatch(def)
-			if len(uniqueMatches) >= 2 {
-				cols := strings.Split(uniqueMatches[1], ",")
-				for _, col := range cols {
-					col = cleanIdentifier(col)
-					if col != "" {
-						uniqueKeys[col] = true
-					}
-				}
+		// Check for UNIQUE constraint (table-level). A single column is
+		// marked unique; several columns are unique together, which only an
+		// index can express.
+		uniqueRe := regexp.MustCompile(`(?i)^UNIQUE\s*(?:KEY|INDEX)?\s*(?:["\x60]?(\w+)["\x60]?\s*)?\(([^)]+)\)`)
+		if uniqueMatches := uniqueRe.FindStringSubmatch(body); uniqueMatches != nil {
+			columns, ok := parseIndexColumns(uniqueMatches[2])
+			if !ok {
+				continue
+			}
+			if len(columns) == 1 {
+				uniqueKeys[strings.ToLower(columns[0].Name)] = true
+				continue
+			}
+			name := constraintName
+			if name == "" {
+				name = uniqueMatches[1]
+			}
+			table.Indexes = append(table.Indexes, SQLIndex{Name: name, Columns: columns, Unique: true})
+			continue
+		}
+
+		// Check for CHECK constraint (table-level)
+		if strings.HasPrefix(upperBody, "CHECK") {
+			if check := parseCheckClause(body, constraintName); check != nil {
+				table.Checks = append(table.Checks, *check)
 			}
 			continue
 		}
 
 		// Check for FOREIGN KEY constraint (table-level)
-		if strings.HasPrefix(upperDef, "FOREIGN KEY") || strings.HasPrefix(upperDef, "CONSTRAINT") {
+		if strings.HasPrefix(upperBody, "FOREIGN KEY") || strings.HasPrefix(upperDef, "CONSTRAINT") {
 			fk := parseForeignKeyConstraint(def, table.Name)
 			if fk != nil {
 				foreignKeys = append(foreignKeys, *fk)
@@ -217,23 +315,32 @@ func parseCreateTable(stmt string) (SQLTable, []SQLForeignKey) {
 			continue
 		}
 
-		// Check for CHECK constraint
-		if strings.HasPrefix(upperDef, "CHECK") {
+		// Check for INDEX / KEY (MySQL)
+		if inlineIndexRe.MatchString(def) {
+			if index := parseInlineIndex(def); index != nil {
+				table.Indexes = append(table.Indexes, *index)
+			}
 			continue
 		}
 
-		// Check for INDEX
-		if strings.HasPrefix(upperDef, "INDEX") || strings.HasPrefix(upperDef, "KEY") {
+		// Full-text and spatial indexes have no portable equivalent
+		if fullTextIndexRe.MatchString(def) {
 			continue
 		}
 
-		// Parse column definition
+		// Parse column definition. Its checks come out first so their
+		// expressions can't be mistaken for the column's own constraints.
+		def, checks := extractColumnChecks(def)
 		col, inlineFk := parseColumnDefinition(def, table.Name)
 		if col.Name != "" {
 			table.Columns = append(table.Columns, col)
 			if inlineFk != nil {
 				foreignKeys = append(foreignKeys, *inlineFk)
 			}
+			for _, check := range checks {
+				check.Column = col.Name
+				table.Checks = append(table.Checks, check)
+			}
 		}
 	}
 
@@ -351,24 +458,25 @@ func parseColumnDefinition(def string, tableName string) (SQLColumn, *SQLForeign
 		col.IsPrimaryKey = true
 	}
 
+	// Check for DEFAULT value
+	col.Default = parseColumnDefault(def)
+
 	// Check for AUTO_INCREMENT / SERIAL / IDENTITY
-	if regexp.MustCompile(`(?i)\b(AUTO_INCREMENT|SERIAL|BIGSERIAL|SMALLSERIAL|IDENTITY)\b`).MatchString(def) {
+	if regexp.MustCompile(`(?i)\b(AUTO_INCREMENT|AUTOINCREMENT|SERIAL|BIGSERIAL|SMALLSERIAL|IDENTITY)\b`).MatchString(def) {
 		if !contains(col.Constraints, "AI") {
 			col.Constraints = append(col.Constraints, "AI")
 		}
+		if col.Default == nil {
+			col.Default = &SQLColumnDefault{Kind: canvas.DefaultSequence}
+		}
 	}
 
-	// Check for DEFAULT value
-	defaultRe := regexp.MustCompile(`(?i)\bDEFAULT\s+([^\s,]+(?:\([^)]*\))?)`)
-	defaultMatches := defaultRe.FindStringSubmatch(def)
-	if len(defaultMatches) >= 2 {
-		col.DefaultValue = defaultMatches[1]
-	}
-
-	// Check for inline REFERENCES (foreign key)
-	refRe := regexp.MustCompile(`(?i)\bREFERENCES\s+["'\x60]?(\w+)["'\x60]?\s*\(\s*["'\x60]?(\w+)["'\x60]?\s*\)`)
+	// Check for inline REFERENCES (foreign key). Without a column it
+	// references the primary key, which is resolved once every table is known.
+	refRe := regexp.MustCompile(`(?i)\bREFERENCES\s+(?:["'\x60]?\w+["'\x60]?\.)?["'\x60]?(\w+)["'\x60]?(?:\s*\(\s*["'\x60]?(\w+)["'\x60]?\s*\))?`)
 	refMatches := refRe.FindStringSubmatch(def)
 	if len(refMatches) >= 3 {
+		refEnd := refRe.FindStringIndex(def)[1]
 		col.IsForeignKey = true
 		col.RefTable = refMatches[1]
 		col.RefColumn = refMatches[2]
@@ -376,11 +484,14 @@ func parseColumnDefinition(def string, tableName string) (SQLColumn, *SQLForeign
 			col.Constraints = append(col.Constraints, "FK")
 		}
 		fk = &SQLForeignKey{
-			FromTable:  tableName,
-			FromColumn: col.Name,
-			ToTable:    refMatches[1],
-			ToColumn:   refMatches[2],
+			FromTable:   tableName,
+			FromColumns: []string{col.Name},
+			ToTable:     refMatches[1],
+		}
+		if refMatches[2] != "" {
+			fk.ToColumns = []string{refMatches[2]}
 		}
+		parseReferentialOptions(fk, def[refEnd:])
 	}
 
 	return col, fk
@@ -530,35 +641,362 @@ func normalizeTypeString(typeStr string) string {
 	}
 }
 
-func parseForeignKeyConstraint(def string, tableName string) *SQLForeignKey {
-	// Pattern: [CONSTRAINT name] FOREIGN KEY (column[, ...]) REFERENCES ref_table(ref_column[, ...])
-	re := regexp.MustCompile(`(?i)(?:CONSTRAINT\s+["'\x60]?(\w+)["'\x60]?\s+)?FOREIGN\s+KEY\s*\(\s*["'\x60]?(\w+)["'\x60]?\s*\)\s+REFERENCES\s+["'\x60]?(\w+)["'\x60]?\s*\(\s*["'\x60]?(\w+)["'\x60]?\s*\)`)
-	matches := re.FindStringSubmatch(def)
-	if len(matches) >= 5 {
-		return &SQLForeignKey{
-			Name:       matches[1],
-			FromTable:  tableName,
-			FromColumn: matches[2],
-			ToTable:    matches[3],
-			ToColumn:   matches[4],
+var (
+	defaultKeywordRe = regexp.MustCompile(`(?i)\bDEFAULT\s+`)
+	setBeforeRe      = regexp.MustCompile(`(?i)\bSET\s+$`)
+	castSuffixRe     = regexp.MustCompile(`::[\w\s]+(?:\[\])?$`)
+	nextvalRe        = regexp.MustCompile(`(?i)^nextval\(\s*'([^']+)'`)
+)
+
+// parseColumnDefault finds a column's DEFAULT clause and sorts it into a
+// kind. DEFAULT NULL is no default at all, and the DEFAULT of a referential
+// SET DEFAULT action isn't one.
+func parseColumnDefault(def string) *SQLColumnDefault {
+	for _, loc := range defaultKeywordRe.FindAllStringIndex(def, -1) {
+		if setBeforeRe.MatchString(def[:loc[0]]) {
+			continue
 		}
+		return classifyDefault(readDefaultExpression(def[loc[1]:]))
 	}
 	return nil
 }
 
+// readDefaultExpression reads the expression at the start of s, up to the
+// first space or comma outside quotes and parentheses
+func readDefaultExpression(s string) string {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\'':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth == 0 {
+				return s[:i]
+			}
+			depth--
+		case depth == 0 && (c == ' ' || c == ','):
+			return s[:i]
+		}
+	}
+	return s
+}
+
+func classifyDefault(expr string) *SQLColumnDefault {
+	expr = strings.TrimSpace(expr)
+	for wrappedInParens(expr) {
+		expr = strings.TrimSpace(expr[1 : len(expr)-1])
+	}
+	literal := castSuffixRe.ReplaceAllString(expr, "")
+
+	switch {
+	case expr == "" || strings.EqualFold(literal, "NULL"):
+		return nil
+	case strings.HasPrefix(literal, "'") && strings.HasSuffix(literal, "'") && len(literal) >= 2:
+		value := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+		return &SQLColumnDefault{Kind: canvas.DefaultLiteral, Value: value}
+	case numericLiteralRe.MatchString(literal):
+		return &SQLColumnDefault{Kind: canvas.DefaultLiteral, Value: literal}
+	case strings.EqualFold(literal, "TRUE") || strings.EqualFold(literal, "FALSE"):
+		return &SQLColumnDefault{Kind: canvas.DefaultLiteral, Value: strings.ToLower(literal)}
+	}
+	if m := nextvalRe.FindStringSubmatch(expr); m != nil {
+		return &SQLColumnDefault{Kind: canvas.DefaultSequence, Value: m[1]}
+	}
+	return &SQLColumnDefault{Kind: canvas.DefaultExpression, Value: expr}
+}
+
+// wrappedInParens reports whether expr is entirely enclosed in one pair of
+// parentheses, as MySQL writes expression defaults
+func wrappedInParens(expr string) bool {
+	if len(expr) < 2 || expr[0] != '(' || expr[len(expr)-1] != ')' {
+		return false
+	}
+	depth := 0
+	inString := false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\'':
+			inString = !inString
+		case inString:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 && i < len(expr)-1 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// foreignKeyClauseRe matches FOREIGN KEY (columns) REFERENCES table
+// [(columns)]. The column lists are split by splitIdentifierList.
+var foreignKeyClauseRe = regexp.MustCompile(`(?i)^FOREIGN\s+KEY\s*\(([^)]+)\)\s*REFERENCES\s+(?:["'\x60]?\w+["'\x60]?\.)?["'\x60]?(\w+)["'\x60]?\s*(?:\(([^)]+)\))?`)
+
+func parseForeignKeyConstraint(def string, tableName string) *SQLForeignKey {
+	// Pattern: [CONSTRAINT name] FOREIGN KEY (column[, ...]) REFERENCES ref_table[(ref_column[, ...])]
+	name, body := splitConstraintName(def)
+	return parseForeignKeyClause(body, tableName, name)
+}
+
+var alterTableAddRe = regexp.MustCompile(`(?i)^ALTER\s+TABLE\s+(?:ONLY\s+)?(?:["'\x60]?\w+["'\x60]?\.)?["'\x60]?(\w+)["'\x60]?\s+ADD\s+`)
+
 func parseAlterTableFK(stmt string) *SQLForeignKey {
-	// Pattern: ALTER TABLE table_name ADD [CONSTRAINT constraint_name] FOREIGN KEY (column) REFERENCES ref_table(ref_column)
-	re := regexp.MustCompile(`(?i)ALTER\s+TABLE\s+["'\x60]?(\w+)["'\x60]?\s+ADD\s+(?:CONSTRAINT\s+["'\x60]?\w+["'\x60]?\s+)?FOREIGN\s+KEY\s*\(\s*["'\x60]?(\w+)["'\x60]?\s*\)\s+REFERENCES\s+["'\x60]?(\w+)["'\x60]?\s*\(\s*["'\x60]?(\w+)["'\x60]?\s*\)`)
-	matches := re.FindStringSubmatch(stmt)
-	if len(matches) >= 5 {
-		return &SQLForeignKey{
-			FromTable:  matches[1],
-			FromColumn: matches[2],
-			ToTable:    matches[3],
-			ToColumn:   matches[4],
+	// Pattern: ALTER TABLE table_name ADD [CONSTRAINT constraint_name] FOREIGN KEY (column[, ...]) REFERENCES ref_table[(ref_column[, ...])]
+	matches := alterTableAddRe.FindStringSubmatch(stmt)
+	if matches == nil {
+		return nil
+	}
+	name, body := splitConstraintName(stmt[len(matches[0]):])
+	return parseForeignKeyClause(body, matches[1], name)
+}
+
+// parseAlterTableCheck parses ALTER TABLE table_name ADD [CONSTRAINT name]
+// CHECK (expression) into its table name and check
+func parseAlterTableCheck(stmt string) (string, *SQLCheck) {
+	matches := alterTableAddRe.FindStringSubmatch(stmt)
+	if matches == nil {
+		return "", nil
+	}
+	name, body := splitConstraintName(stmt[len(matches[0]):])
+	return matches[1], parseCheckClause(body, name)
+}
+
+var (
+	checkClauseRe = regexp.MustCompile(`(?i)^CHECK\s*\(`)
+	columnCheckRe = regexp.MustCompile(`(?i)(?:\bCONSTRAINT\s+["'\x60]?(\w+)["'\x60]?\s+)?\bCHECK\s*\(`)
+)
+
+// parseCheckClause parses a CHECK (expression) clause. Anything after the
+// expression, such as NOT ENFORCED or NO INHERIT, is ignored.
+func parseCheckClause(clause, name string) *SQLCheck {
+	clause = strings.TrimSpace(clause)
+	m := checkClauseRe.FindStringIndex(clause)
+	if m == nil {
+		return nil
+	}
+	expr, _, ok := parenthesized(clause[m[1]-1:])
+	if !ok || strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	return &SQLCheck{Name: name, Expression: strings.TrimSpace(expr)}
+}
+
+// extractColumnChecks removes the [CONSTRAINT name] CHECK (...) clauses from
+// a column definition and returns what's left along with the checks
+func extractColumnChecks(def string) (string, []SQLCheck) {
+	var checks []SQLCheck
+	for {
+		m := columnCheckRe.FindStringSubmatchIndex(def)
+		if m == nil {
+			return def, checks
+		}
+		expr, rest, ok := parenthesized(def[m[1]-1:])
+		if !ok {
+			return def, checks
+		}
+		if expr = strings.TrimSpace(expr); expr != "" {
+			check := SQLCheck{Expression: expr}
+			if m[2] >= 0 {
+				check.Name = def[m[2]:m[3]]
+			}
+			checks = append(checks, check)
 		}
+		def = strings.TrimSpace(def[:m[0]]) + " " + strings.TrimSpace(rest)
 	}
-	return nil
+}
+
+// parseForeignKeyClause parses the FOREIGN KEY ... REFERENCES part of a
+// constraint on tableName. An omitted referenced column list is left empty
+// for resolveForeignKeys.
+func parseForeignKeyClause(clause, tableName, name string) *SQLForeignKey {
+	clause = strings.TrimSpace(clause)
+	matches := foreignKeyClauseRe.FindStringSubmatch(clause)
+	if matches == nil {
+		return nil
+	}
+	fk := &SQLForeignKey{
+		Name:        name,
+		FromTable:   tableName,
+		FromColumns: splitIdentifierList(matches[1]),
+		ToTable:     matches[2],
+		ToColumns:   splitIdentifierList(matches[3]),
+	}
+	parseReferentialOptions(fk, clause[len(matches[0]):])
+	return fk
+}
+
+var (
+	onDeleteRe          = regexp.MustCompile(`(?i)\bON\s+DELETE\s+(CASCADE|RESTRICT|NO\s+ACTION|SET\s+NULL|SET\s+DEFAULT)\b`)
+	onUpdateRe          = regexp.MustCompile(`(?i)\bON\s+UPDATE\s+(CASCADE|RESTRICT|NO\s+ACTION|SET\s+NULL|SET\s+DEFAULT)\b`)
+	deferrableRe        = regexp.MustCompile(`(?i)\b(NOT\s+)?DEFERRABLE\b`)
+	initiallyDeferredRe = regexp.MustCompile(`(?i)\bINITIALLY\s+DEFERRED\b`)
+)
+
+// parseReferentialOptions reads the actions and deferrability that follow a
+// REFERENCES clause into fk
+func parseReferentialOptions(fk *SQLForeignKey, rest string) {
+	normalize := func(action string) string {
+		return strings.Join(strings.Fields(strings.ToUpper(action)), " ")
+	}
+	if m := onDeleteRe.FindStringSubmatch(rest); m != nil {
+		fk.OnDelete = normalize(m[1])
+	}
+	if m := onUpdateRe.FindStringSubmatch(rest); m != nil {
+		fk.OnUpdate = normalize(m[1])
+	}
+	if m := deferrableRe.FindStringSubmatch(rest); m != nil {
+		fk.Deferrable = m[1] == ""
+	}
+	fk.InitiallyDeferred = initiallyDeferredRe.MatchString(rest)
+	if fk.InitiallyDeferred {
+		fk.Deferrable = true
+	}
+}
+
+// splitIdentifierList splits a comma-separated column list into cleaned names
+func splitIdentifierList(list string) []string {
+	var names []string
+	for _, part := range strings.Split(list, ",") {
+		if name := cleanIdentifier(part); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+var (
+	createIndexRe      = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(?:["\x60]?(\w+)["\x60]?\s+)?ON\s+(?:ONLY\s+)?(?:["\x60]?\w+["\x60]?\.)?["\x60]?(\w+)["\x60]?\s*(?:USING\s+(\w+)\s*)?\(`)
+	inlineIndexRe      = regexp.MustCompile(`(?i)^(?:INDEX|KEY)\s*(?:["\x60]?(\w+)["\x60]?\s*)?(?:USING\s+(\w+)\s*)?\(`)
+	fullTextIndexRe    = regexp.MustCompile(`(?i)^(?:FULLTEXT|SPATIAL)\s+(?:INDEX|KEY)?`)
+	indexUsingRe       = regexp.MustCompile(`(?i)^\s*USING\s+(\w+)`)
+	indexWhereRe       = regexp.MustCompile(`(?i)\bWHERE\s+(.+)$`)
+	constraintPrefixRe = regexp.MustCompile(`(?i)^CONSTRAINT\s+["'\x60]?(\w+)["'\x60]?\s+`)
+	prefixLengthRe     = regexp.MustCompile(`^(\w+)\s*\(\s*\d+\s*\)$`)
+)
+
+// splitConstraintName strips a leading CONSTRAINT name from a table-level
+// definition and returns the name and the rest
+func splitConstraintName(def string) (string, string) {
+	if m := constraintPrefixRe.FindStringSubmatch(def); m != nil {
+		return m[1], def[len(m[0]):]
+	}
+	return "", def
+}
+
+// parseCreateIndex parses a CREATE INDEX statement into its table name and
+// index. Expression indexes aren't modeled and return nil.
+func parseCreateIndex(stmt string) (string, *SQLIndex) {
+	m := createIndexRe.FindStringSubmatchIndex(stmt)
+	if m == nil {
+		return "", nil
+	}
+	group := func(n int) string {
+		if m[2*n] < 0 {
+			return ""
+		}
+		return stmt[m[2*n]:m[2*n+1]]
+	}
+
+	content, rest, ok := parenthesized(stmt[m[1]-1:])
+	if !ok {
+		return "", nil
+	}
+	columns, ok := parseIndexColumns(content)
+	if !ok {
+		return "", nil
+	}
+
+	index := &SQLIndex{
+		Name:    group(2),
+		Columns: columns,
+		Unique:  group(1) != "",
+		Method:  strings.ToLower(group(4)),
+	}
+	if using := indexUsingRe.FindStringSubmatch(rest); using != nil && index.Method == "" {
+		index.Method = strings.ToLower(using[1])
+	}
+	if where := indexWhereRe.FindStringSubmatch(rest); where != nil {
+		index.Where = strings.TrimSpace(where[1])
+	}
+	return group(3), index
+}
+
+// parseInlineIndex parses a MySQL INDEX or KEY definition inside CREATE TABLE
+func parseInlineIndex(def string) *SQLIndex {
+	m := inlineIndexRe.FindStringSubmatch(def)
+	if m == nil {
+		return nil
+	}
+	content, rest, ok := parenthesized(def[len(m[0])-1:])
+	if !ok {
+		return nil
+	}
+	columns, ok := parseIndexColumns(content)
+	if !ok {
+		return nil
+	}
+	index := &SQLIndex{Name: m[1], Columns: columns, Method: strings.ToLower(m[2])}
+	if using := indexUsingRe.FindStringSubmatch(rest); using != nil && index.Method == "" {
+		index.Method = strings.ToLower(using[1])
+	}
+	return index
+}
+
+// parenthesized returns the contents of the parenthesized group s starts
+// with and whatever follows it. Parentheses inside quotes don't count.
+func parenthesized(s string) (string, string, bool) {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			if end := strings.IndexByte(s[i+1:], s[i]); end >= 0 {
+				i += end + 1
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return s[1:i], s[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// parseIndexColumns parses an index column list. It reports false when an
+// entry is an expression rather than a column. MySQL prefix lengths are
+// dropped, as are operator classes and NULLS FIRST/LAST.
+func parseIndexColumns(content string) ([]SQLIndexColumn, bool) {
+	var columns []SQLIndexColumn
+	for _, part := range splitColumnDefinitions(content) {
+		tokens := tokenizeColumnDef(part)
+		if len(tokens) == 0 {
+			continue
+		}
+		name := tokens[0]
+		if m := prefixLengthRe.FindStringSubmatch(cleanIdentifier(name)); m != nil {
+			name = m[1]
+		}
+		name = cleanIdentifier(name)
+		if name == "" || strings.ContainsAny(name, "()") {
+			return nil, false
+		}
+		col := SQLIndexColumn{Name: name}
+		for _, token := range tokens[1:] {
+			if strings.EqualFold(token, "DESC") {
+				col.Descending = true
+			}
+		}
+		columns = append(columns, col)
+	}
+	return columns, len(columns) > 0
 }
 
 func cleanIdentifier(s string) string {
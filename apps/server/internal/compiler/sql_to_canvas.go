@@ -4,12 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
 )
 
 // ConvertSQLToCanvas converts parsed SQL schema to React Flow canvas format
-func ConvertSQLToCanvas(tables []SQLTable, foreignKeys []SQLForeignKey) (map[string]interface{}, error) {
-	nodes := []map[string]interface{}{}
-	edges := []map[string]interface{}{}
+func ConvertSQLToCanvas(tables []SQLTable, foreignKeys []SQLForeignKey) (*canvas.Canvas, error) {
+	canvasData := canvas.New()
 
 	// Map to track table names to node IDs
 	tableToNodeID := make(map[string]string)
@@ -24,121 +26,197 @@ func ConvertSQLToCanvas(tables []SQLTable, foreignKeys []SQLForeignKey) (map[str
 		tableToNodeID[table.Name] = nodeID
 		tableColumnMap[table.Name] = make(map[string]string)
 
-		columns := []map[string]interface{}{}
+		columns := make([]canvas.Column, 0, len(table.Columns))
+		primaryKeys := 0
 		for j, col := range table.Columns {
+			if col.IsPrimaryKey {
+				primaryKeys++
+			}
 			colID := fmt.Sprintf("col_%d_%d", i, j)
 			tableColumnMap[table.Name][col.Name] = colID
 
+			column := canvas.Column{
+				ID:           colID,
+				Name:         col.Name,
+				Type:         col.Type,
+				IsPrimaryKey: col.IsPrimaryKey,
+				IsUnique:     col.IsUnique,
+				IsNullable:   col.IsNullable && !col.IsPrimaryKey,
+				Constraints:  []string{},
+			}
+			if col.Default != nil {
+				column.Default = &canvas.Default{Kind: col.Default.Kind, Value: col.Default.Value}
+			}
+
 			// Build constraints array
-			constraints := []string{}
 			if !col.IsNullable {
-				if !containsStr(col.Constraints, "NN") {
-					constraints = append(constraints, "NN")
-				}
+				column.AddConstraint(canvas.ConstraintNotNull)
 			}
 			if col.IsUnique {
-				if !containsStr(col.Constraints, "UNQ") {
-					constraints = append(constraints, "UNQ")
-				}
+				column.AddConstraint(canvas.ConstraintUnique)
 			}
 			if col.IsForeignKey {
-				if !containsStr(col.Constraints, "FK") {
-					constraints = append(constraints, "FK")
-				}
+				column.AddConstraint(canvas.ConstraintForeignKey)
 			}
 			// Add remaining constraints from column
 			for _, c := range col.Constraints {
-				if !containsStr(constraints, c) {
-					constraints = append(constraints, c)
+				column.AddConstraint(c)
+			}
+
+			columns = append(columns, column)
+		}
+
+		var indexes []canvas.Index
+		for j, idx := range table.Indexes {
+			index := canvas.Index{
+				ID:     fmt.Sprintf("idx_%d_%d", i, j),
+				Name:   idx.Name,
+				Unique: idx.Unique,
+				Where:  idx.Where,
+				Method: idx.Method,
+			}
+			for _, col := range idx.Columns {
+				colID, ok := lookupColumnID(tableColumnMap[table.Name], col.Name)
+				if !ok {
+					continue
 				}
+				indexCol := canvas.IndexColumn{ColumnID: colID}
+				if col.Descending {
+					indexCol.Order = canvas.OrderDesc
+				}
+				index.Columns = append(index.Columns, indexCol)
+			}
+			// Without all of its columns the index would mean something else
+			if len(index.Columns) == len(idx.Columns) {
+				indexes = append(indexes, index)
 			}
+		}
 
-			column := map[string]interface{}{
-				"id":           colID,
-				"name":         col.Name,
-				"type":         col.Type,
-				"isPrimaryKey": col.IsPrimaryKey,
-				"constraints":  constraints,
+		var checks []canvas.Check
+		for j, chk := range table.Checks {
+			check := canvas.Check{
+				ID:         fmt.Sprintf("chk_%d_%d", i, j),
+				Name:       chk.Name,
+				Expression: chk.Expression,
 			}
-			columns = append(columns, column)
+			if chk.Column != "" {
+				check.ColumnID, _ = lookupColumnID(tableColumnMap[table.Name], chk.Column)
+			}
+			checks = append(checks, check)
 		}
 
-		pos := positions[i]
-		node := map[string]interface{}{
-			"id":       nodeID,
-			"type":     "tableNode",
-			"position": map[string]interface{}{"x": pos.x, "y": pos.y},
-			"data": map[string]interface{}{
-				"name":    table.Name,
-				"columns": columns,
-			},
+		var composite *bool
+		if primaryKeys > 1 {
+			isComposite := true
+			composite = &isComposite
 		}
 
-		nodes = append(nodes, node)
+		pos := positions[i]
+		canvasData.Nodes = append(canvasData.Nodes, canvas.Node{
+			ID:       nodeID,
+			Type:     canvas.NodeTypeTable,
+			Position: &canvas.Position{X: pos.x, Y: pos.y},
+			Data: canvas.TableData{
+				Name:                table.Name,
+				Columns:             columns,
+				Indexes:             indexes,
+				Checks:              checks,
+				CompositePrimaryKey: composite,
+			},
+		})
 	}
 
 	// Create edges for foreign keys - deduplicate
 	edgeMap := make(map[string]bool)
 	edgeIDCounter := 0
 
+	// Edges run from the referenced table to the one holding the key
 	for _, fk := range foreignKeys {
-		sourceNodeID, sourceExists := tableToNodeID[fk.FromTable]
-		targetNodeID, targetExists := tableToNodeID[fk.ToTable]
+		sourceNodeID, sourceExists := tableToNodeID[fk.ToTable]
+		targetNodeID, targetExists := tableToNodeID[fk.FromTable]
 
 		if !sourceExists || !targetExists {
 			continue
 		}
 
-		sourceColID, sourceColExists := tableColumnMap[fk.FromTable][fk.FromColumn]
-		targetColID, targetColExists := tableColumnMap[fk.ToTable][fk.ToColumn]
-
-		if !sourceColExists || !targetColExists {
+		pairs := make([]canvas.EdgeColumn, 0, len(fk.FromColumns))
+		for k := range fk.FromColumns {
+			sourceColID, sourceColExists := lookupColumnID(tableColumnMap[fk.ToTable], fk.ToColumns[k])
+			targetColID, targetColExists := lookupColumnID(tableColumnMap[fk.FromTable], fk.FromColumns[k])
+			if !sourceColExists || !targetColExists {
+				break
+			}
+			pairs = append(pairs, canvas.EdgeColumn{Source: sourceColID, Target: targetColID})
+		}
+		if len(pairs) != len(fk.FromColumns) {
 			continue
 		}
 
 		// Create a unique key for this edge to avoid duplicates
-		edgeKey := fmt.Sprintf("%s.%s->%s.%s", fk.FromTable, fk.FromColumn, fk.ToTable, fk.ToColumn)
+		edgeKey := fmt.Sprintf("%s.(%s)->%s.(%s)",
+			fk.FromTable, strings.Join(fk.FromColumns, ","), fk.ToTable, strings.Join(fk.ToColumns, ","))
 		if edgeMap[edgeKey] {
 			continue
 		}
 		edgeMap[edgeKey] = true
 
-		edge := map[string]interface{}{
-			"id":           fmt.Sprintf("edge_%d", edgeIDCounter),
-			"source":       sourceNodeID,
-			"target":       targetNodeID,
-			"sourceHandle": fmt.Sprintf("%s-source", sourceColID),
-			"targetHandle": fmt.Sprintf("%s-target", targetColID),
-			"type":         "smoothstep",
-			"animated":     true,
-			"style": map[string]interface{}{
+		var data *canvas.EdgeData
+		if len(pairs) > 1 || fk.OnDelete != "" || fk.OnUpdate != "" || fk.Deferrable {
+			data = &canvas.EdgeData{
+				OnDelete:          canvasAction(fk.OnDelete),
+				OnUpdate:          canvasAction(fk.OnUpdate),
+				Deferrable:        fk.Deferrable,
+				InitiallyDeferred: fk.InitiallyDeferred,
+			}
+			if len(pairs) > 1 {
+				data.Columns = pairs
+			}
+		}
+
+		canvasData.Edges = append(canvasData.Edges, canvas.Edge{
+			ID:           fmt.Sprintf("edge_%d", edgeIDCounter),
+			Source:       sourceNodeID,
+			Target:       targetNodeID,
+			SourceHandle: canvas.SourceHandle(pairs[0].Source),
+			TargetHandle: canvas.TargetHandle(pairs[0].Target),
+			Type:         canvas.EdgeTypeSmoothStep,
+			Animated:     true,
+			Style: map[string]interface{}{
 				"stroke":      "#b4befe",
 				"strokeWidth": 2,
 			},
-			"label":     fmt.Sprintf("%s -> %s", fk.FromColumn, fk.ToColumn),
-			"labelStyle": map[string]interface{}{
+			Label: fmt.Sprintf("%s -> %s", strings.Join(fk.FromColumns, ", "), strings.Join(fk.ToColumns, ", ")),
+			LabelStyle: map[string]interface{}{
 				"fill":       "#cdd6f4",
 				"fontSize":   10,
 				"fontWeight": 500,
 			},
-			"labelBgStyle": map[string]interface{}{
-				"fill":   "#1e1e2e",
+			LabelBgStyle: map[string]interface{}{
+				"fill":        "#1e1e2e",
 				"fillOpacity": 0.8,
 			},
-		}
-
-		edges = append(edges, edge)
+			Data: data,
+		})
 		edgeIDCounter++
 	}
 
-	canvasData := map[string]interface{}{
-		"nodes": nodes,
-		"edges": edges,
-	}
-
 	return canvasData, nil
 }
 
+// lookupColumnID finds a column ID by name, falling back to a
+// case-insensitive match since SQL identifiers are case-insensitive
+func lookupColumnID(columns map[string]string, name string) (string, bool) {
+	if id, ok := columns[name]; ok {
+		return id, true
+	}
+	for colName, id := range columns {
+		if strings.EqualFold(colName, name) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
 type position struct {
 	x float64
 	y float64
@@ -197,19 +275,10 @@ func ImportSQL(sqlContent string) (json.RawMessage, error) {
 		return nil, fmt.Errorf("failed to convert to canvas: %w", err)
 	}
 
-	jsonData, err := json.Marshal(canvasData)
+	jsonData, err := canvas.Encode(canvasData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal canvas data: %w", err)
 	}
 
 	return json.RawMessage(jsonData), nil
 }
-
-func containsStr(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
-	}
-	return false
-}
@@ -0,0 +1,116 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/canvas"
+)
+
+// SQLiteDialect emits SQLite DDL. SQLite can't add foreign keys with ALTER
+// TABLE, so they are always declared inside CREATE TABLE; column types are
+// reduced to SQLite's storage affinities.
+type SQLiteDialect struct{}
+
+// GenerateSQLite generates SQLite DDL from canvas data
+func GenerateSQLite(jsonData []byte) (string, error) {
+	return GenerateDialectSQL(jsonData, SQLiteDialect{})
+}
+
+func (SQLiteDialect) Name() string { return "sqlite" }
+
+func (SQLiteDialect) Preamble() string { return "PRAGMA foreign_keys = ON;" }
+
+func (SQLiteDialect) QuoteIdent(name string) string { return quoteSQLite(name) }
+
+func (SQLiteDialect) ColumnType(col ColumnSchema, isKey bool) string {
+	return sqliteType(col.Type)
+}
+
+// ColumnDefault rewrites the current-time and UUID functions, which SQLite
+// lacks, and parenthesizes other expressions as SQLite requires
+func (SQLiteDialect) ColumnDefault(col ColumnSchema, colType string) string {
+	if col.Default == nil {
+		return ""
+	}
+	switch col.Default.Kind {
+	case canvas.DefaultLiteral:
+		return literalSQL(col.Default.Value, col.Type)
+	case canvas.DefaultExpression:
+		switch expr := strings.TrimSpace(col.Default.Value); {
+		case isTimestampExpression(expr):
+			return "CURRENT_TIMESTAMP"
+		case isUUIDExpression(expr):
+			return "(lower(hex(randomblob(16))))"
+		default:
+			return "(" + expr + ")"
+		}
+	}
+	return ""
+}
+
+// AutoIncrementClause declares the primary key inline, because AUTOINCREMENT
+// is only valid on a column declared INTEGER PRIMARY KEY.
+func (SQLiteDialect) AutoIncrementClause(colType string) (string, bool) {
+	if colType != "INTEGER" {
+		return "", false
+	}
+	return "PRIMARY KEY AUTOINCREMENT", true
+}
+
+func (SQLiteDialect) TableOptions() string { return "" }
+
+func (SQLiteDialect) ForeignKeyName(rel RelationSchema) string {
+	return PostgresDialect{}.ForeignKeyName(rel)
+}
+
+func (SQLiteDialect) ForeignKeyActions(rel RelationSchema) (string, []string) {
+	return referentialClause(rel.OnDelete, rel.OnUpdate, rel.Deferrable, rel.InitiallyDeferred), nil
+}
+
+func (SQLiteDialect) IndexName(table string, columns []string) string {
+	return PostgresDialect{}.IndexName(table, columns)
+}
+
+// CreateIndex ignores the access method: SQLite only has B-tree indexes
+func (d SQLiteDialect) CreateIndex(table string, index IndexSchema) string {
+	stmt := newIndexStatement(d, index.Name, table, index)
+	if index.Method != "" && index.Method != canvas.IndexMethodBTree {
+		stmt.Notes = append(stmt.Notes, fmt.Sprintf("SQLite has no %s indexes; created as a B-tree index", index.Method))
+	}
+	return stmt.String()
+}
+
+func (SQLiteDialect) SupportsAlterForeignKeys() bool { return false }
+
+func (SQLiteDialect) RequiresReferencedIndex() bool { return false }
+
+// quoteSQLite wraps an identifier in double quotes, escaping embedded quotes
+func quoteSQLite(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// sqliteType maps a canvas type onto one of SQLite's storage affinities
+// (INTEGER, TEXT, REAL, NUMERIC, BLOB) following SQLite's own affinity rules.
+func sqliteType(canvasType string) string {
+	t := strings.ToLower(strings.TrimSpace(canvasType))
+
+	switch {
+	case strings.Contains(t, "int"), strings.HasSuffix(t, "serial"):
+		return "INTEGER"
+	case t == "boolean", t == "bool":
+		return "INTEGER"
+	case strings.Contains(t, "char"), strings.Contains(t, "text"), strings.Contains(t, "clob"),
+		t == "uuid", t == "json", t == "jsonb":
+		return "TEXT"
+	case strings.HasPrefix(t, "timestamp"), t == "date", strings.HasPrefix(t, "time"), t == "datetime":
+		// Stored as ISO-8601 strings, which is what SQLite's date functions expect
+		return "TEXT"
+	case t == "bytea", t == "blob":
+		return "BLOB"
+	case strings.Contains(t, "real"), strings.Contains(t, "floa"), strings.Contains(t, "doub"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
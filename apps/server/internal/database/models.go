@@ -12,6 +12,12 @@ import (
 	"github.com/google/uuid"
 )
 
+type CollaborationMessage struct {
+	ID        int64     `json:"id"`
+	Payload   string    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type DatabaseConnection struct {
 	ID           uuid.UUID     `json:"id"`
 	UserID       uuid.UUID     `json:"user_id"`
@@ -48,16 +54,44 @@ type ProjectCollaborator struct {
 	CreatedAt time.Time `json:"created_at"`
 }
 
+type ProjectDocument struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	State     []byte    `json:"state"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ProjectLintRule struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	RuleID    string    `json:"rule_id"`
+	Enabled   bool      `json:"enabled"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
 type ProjectShareLink struct {
-	ID         uuid.UUID    `json:"id"`
-	ProjectID  uuid.UUID    `json:"project_id"`
-	Token      string       `json:"token"`
-	RoomKey    string       `json:"room_key"`
-	CreatedBy  uuid.UUID    `json:"created_by"`
-	ExpiresAt  sql.NullTime `json:"expires_at"`
-	RevokedAt  sql.NullTime `json:"revoked_at"`
-	CreatedAt  time.Time    `json:"created_at"`
-	LastUsedAt sql.NullTime `json:"last_used_at"`
+	ID         uuid.UUID      `json:"id"`
+	ProjectID  uuid.UUID      `json:"project_id"`
+	Token      string         `json:"token"`
+	RoomKey    string         `json:"room_key"`
+	CreatedBy  uuid.UUID      `json:"created_by"`
+	ExpiresAt  sql.NullTime   `json:"expires_at"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+	CreatedAt  time.Time      `json:"created_at"`
+	LastUsedAt sql.NullTime   `json:"last_used_at"`
+	Name       sql.NullString `json:"name"`
+	Role       string         `json:"role"`
+	MaxUses    sql.NullInt32  `json:"max_uses"`
+	UseCount   int32          `json:"use_count"`
+}
+
+type ProjectVersion struct {
+	ID            uuid.UUID       `json:"id"`
+	ProjectID     uuid.UUID       `json:"project_id"`
+	VersionNumber int32           `json:"version_number"`
+	Data          json.RawMessage `json:"data"`
+	ContentHash   string          `json:"content_hash"`
+	Message       sql.NullString  `json:"message"`
+	CreatedBy     uuid.NullUUID   `json:"created_by"`
+	CreatedAt     time.Time       `json:"created_at"`
 }
 
 type User struct {
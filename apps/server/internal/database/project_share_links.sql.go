@@ -12,18 +12,53 @@ import (
 	"github.com/google/uuid"
 )
 
+const claimShareLinkUse = `-- name: ClaimShareLinkUse :one
+UPDATE project_share_links
+SET use_count = use_count + 1,
+    last_used_at = NOW()
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+RETURNING id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at, name, role, max_uses, use_count
+`
+
+func (q *Queries) ClaimShareLinkUse(ctx context.Context, id uuid.UUID) (ProjectShareLink, error) {
+	row := q.db.QueryRowContext(ctx, claimShareLinkUse, id)
+	var i ProjectShareLink
+	err := row.Scan(
+		&i.ID,
+		&i.ProjectID,
+		&i.Token,
+		&i.RoomKey,
+		&i.CreatedBy,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.CreatedAt,
+		&i.LastUsedAt,
+		&i.Name,
+		&i.Role,
+		&i.MaxUses,
+		&i.UseCount,
+	)
+	return i, err
+}
+
 const createProjectShareLink = `-- name: CreateProjectShareLink :one
-INSERT INTO project_share_links (project_id, token, room_key, created_by, expires_at)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at
+INSERT INTO project_share_links (project_id, token, room_key, created_by, expires_at, name, role, max_uses)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+RETURNING id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at, name, role, max_uses, use_count
 `
 
 type CreateProjectShareLinkParams struct {
-	ProjectID uuid.UUID    `json:"project_id"`
-	Token     string       `json:"token"`
-	RoomKey   string       `json:"room_key"`
-	CreatedBy uuid.UUID    `json:"created_by"`
-	ExpiresAt sql.NullTime `json:"expires_at"`
+	ProjectID uuid.UUID      `json:"project_id"`
+	Token     string         `json:"token"`
+	RoomKey   string         `json:"room_key"`
+	CreatedBy uuid.UUID      `json:"created_by"`
+	ExpiresAt sql.NullTime   `json:"expires_at"`
+	Name      sql.NullString `json:"name"`
+	Role      string         `json:"role"`
+	MaxUses   sql.NullInt32  `json:"max_uses"`
 }
 
 func (q *Queries) CreateProjectShareLink(ctx context.Context, arg CreateProjectShareLinkParams) (ProjectShareLink, error) {
@@ -33,6 +68,9 @@ func (q *Queries) CreateProjectShareLink(ctx context.Context, arg CreateProjectS
 		arg.RoomKey,
 		arg.CreatedBy,
 		arg.ExpiresAt,
+		arg.Name,
+		arg.Role,
+		arg.MaxUses,
 	)
 	var i ProjectShareLink
 	err := row.Scan(
@@ -45,12 +83,16 @@ func (q *Queries) CreateProjectShareLink(ctx context.Context, arg CreateProjectS
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.LastUsedAt,
+		&i.Name,
+		&i.Role,
+		&i.MaxUses,
+		&i.UseCount,
 	)
 	return i, err
 }
 
 const getActiveShareLinkForProject = `-- name: GetActiveShareLinkForProject :one
-SELECT id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at
+SELECT id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at, name, role, max_uses, use_count
 FROM project_share_links
 WHERE project_id = $1
   AND revoked_at IS NULL
@@ -71,12 +113,45 @@ func (q *Queries) GetActiveShareLinkForProject(ctx context.Context, projectID uu
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.LastUsedAt,
+		&i.Name,
+		&i.Role,
+		&i.MaxUses,
+		&i.UseCount,
 	)
 	return i, err
 }
 
+const getProjectIDByRoomKey = `-- name: GetProjectIDByRoomKey :one
+SELECT project_id
+FROM project_share_links
+WHERE room_key = $1
+LIMIT 1
+`
+
+func (q *Queries) GetProjectIDByRoomKey(ctx context.Context, roomKey string) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, getProjectIDByRoomKey, roomKey)
+	var project_id uuid.UUID
+	err := row.Scan(&project_id)
+	return project_id, err
+}
+
+const getProjectRoomKey = `-- name: GetProjectRoomKey :one
+SELECT room_key
+FROM project_share_links
+WHERE project_id = $1
+ORDER BY created_at DESC
+LIMIT 1
+`
+
+func (q *Queries) GetProjectRoomKey(ctx context.Context, projectID uuid.UUID) (string, error) {
+	row := q.db.QueryRowContext(ctx, getProjectRoomKey, projectID)
+	var room_key string
+	err := row.Scan(&room_key)
+	return room_key, err
+}
+
 const getShareLinkByToken = `-- name: GetShareLinkByToken :one
-SELECT id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at
+SELECT id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at, name, role, max_uses, use_count
 FROM project_share_links
 WHERE token = $1
   AND revoked_at IS NULL
@@ -96,10 +171,80 @@ func (q *Queries) GetShareLinkByToken(ctx context.Context, token string) (Projec
 		&i.RevokedAt,
 		&i.CreatedAt,
 		&i.LastUsedAt,
+		&i.Name,
+		&i.Role,
+		&i.MaxUses,
+		&i.UseCount,
 	)
 	return i, err
 }
 
+const listShareLinksForProject = `-- name: ListShareLinksForProject :many
+SELECT id, project_id, token, room_key, created_by, expires_at, revoked_at, created_at, last_used_at, name, role, max_uses, use_count
+FROM project_share_links
+WHERE project_id = $1
+  AND revoked_at IS NULL
+ORDER BY created_at DESC
+`
+
+func (q *Queries) ListShareLinksForProject(ctx context.Context, projectID uuid.UUID) ([]ProjectShareLink, error) {
+	rows, err := q.db.QueryContext(ctx, listShareLinksForProject, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ProjectShareLink
+	for rows.Next() {
+		var i ProjectShareLink
+		if err := rows.Scan(
+			&i.ID,
+			&i.ProjectID,
+			&i.Token,
+			&i.RoomKey,
+			&i.CreatedBy,
+			&i.ExpiresAt,
+			&i.RevokedAt,
+			&i.CreatedAt,
+			&i.LastUsedAt,
+			&i.Name,
+			&i.Role,
+			&i.MaxUses,
+			&i.UseCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const revokeShareLink = `-- name: RevokeShareLink :execrows
+UPDATE project_share_links
+SET revoked_at = NOW()
+WHERE id = $1
+  AND project_id = $2
+  AND revoked_at IS NULL
+`
+
+type RevokeShareLinkParams struct {
+	ID        uuid.UUID `json:"id"`
+	ProjectID uuid.UUID `json:"project_id"`
+}
+
+func (q *Queries) RevokeShareLink(ctx context.Context, arg RevokeShareLinkParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeShareLink, arg.ID, arg.ProjectID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeShareLinksForProject = `-- name: RevokeShareLinksForProject :exec
 UPDATE project_share_links
 SET revoked_at = NOW()
@@ -111,3 +256,14 @@ func (q *Queries) RevokeShareLinksForProject(ctx context.Context, projectID uuid
 	_, err := q.db.ExecContext(ctx, revokeShareLinksForProject, projectID)
 	return err
 }
+
+const touchShareLink = `-- name: TouchShareLink :exec
+UPDATE project_share_links
+SET last_used_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) TouchShareLink(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, touchShareLink, id)
+	return err
+}
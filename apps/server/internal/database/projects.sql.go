@@ -80,6 +80,24 @@ func (q *Queries) DeleteProject(ctx context.Context, arg DeleteProjectParams) er
 	return err
 }
 
+const deleteProjectCollaborator = `-- name: DeleteProjectCollaborator :execrows
+DELETE FROM project_collaborators
+WHERE project_id = $1 AND user_id = $2
+`
+
+type DeleteProjectCollaboratorParams struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+func (q *Queries) DeleteProjectCollaborator(ctx context.Context, arg DeleteProjectCollaboratorParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteProjectCollaborator, arg.ProjectID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getProjectByID = `-- name: GetProjectByID :one
 SELECT id, user_id, name, description, data, is_public, last_saved_at, created_at, updated_at FROM projects WHERE id = $1
 `
@@ -231,6 +249,76 @@ func (q *Queries) GetProjectsByUser(ctx context.Context, userID uuid.UUID) ([]Ge
 	return items, nil
 }
 
+const listProjectCanvasesAfter = `-- name: ListProjectCanvasesAfter :many
+SELECT id, data FROM projects
+WHERE id > $1
+ORDER BY id
+LIMIT $2
+`
+
+type ListProjectCanvasesAfterParams struct {
+	ID    uuid.UUID `json:"id"`
+	Limit int32     `json:"limit"`
+}
+
+type ListProjectCanvasesAfterRow struct {
+	ID   uuid.UUID       `json:"id"`
+	Data json.RawMessage `json:"data"`
+}
+
+func (q *Queries) ListProjectCanvasesAfter(ctx context.Context, arg ListProjectCanvasesAfterParams) ([]ListProjectCanvasesAfterRow, error) {
+	rows, err := q.db.QueryContext(ctx, listProjectCanvasesAfter, arg.ID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListProjectCanvasesAfterRow
+	for rows.Next() {
+		var i ListProjectCanvasesAfterRow
+		if err := rows.Scan(&i.ID, &i.Data); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const lockProject = `-- name: LockProject :one
+SELECT id FROM projects WHERE id = $1 FOR UPDATE
+`
+
+func (q *Queries) LockProject(ctx context.Context, id uuid.UUID) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, lockProject, id)
+	err := row.Scan(&id)
+	return id, err
+}
+
+const replaceProjectCanvas = `-- name: ReplaceProjectCanvas :execrows
+UPDATE projects
+SET data = $1
+WHERE id = $2 AND data = $3
+`
+
+type ReplaceProjectCanvasParams struct {
+	Data     json.RawMessage `json:"data"`
+	ID       uuid.UUID       `json:"id"`
+	Previous json.RawMessage `json:"previous"`
+}
+
+func (q *Queries) ReplaceProjectCanvas(ctx context.Context, arg ReplaceProjectCanvasParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, replaceProjectCanvas, arg.Data, arg.ID, arg.Previous)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateProject = `-- name: UpdateProject :one
 UPDATE projects 
 SET name = $2,
@@ -270,6 +358,26 @@ func (q *Queries) UpdateProject(ctx context.Context, arg UpdateProjectParams) (P
 	return i, err
 }
 
+const updateProjectCollaboratorRole = `-- name: UpdateProjectCollaboratorRole :execrows
+UPDATE project_collaborators
+SET role = $3
+WHERE project_id = $1 AND user_id = $2
+`
+
+type UpdateProjectCollaboratorRoleParams struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Role      string    `json:"role"`
+}
+
+func (q *Queries) UpdateProjectCollaboratorRole(ctx context.Context, arg UpdateProjectCollaboratorRoleParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, updateProjectCollaboratorRole, arg.ProjectID, arg.UserID, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const updateProjectData = `-- name: UpdateProjectData :one
 UPDATE projects
 SET data = $2,
@@ -0,0 +1,17 @@
+// Package fanout carries collaboration messages between server instances so
+// a room's clients can be spread across them.
+package fanout
+
+import "context"
+
+// Backend delivers every published payload to every connected backend,
+// including the publisher's own. Payloads are opaque to the backend but must
+// be valid UTF-8, since Postgres notifications are text.
+type Backend interface {
+	// Publish sends payload to every subscriber
+	Publish(ctx context.Context, payload []byte) error
+	// Messages returns the channel payloads are delivered on. It is closed
+	// once the backend is closed.
+	Messages() <-chan []byte
+	Close() error
+}
@@ -0,0 +1,73 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var errClosed = errors.New("fanout: backend closed")
+
+// Memory is an in-process bus. Backends connected to the same Memory behave
+// like server instances sharing a database, which lets several hubs run side
+// by side in one process.
+type Memory struct {
+	mu          sync.RWMutex
+	subscribers map[*memoryBackend]bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{subscribers: make(map[*memoryBackend]bool)}
+}
+
+// Connect returns a backend attached to the bus
+func (m *Memory) Connect() Backend {
+	b := &memoryBackend{
+		bus:      m,
+		messages: make(chan []byte, 256),
+	}
+	m.mu.Lock()
+	m.subscribers[b] = true
+	m.mu.Unlock()
+	return b
+}
+
+type memoryBackend struct {
+	bus      *Memory
+	messages chan []byte
+	closed   bool
+}
+
+// Publish blocks until every subscriber has room for the payload or ctx is
+// done
+func (b *memoryBackend) Publish(ctx context.Context, payload []byte) error {
+	b.bus.mu.RLock()
+	defer b.bus.mu.RUnlock()
+	if b.closed {
+		return errClosed
+	}
+	for sub := range b.bus.subscribers {
+		select {
+		case sub.messages <- payload:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (b *memoryBackend) Messages() <-chan []byte {
+	return b.messages
+}
+
+func (b *memoryBackend) Close() error {
+	b.bus.mu.Lock()
+	defer b.bus.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	delete(b.bus.subscribers, b)
+	close(b.messages)
+	return nil
+}
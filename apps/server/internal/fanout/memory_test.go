@@ -0,0 +1,84 @@
+package fanout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func receive(t *testing.T, b Backend) []byte {
+	t.Helper()
+	select {
+	case payload, ok := <-b.Messages():
+		if !ok {
+			t.Fatal("messages channel closed")
+		}
+		return payload
+	case <-time.After(time.Second):
+		t.Fatal("no message delivered")
+		return nil
+	}
+}
+
+func TestMemoryDeliversToEverySubscriber(t *testing.T) {
+	bus := NewMemory()
+	a, b := bus.Connect(), bus.Connect()
+	defer a.Close()
+	defer b.Close()
+
+	if err := a.Publish(context.Background(), []byte("hello")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	for name, backend := range map[string]Backend{"publisher": a, "other": b} {
+		if got := string(receive(t, backend)); got != "hello" {
+			t.Errorf("%s received %q, want %q", name, got, "hello")
+		}
+	}
+}
+
+func TestMemoryClose(t *testing.T) {
+	bus := NewMemory()
+	a, b := bus.Connect(), bus.Connect()
+	defer b.Close()
+
+	if err := a.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("second close: %v", err)
+	}
+	if _, ok := <-a.Messages(); ok {
+		t.Fatal("messages channel still open after close")
+	}
+	if err := a.Publish(context.Background(), []byte("late")); !errors.Is(err, errClosed) {
+		t.Fatalf("publish after close returned %v, want %v", err, errClosed)
+	}
+
+	// The closed backend no longer receives, and the others are unaffected
+	if err := b.Publish(context.Background(), []byte("still here")); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if got := string(receive(t, b)); got != "still here" {
+		t.Errorf("received %q, want %q", got, "still here")
+	}
+}
+
+func TestMemoryPublishHonoursContextWhenSubscriberIsFull(t *testing.T) {
+	bus := NewMemory()
+	a, b := bus.Connect(), bus.Connect()
+	defer a.Close()
+	defer b.Close()
+
+	for i := 0; i < cap(b.(*memoryBackend).messages); i++ {
+		if err := a.Publish(context.Background(), []byte("fill")); err != nil {
+			t.Fatalf("publish %d: %v", i, err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := a.Publish(ctx, []byte("overflow")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("publish to a full subscriber returned %v, want %v", err, context.DeadlineExceeded)
+	}
+}
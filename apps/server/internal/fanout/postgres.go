@@ -0,0 +1,194 @@
+package fanout
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ASHUTOSH-SWAIN-GIT/skyforge/server/internal/database"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// maxNotifyPayload keeps notifications under Postgres' 8000 byte limit.
+	// Larger payloads are stored in collaboration_messages.
+	maxNotifyPayload = 7900
+	// spillRetention is how long stored payloads are kept for listeners
+	spillRetention = time.Minute
+
+	minReconnectDelay = time.Second
+	maxReconnectDelay = 30 * time.Second
+)
+
+// Notification payloads are prefixed with their kind
+const (
+	inlinePrefix = "i:"
+	spillPrefix  = "r:"
+)
+
+// Postgres fans messages out with LISTEN/NOTIFY. Notifications are sent
+// through the regular queries, while listening needs a dedicated session, so
+// its connection must not go through a transaction-mode pooler.
+type Postgres struct {
+	db       *database.Queries
+	config   *pgx.ConnConfig
+	channel  string
+	messages chan []byte
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	cleanupMu   sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewPostgres connects a listener on channel with config and starts
+// delivering notifications. The listener reconnects on its own if the
+// connection drops; notifications sent meanwhile are lost.
+func NewPostgres(ctx context.Context, config *pgx.ConnConfig, db *database.Queries, channel string) (*Postgres, error) {
+	p := &Postgres{
+		db:       db,
+		config:   config,
+		channel:  channel,
+		messages: make(chan []byte, 256),
+		done:     make(chan struct{}),
+	}
+
+	conn, err := p.listen(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.run(runCtx, conn)
+	return p, nil
+}
+
+func (p *Postgres) listen(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.ConnectConfig(ctx, p.config)
+	if err != nil {
+		return nil, fmt.Errorf("fanout: connect listener: %w", err)
+	}
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{p.channel}.Sanitize()); err != nil {
+		conn.Close(context.Background())
+		return nil, fmt.Errorf("fanout: listen on %s: %w", p.channel, err)
+	}
+	return conn, nil
+}
+
+func (p *Postgres) run(ctx context.Context, conn *pgx.Conn) {
+	defer close(p.done)
+	defer close(p.messages)
+
+	delay := minReconnectDelay
+	for {
+		if conn == nil {
+			var err error
+			if conn, err = p.listen(ctx); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Collaboration fan-out reconnect failed, retrying in %s: %v", delay, err)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return
+				}
+				delay = min(delay*2, maxReconnectDelay)
+				continue
+			}
+			delay = minReconnectDelay
+		}
+
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			conn.Close(context.Background())
+			conn = nil
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Collaboration fan-out listener lost: %v", err)
+			continue
+		}
+
+		payload, err := p.resolve(ctx, notification.Payload)
+		if err != nil {
+			log.Printf("Dropping collaboration notification: %v", err)
+			continue
+		}
+		select {
+		case p.messages <- payload:
+		case <-ctx.Done():
+			conn.Close(context.Background())
+			return
+		}
+	}
+}
+
+// resolve returns the payload a notification carries, loading it from
+// collaboration_messages if it was too large to send inline
+func (p *Postgres) resolve(ctx context.Context, notification string) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(notification, inlinePrefix):
+		return []byte(notification[len(inlinePrefix):]), nil
+	case strings.HasPrefix(notification, spillPrefix):
+		id, err := strconv.ParseInt(notification[len(spillPrefix):], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		payload, err := p.db.GetCollaborationMessage(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("load message %d: %w", id, err)
+		}
+		return []byte(payload), nil
+	default:
+		return nil, fmt.Errorf("unknown notification %.20q", notification)
+	}
+}
+
+func (p *Postgres) Publish(ctx context.Context, payload []byte) error {
+	notification := inlinePrefix + string(payload)
+	if len(notification) > maxNotifyPayload {
+		id, err := p.db.CreateCollaborationMessage(ctx, string(payload))
+		if err != nil {
+			return fmt.Errorf("fanout: store message: %w", err)
+		}
+		notification = spillPrefix + strconv.FormatInt(id, 10)
+		p.cleanup(ctx)
+	}
+
+	return p.db.NotifyCollaborationChannel(ctx, database.NotifyCollaborationChannelParams{
+		Channel: p.channel,
+		Payload: notification,
+	})
+}
+
+// cleanup removes stored payloads every listener has had time to load
+func (p *Postgres) cleanup(ctx context.Context) {
+	p.cleanupMu.Lock()
+	if time.Since(p.lastCleanup) < spillRetention {
+		p.cleanupMu.Unlock()
+		return
+	}
+	p.lastCleanup = time.Now()
+	p.cleanupMu.Unlock()
+
+	if err := p.db.DeleteCollaborationMessagesBefore(ctx, time.Now().Add(-spillRetention)); err != nil {
+		log.Printf("Failed to clean up collaboration messages: %v", err)
+	}
+}
+
+func (p *Postgres) Messages() <-chan []byte {
+	return p.messages
+}
+
+// Close stops the listener and waits for it to disconnect
+func (p *Postgres) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
@@ -0,0 +1,39 @@
+package yjs
+
+import "encoding/json"
+
+// AwarenessState is one client's entry in an awareness update. State is nil
+// once the client has gone offline.
+type AwarenessState struct {
+	ClientID uint64
+	Clock    uint64
+	State    json.RawMessage
+}
+
+// DecodeAwarenessUpdate decodes the payload of an awareness message
+func DecodeAwarenessUpdate(update []byte) ([]AwarenessState, error) {
+	dec := newDecoder(update)
+	n, err := dec.readUint()
+	if err != nil {
+		return nil, err
+	}
+	states := make([]AwarenessState, 0, min(n, 64))
+	for i := uint64(0); i < n; i++ {
+		var s AwarenessState
+		if s.ClientID, err = dec.readUint(); err != nil {
+			return nil, err
+		}
+		if s.Clock, err = dec.readUint(); err != nil {
+			return nil, err
+		}
+		raw, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		if raw != "null" {
+			s.State = json.RawMessage(raw)
+		}
+		states = append(states, s)
+	}
+	return states, nil
+}
@@ -0,0 +1,274 @@
+package yjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf16"
+)
+
+// Content refs as written in the low five bits of an item's info byte
+const (
+	refGC       = 0
+	refDeleted  = 1
+	refJSON     = 2
+	refBinary   = 3
+	refString   = 4
+	refEmbed    = 5
+	refFormat   = 6
+	refType     = 7
+	refAny      = 8
+	refDoc      = 9
+	refSkip     = 10
+	refBitsMask = 0x1f
+)
+
+// Shared type refs of ContentType
+const (
+	typeArray       = 0
+	typeMap         = 1
+	typeText        = 2
+	typeXMLElement  = 3
+	typeXMLFragment = 4
+	typeXMLHook     = 5
+	typeXMLText     = 6
+)
+
+// content is the payload of an item. Contents longer than one can be split.
+type content interface {
+	ref() byte
+	length() uint64
+	countable() bool
+	// splice keeps the first offset elements and returns the rest
+	splice(offset uint64) content
+	write(e *encoder, offset uint64)
+	// values returns the JSON values the content contributes to its parent
+	values() []interface{}
+}
+
+func readContent(d *decoder, info byte) (content, error) {
+	switch info & refBitsMask {
+	case refDeleted:
+		n, err := d.readUint()
+		return &contentDeleted{n: n}, err
+	case refJSON:
+		n, err := d.readUint()
+		if err != nil {
+			return nil, err
+		}
+		c := &contentJSON{items: make([]string, 0, min(n, 64))}
+		for i := uint64(0); i < n; i++ {
+			s, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			c.items = append(c.items, s)
+		}
+		return c, nil
+	case refBinary:
+		b, err := d.readBytes()
+		return &contentBinary{data: b}, err
+	case refString:
+		s, err := d.readString()
+		return &contentString{units: utf16.Encode([]rune(s))}, err
+	case refEmbed:
+		s, err := d.readString()
+		return &contentEmbed{raw: s}, err
+	case refFormat:
+		key, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		s, err := d.readString()
+		return &contentFormat{key: key, raw: s}, err
+	case refType:
+		typeRef, err := d.readUint()
+		if err != nil {
+			return nil, err
+		}
+		c := &contentType{typ: newType(typeRef)}
+		if typeRef == typeXMLElement || typeRef == typeXMLHook {
+			if c.key, err = d.readString(); err != nil {
+				return nil, err
+			}
+		}
+		return c, nil
+	case refAny:
+		n, err := d.readUint()
+		if err != nil {
+			return nil, err
+		}
+		c := &contentAny{items: make([]anyValue, 0, min(n, 64))}
+		for i := uint64(0); i < n; i++ {
+			raw, v, err := d.readAny()
+			if err != nil {
+				return nil, err
+			}
+			c.items = append(c.items, anyValue{raw: raw, value: v})
+		}
+		return c, nil
+	case refDoc:
+		guid, err := d.readString()
+		if err != nil {
+			return nil, err
+		}
+		raw, _, err := d.readAny()
+		return &contentDoc{guid: guid, opts: raw}, err
+	default:
+		return nil, fmt.Errorf("yjs: unknown content ref %d", info&refBitsMask)
+	}
+}
+
+type contentDeleted struct{ n uint64 }
+
+func (c *contentDeleted) ref() byte       { return refDeleted }
+func (c *contentDeleted) length() uint64  { return c.n }
+func (c *contentDeleted) countable() bool { return false }
+func (c *contentDeleted) splice(offset uint64) content {
+	right := &contentDeleted{n: c.n - offset}
+	c.n = offset
+	return right
+}
+func (c *contentDeleted) write(e *encoder, offset uint64) { e.writeUint(c.n - offset) }
+func (c *contentDeleted) values() []interface{}           { return nil }
+
+type contentJSON struct{ items []string }
+
+func (c *contentJSON) ref() byte       { return refJSON }
+func (c *contentJSON) length() uint64  { return uint64(len(c.items)) }
+func (c *contentJSON) countable() bool { return true }
+func (c *contentJSON) splice(offset uint64) content {
+	right := &contentJSON{items: c.items[offset:]}
+	c.items = c.items[:offset:offset]
+	return right
+}
+func (c *contentJSON) write(e *encoder, offset uint64) {
+	e.writeUint(uint64(len(c.items)) - offset)
+	for _, s := range c.items[offset:] {
+		e.writeString(s)
+	}
+}
+func (c *contentJSON) values() []interface{} {
+	vals := make([]interface{}, len(c.items))
+	for i, s := range c.items {
+		if s != "undefined" {
+			_ = json.Unmarshal([]byte(s), &vals[i])
+		}
+	}
+	return vals
+}
+
+type contentBinary struct{ data []byte }
+
+func (c *contentBinary) ref() byte                       { return refBinary }
+func (c *contentBinary) length() uint64                  { return 1 }
+func (c *contentBinary) countable() bool                 { return true }
+func (c *contentBinary) splice(uint64) content           { panic("yjs: binary content can't be split") }
+func (c *contentBinary) write(e *encoder, offset uint64) { e.writeBytes(c.data) }
+func (c *contentBinary) values() []interface{}           { return []interface{}{c.data} }
+
+// contentString measures its length in UTF-16 code units like JavaScript
+type contentString struct{ units []uint16 }
+
+func (c *contentString) ref() byte       { return refString }
+func (c *contentString) length() uint64  { return uint64(len(c.units)) }
+func (c *contentString) countable() bool { return true }
+func (c *contentString) splice(offset uint64) content {
+	right := &contentString{units: c.units[offset:]}
+	c.units = c.units[:offset:offset]
+	return right
+}
+func (c *contentString) write(e *encoder, offset uint64) {
+	e.writeString(string(utf16.Decode(c.units[offset:])))
+}
+func (c *contentString) values() []interface{} {
+	return []interface{}{string(utf16.Decode(c.units))}
+}
+
+type contentEmbed struct{ raw string }
+
+func (c *contentEmbed) ref() byte                       { return refEmbed }
+func (c *contentEmbed) length() uint64                  { return 1 }
+func (c *contentEmbed) countable() bool                 { return true }
+func (c *contentEmbed) splice(uint64) content           { panic("yjs: embed content can't be split") }
+func (c *contentEmbed) write(e *encoder, offset uint64) { e.writeString(c.raw) }
+func (c *contentEmbed) values() []interface{} {
+	var v interface{}
+	_ = json.Unmarshal([]byte(c.raw), &v)
+	return []interface{}{v}
+}
+
+type contentFormat struct {
+	key string
+	raw string
+}
+
+func (c *contentFormat) ref() byte             { return refFormat }
+func (c *contentFormat) length() uint64        { return 1 }
+func (c *contentFormat) countable() bool       { return false }
+func (c *contentFormat) splice(uint64) content { panic("yjs: format content can't be split") }
+func (c *contentFormat) write(e *encoder, offset uint64) {
+	e.writeString(c.key)
+	e.writeString(c.raw)
+}
+func (c *contentFormat) values() []interface{} { return nil }
+
+type contentType struct {
+	typ *ytype
+	key string
+}
+
+func (c *contentType) ref() byte             { return refType }
+func (c *contentType) length() uint64        { return 1 }
+func (c *contentType) countable() bool       { return true }
+func (c *contentType) splice(uint64) content { panic("yjs: type content can't be split") }
+func (c *contentType) write(e *encoder, offset uint64) {
+	e.writeUint(c.typ.ref)
+	if c.typ.ref == typeXMLElement || c.typ.ref == typeXMLHook {
+		e.writeString(c.key)
+	}
+}
+func (c *contentType) values() []interface{} { return []interface{}{c.typ.toJSON()} }
+
+type anyValue struct {
+	raw   []byte
+	value interface{}
+}
+
+type contentAny struct{ items []anyValue }
+
+func (c *contentAny) ref() byte       { return refAny }
+func (c *contentAny) length() uint64  { return uint64(len(c.items)) }
+func (c *contentAny) countable() bool { return true }
+func (c *contentAny) splice(offset uint64) content {
+	right := &contentAny{items: c.items[offset:]}
+	c.items = c.items[:offset:offset]
+	return right
+}
+func (c *contentAny) write(e *encoder, offset uint64) {
+	e.writeUint(uint64(len(c.items)) - offset)
+	for _, v := range c.items[offset:] {
+		e.writeRaw(v.raw)
+	}
+}
+func (c *contentAny) values() []interface{} {
+	vals := make([]interface{}, len(c.items))
+	for i, v := range c.items {
+		vals[i] = v.value
+	}
+	return vals
+}
+
+type contentDoc struct {
+	guid string
+	opts []byte
+}
+
+func (c *contentDoc) ref() byte             { return refDoc }
+func (c *contentDoc) length() uint64        { return 1 }
+func (c *contentDoc) countable() bool       { return true }
+func (c *contentDoc) splice(uint64) content { panic("yjs: doc content can't be split") }
+func (c *contentDoc) write(e *encoder, offset uint64) {
+	e.writeString(c.guid)
+	e.writeRaw(c.opts)
+}
+func (c *contentDoc) values() []interface{} { return []interface{}{nil} }
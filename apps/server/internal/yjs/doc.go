@@ -0,0 +1,550 @@
+// Package yjs implements enough of the Yjs CRDT to hold an authoritative copy
+// of a collaborative document on the server: it decodes and integrates v1
+// updates, answers state vector requests and materializes shared types as
+// JSON. Beyond editing root arrays of JSON values with EncodeArrayEdits, it
+// doesn't generate local edits.
+package yjs
+
+import (
+	"errors"
+	"slices"
+	"sort"
+)
+
+var errStructNotFound = errors.New("yjs: referenced struct not found")
+
+// ID identifies the first element of a struct: the client that created it
+// and that client's clock at the time
+type ID struct {
+	Client uint64
+	Clock  uint64
+}
+
+type structKind uint8
+
+const (
+	kindItem structKind = iota
+	kindGC
+	kindSkip
+)
+
+// item is a Yjs struct. GC and Skip structs only use id and length.
+type item struct {
+	id     ID
+	length uint64
+	kind   structKind
+
+	origin      *ID
+	rightOrigin *ID
+	left, right *item
+
+	// parent is resolved on integration; until then an item whose parent is
+	// a nested type only knows that type's item ID
+	parent    *ytype
+	parentID  *ID
+	parentSub *string
+
+	content content
+	deleted bool
+}
+
+func (it *item) lastID() ID {
+	return ID{Client: it.id.Client, Clock: it.id.Clock + it.length - 1}
+}
+
+// ytype is a shared type: a sequence of items and, for maps, the current
+// item of every key
+type ytype struct {
+	ref     uint64
+	name    string // root types only
+	item    *item  // nil for root types
+	start   *item
+	entries map[string]*item
+}
+
+func newType(ref uint64) *ytype {
+	return &ytype{ref: ref, entries: make(map[string]*item)}
+}
+
+func (t *ytype) values() []interface{} {
+	vals := []interface{}{}
+	for it := t.start; it != nil; it = it.right {
+		if !it.deleted && it.content.countable() {
+			vals = append(vals, it.content.values()...)
+		}
+	}
+	return vals
+}
+
+func (t *ytype) toJSON() interface{} {
+	switch t.ref {
+	case typeArray:
+		return t.values()
+	case typeMap:
+		m := make(map[string]interface{}, len(t.entries))
+		for key, it := range t.entries {
+			if vals := it.content.values(); !it.deleted && len(vals) > 0 {
+				m[key] = vals[len(vals)-1]
+			}
+		}
+		return m
+	case typeText, typeXMLText:
+		s := ""
+		for _, v := range t.values() {
+			if str, ok := v.(string); ok {
+				s += str
+			}
+		}
+		return s
+	default:
+		return nil
+	}
+}
+
+type deleteRange struct {
+	client uint64
+	clock  uint64
+	length uint64
+}
+
+// Doc is a server-side Yjs document. It isn't safe for concurrent use.
+type Doc struct {
+	roots   map[string]*ytype
+	clients map[uint64][]*item
+
+	// pending holds structs and deletions whose dependencies haven't arrived
+	pending        map[uint64][]*item
+	pendingDeletes []deleteRange
+}
+
+func NewDoc() *Doc {
+	return &Doc{
+		roots:   make(map[string]*ytype),
+		clients: make(map[uint64][]*item),
+		pending: make(map[uint64][]*item),
+	}
+}
+
+// root returns the root type with the given name, creating it if needed.
+// Root types are untyped until read; Array reads them as arrays.
+func (d *Doc) root(name string) *ytype {
+	t, ok := d.roots[name]
+	if !ok {
+		t = newType(typeArray)
+		t.name = name
+		d.roots[name] = t
+	}
+	return t
+}
+
+// Array returns the JSON values of a root array. ok is false when no update
+// has touched the root yet.
+func (d *Doc) Array(name string) (values []interface{}, ok bool) {
+	t, ok := d.roots[name]
+	if !ok {
+		return nil, false
+	}
+	return t.values(), true
+}
+
+// state is the next expected clock of a client
+func (d *Doc) state(client uint64) uint64 {
+	structs := d.clients[client]
+	if len(structs) == 0 {
+		return 0
+	}
+	last := structs[len(structs)-1]
+	return last.id.Clock + last.length
+}
+
+func findIndex(structs []*item, clock uint64) (int, error) {
+	lo, hi := 0, len(structs)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		s := structs[mid]
+		switch {
+		case clock < s.id.Clock:
+			hi = mid - 1
+		case clock >= s.id.Clock+s.length:
+			lo = mid + 1
+		default:
+			return mid, nil
+		}
+	}
+	return 0, errStructNotFound
+}
+
+func (d *Doc) getItem(id ID) (*item, error) {
+	structs := d.clients[id.Client]
+	i, err := findIndex(structs, id.Clock)
+	if err != nil {
+		return nil, err
+	}
+	return structs[i], nil
+}
+
+// getItemCleanStart returns the item starting at id, splitting the item that
+// contains it if needed
+func (d *Doc) getItemCleanStart(id ID) (*item, error) {
+	structs := d.clients[id.Client]
+	i, err := findIndex(structs, id.Clock)
+	if err != nil {
+		return nil, err
+	}
+	s := structs[i]
+	if s.id.Clock < id.Clock && s.kind == kindItem {
+		right := d.splitItem(s, id.Clock-s.id.Clock)
+		d.clients[id.Client] = slices.Insert(structs, i+1, right)
+		return right, nil
+	}
+	return s, nil
+}
+
+// getItemCleanEnd returns the item ending at id, splitting the item that
+// contains it if needed
+func (d *Doc) getItemCleanEnd(id ID) (*item, error) {
+	structs := d.clients[id.Client]
+	i, err := findIndex(structs, id.Clock)
+	if err != nil {
+		return nil, err
+	}
+	s := structs[i]
+	if id.Clock != s.id.Clock+s.length-1 && s.kind == kindItem {
+		d.clients[id.Client] = slices.Insert(structs, i+1, d.splitItem(s, id.Clock-s.id.Clock+1))
+	}
+	return s, nil
+}
+
+// splitItem cuts left after diff elements and returns the right half. The
+// caller inserts the right half into the struct store.
+func (d *Doc) splitItem(left *item, diff uint64) *item {
+	right := &item{
+		id:          ID{Client: left.id.Client, Clock: left.id.Clock + diff},
+		kind:        kindItem,
+		origin:      &ID{Client: left.id.Client, Clock: left.id.Clock + diff - 1},
+		rightOrigin: left.rightOrigin,
+		left:        left,
+		right:       left.right,
+		parent:      left.parent,
+		parentSub:   left.parentSub,
+		content:     left.content.splice(diff),
+		deleted:     left.deleted,
+	}
+	right.length = right.content.length()
+	left.length = diff
+	left.right = right
+	if right.right != nil {
+		right.right.left = right
+	}
+	if right.parentSub != nil && right.right == nil {
+		right.parent.entries[*right.parentSub] = right
+	}
+	return right
+}
+
+// missing resolves an item's origins and parent, or reports the client whose
+// structs must be integrated first
+func (d *Doc) missing(it *item) (uint64, bool, error) {
+	if it.kind != kindItem {
+		return 0, false, nil
+	}
+	if it.origin != nil && it.origin.Client != it.id.Client && it.origin.Clock >= d.state(it.origin.Client) {
+		return it.origin.Client, true, nil
+	}
+	if it.rightOrigin != nil && it.rightOrigin.Client != it.id.Client && it.rightOrigin.Clock >= d.state(it.rightOrigin.Client) {
+		return it.rightOrigin.Client, true, nil
+	}
+	if it.parentID != nil && it.parentID.Client != it.id.Client && it.parentID.Clock >= d.state(it.parentID.Client) {
+		return it.parentID.Client, true, nil
+	}
+
+	if it.origin != nil {
+		left, err := d.getItemCleanEnd(*it.origin)
+		if err != nil {
+			return 0, false, err
+		}
+		it.left = left
+		origin := left.lastID()
+		it.origin = &origin
+	}
+	if it.rightOrigin != nil {
+		right, err := d.getItemCleanStart(*it.rightOrigin)
+		if err != nil {
+			return 0, false, err
+		}
+		it.right = right
+		rightOrigin := right.id
+		it.rightOrigin = &rightOrigin
+	}
+
+	switch {
+	case (it.left != nil && it.left.kind == kindGC) || (it.right != nil && it.right.kind == kindGC):
+		it.parent, it.parentID = nil, nil
+	case it.parentID != nil:
+		parent, err := d.getItem(*it.parentID)
+		if err != nil {
+			return 0, false, err
+		}
+		it.parent, it.parentID = nil, nil
+		if ct, ok := parent.content.(*contentType); ok && parent.kind == kindItem {
+			it.parent = ct.typ
+		}
+	case it.parent == nil:
+		if it.left != nil && it.left.kind == kindItem {
+			it.parent, it.parentSub = it.left.parent, it.left.parentSub
+		} else if it.right != nil && it.right.kind == kindItem {
+			it.parent, it.parentSub = it.right.parent, it.right.parentSub
+		}
+	}
+	return 0, false, nil
+}
+
+// integrate inserts a struct whose dependencies are present. offset skips
+// elements the document already has.
+func (d *Doc) integrate(it *item, offset uint64) error {
+	if it.kind == kindGC {
+		it.id.Clock += offset
+		it.length -= offset
+		d.clients[it.id.Client] = append(d.clients[it.id.Client], it)
+		return nil
+	}
+
+	if offset > 0 {
+		it.id.Clock += offset
+		left, err := d.getItemCleanEnd(ID{Client: it.id.Client, Clock: it.id.Clock - 1})
+		if err != nil {
+			return err
+		}
+		it.left = left
+		origin := left.lastID()
+		it.origin = &origin
+		it.content = it.content.splice(offset)
+		it.length -= offset
+	}
+
+	if it.parent == nil {
+		// The parent was garbage collected, keep only the clock range
+		gc := &item{id: it.id, length: it.length, kind: kindGC, deleted: true}
+		d.clients[it.id.Client] = append(d.clients[it.id.Client], gc)
+		return nil
+	}
+
+	parent := it.parent
+	if (it.left == nil && (it.right == nil || it.right.left != nil)) || (it.left != nil && it.left.right != it.right) {
+		left := it.left
+		var o *item
+		switch {
+		case left != nil:
+			o = left.right
+		case it.parentSub != nil:
+			o = parent.entries[*it.parentSub]
+			for o != nil && o.left != nil {
+				o = o.left
+			}
+		default:
+			o = parent.start
+		}
+
+		// Resolve concurrent insertions between left and right the way every
+		// other peer does (YATA)
+		conflicting := make(map[*item]bool)
+		beforeOrigin := make(map[*item]bool)
+		for o != nil && o != it.right {
+			beforeOrigin[o] = true
+			conflicting[o] = true
+			if sameID(it.origin, o.origin) {
+				if o.id.Client < it.id.Client {
+					left = o
+					clear(conflicting)
+				} else if sameID(it.rightOrigin, o.rightOrigin) {
+					break
+				}
+			} else if o.origin != nil {
+				originItem, err := d.getItem(*o.origin)
+				if err != nil {
+					return err
+				}
+				if !beforeOrigin[originItem] {
+					break
+				}
+				if !conflicting[originItem] {
+					left = o
+					clear(conflicting)
+				}
+			} else {
+				break
+			}
+			o = o.right
+		}
+		it.left = left
+	}
+
+	if it.left != nil {
+		it.right = it.left.right
+		it.left.right = it
+	} else {
+		var r *item
+		if it.parentSub != nil {
+			r = parent.entries[*it.parentSub]
+			for r != nil && r.left != nil {
+				r = r.left
+			}
+		} else {
+			r = parent.start
+			parent.start = it
+		}
+		it.right = r
+	}
+	if it.right != nil {
+		it.right.left = it
+	} else if it.parentSub != nil {
+		// This is the key's current value, the previous one is overwritten
+		parent.entries[*it.parentSub] = it
+		if it.left != nil {
+			d.deleteItem(it.left)
+		}
+	}
+
+	d.clients[it.id.Client] = append(d.clients[it.id.Client], it)
+
+	switch c := it.content.(type) {
+	case *contentType:
+		c.typ.item = it
+	case *contentDeleted:
+		it.deleted = true
+	}
+
+	if (parent.item != nil && parent.item.deleted) || (it.parentSub != nil && it.right != nil) {
+		d.deleteItem(it)
+	}
+	return nil
+}
+
+// deleteItem marks an item deleted and drops its content, keeping only its
+// length. Deleting a shared type deletes its children instead.
+func (d *Doc) deleteItem(it *item) {
+	if it.deleted {
+		return
+	}
+	it.deleted = true
+	if ct, ok := it.content.(*contentType); ok {
+		for child := ct.typ.start; child != nil; child = child.right {
+			d.deleteItem(child)
+		}
+		for _, child := range ct.typ.entries {
+			d.deleteItem(child)
+		}
+		return
+	}
+	it.content = &contentDeleted{n: it.length}
+}
+
+// ApplyUpdate integrates a v1 update. Structs and deletions that depend on
+// updates not seen yet are kept and retried with later updates.
+func (d *Doc) ApplyUpdate(update []byte) error {
+	queues, deletes, err := d.decodeUpdate(update)
+	if err != nil {
+		return err
+	}
+
+	for client, structs := range d.pending {
+		queues[client] = append(queues[client], structs...)
+	}
+	d.pending = make(map[uint64][]*item)
+	for _, structs := range queues {
+		sort.SliceStable(structs, func(i, j int) bool { return structs[i].id.Clock < structs[j].id.Clock })
+	}
+
+	if err := d.integrateStructs(queues); err != nil {
+		return err
+	}
+
+	d.pendingDeletes, err = d.applyDeleteSet(append(d.pendingDeletes, deletes...))
+	return err
+}
+
+func (d *Doc) integrateStructs(queues map[uint64][]*item) error {
+	for progress := true; progress; {
+		progress = false
+		for client, queue := range queues {
+			for len(queue) > 0 {
+				s := queue[0]
+				state := d.state(client)
+				if s.kind == kindSkip || s.id.Clock+s.length <= state {
+					queue = queue[1:]
+					progress = true
+					continue
+				}
+				if s.id.Clock > state {
+					break
+				}
+				if _, blocked, err := d.missing(s); err != nil {
+					return err
+				} else if blocked {
+					break
+				}
+				if err := d.integrate(s, state-s.id.Clock); err != nil {
+					return err
+				}
+				queue = queue[1:]
+				progress = true
+			}
+			queues[client] = queue
+		}
+	}
+
+	for client, queue := range queues {
+		if len(queue) > 0 {
+			d.pending[client] = queue
+		}
+	}
+	return nil
+}
+
+// applyDeleteSet deletes the given ranges and returns the parts that refer to
+// structs the document doesn't have yet
+func (d *Doc) applyDeleteSet(ranges []deleteRange) ([]deleteRange, error) {
+	var unapplied []deleteRange
+	for _, r := range ranges {
+		state := d.state(r.client)
+		end := r.clock + r.length
+		if r.clock >= state {
+			unapplied = append(unapplied, r)
+			continue
+		}
+		if state < end {
+			unapplied = append(unapplied, deleteRange{client: r.client, clock: state, length: end - state})
+		}
+
+		structs := d.clients[r.client]
+		i, err := findIndex(structs, r.clock)
+		if err != nil {
+			return nil, err
+		}
+		if s := structs[i]; !s.deleted && s.id.Clock < r.clock {
+			structs = slices.Insert(structs, i+1, d.splitItem(s, r.clock-s.id.Clock))
+			i++
+		}
+		for ; i < len(structs); i++ {
+			s := structs[i]
+			if s.id.Clock >= end {
+				break
+			}
+			if !s.deleted {
+				if end < s.id.Clock+s.length {
+					structs = slices.Insert(structs, i+1, d.splitItem(s, end-s.id.Clock))
+				}
+				d.deleteItem(s)
+			}
+		}
+		d.clients[r.client] = structs
+	}
+	return unapplied, nil
+}
+
+func sameID(a, b *ID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,288 @@
+package yjs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var errUnexpectedEOF = errors.New("yjs: unexpected end of data")
+
+// decoder reads the lib0 binary encoding used by Yjs
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func newDecoder(buf []byte) *decoder {
+	return &decoder{buf: buf}
+}
+
+func (d *decoder) hasContent() bool {
+	return d.pos < len(d.buf)
+}
+
+func (d *decoder) readByte() (byte, error) {
+	if d.pos >= len(d.buf) {
+		return 0, errUnexpectedEOF
+	}
+	b := d.buf[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) readN(n uint64) ([]byte, error) {
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, errUnexpectedEOF
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// readUint reads an unsigned varint
+func (d *decoder) readUint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf[d.pos:])
+	if n <= 0 {
+		return 0, errUnexpectedEOF
+	}
+	d.pos += n
+	return v, nil
+}
+
+// readInt reads lib0's signed varint: the first byte carries a continuation
+// bit, a sign bit and six bits of the value.
+func (d *decoder) readInt() (int64, error) {
+	b, err := d.readByte()
+	if err != nil {
+		return 0, err
+	}
+	num := uint64(b & 0x3f)
+	negative := b&0x40 != 0
+	shift := uint(6)
+	for b&0x80 != 0 {
+		if b, err = d.readByte(); err != nil {
+			return 0, err
+		}
+		num |= uint64(b&0x7f) << shift
+		shift += 7
+		if shift > 63 {
+			return 0, errors.New("yjs: varint overflow")
+		}
+	}
+	if negative {
+		return -int64(num), nil
+	}
+	return int64(num), nil
+}
+
+// readBytes reads a length-prefixed byte array
+func (d *decoder) readBytes() ([]byte, error) {
+	n, err := d.readUint()
+	if err != nil {
+		return nil, err
+	}
+	return d.readN(n)
+}
+
+func (d *decoder) readString() (string, error) {
+	b, err := d.readBytes()
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// readAny reads a lib0 "any" value and returns it together with its raw
+// encoding so it can be written back byte for byte.
+func (d *decoder) readAny() ([]byte, interface{}, error) {
+	start := d.pos
+	value, err := d.readAnyValue()
+	if err != nil {
+		return nil, nil, err
+	}
+	return d.buf[start:d.pos], value, nil
+}
+
+func (d *decoder) readAnyValue() (interface{}, error) {
+	tag, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	switch tag {
+	case 127, 126: // undefined, null
+		return nil, nil
+	case 125:
+		return d.readInt()
+	case 124:
+		b, err := d.readN(4)
+		if err != nil {
+			return nil, err
+		}
+		return finiteOrNil(float64(math.Float32frombits(binary.BigEndian.Uint32(b)))), nil
+	case 123:
+		b, err := d.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		return finiteOrNil(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+	case 122:
+		b, err := d.readN(8)
+		if err != nil {
+			return nil, err
+		}
+		return int64(binary.BigEndian.Uint64(b)), nil
+	case 121:
+		return false, nil
+	case 120:
+		return true, nil
+	case 119:
+		return d.readString()
+	case 118:
+		n, err := d.readUint()
+		if err != nil {
+			return nil, err
+		}
+		obj := make(map[string]interface{}, min(n, 64))
+		for i := uint64(0); i < n; i++ {
+			key, err := d.readString()
+			if err != nil {
+				return nil, err
+			}
+			if obj[key], err = d.readAnyValue(); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	case 117:
+		n, err := d.readUint()
+		if err != nil {
+			return nil, err
+		}
+		arr := make([]interface{}, 0, min(n, 64))
+		for i := uint64(0); i < n; i++ {
+			v, err := d.readAnyValue()
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+		return arr, nil
+	case 116:
+		return d.readBytes()
+	default:
+		return nil, fmt.Errorf("yjs: unknown any tag %d", tag)
+	}
+}
+
+// finiteOrNil maps NaN and infinities, which JSON can't represent, to null
+func finiteOrNil(f float64) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil
+	}
+	return f
+}
+
+// encoder writes the lib0 binary encoding used by Yjs
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) writeByte(b byte) {
+	e.buf = append(e.buf, b)
+}
+
+func (e *encoder) writeUint(v uint64) {
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+func (e *encoder) writeBytes(b []byte) {
+	e.writeUint(uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) writeString(s string) {
+	e.writeUint(uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) writeRaw(b []byte) {
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) writeID(id ID) {
+	e.writeUint(id.Client)
+	e.writeUint(id.Clock)
+}
+
+// writeInt writes lib0's signed varint, the counterpart of readInt
+func (e *encoder) writeInt(v int64) {
+	num := uint64(v)
+	b := byte(0)
+	if v < 0 {
+		num = uint64(-v)
+		b = 0x40
+	}
+	b |= byte(num & 0x3f)
+	num >>= 6
+	for num > 0 {
+		e.writeByte(b | 0x80)
+		b = byte(num & 0x7f)
+		num >>= 7
+	}
+	e.writeByte(b)
+}
+
+// writeAny writes a JSON value as a lib0 "any" value. Whole numbers that fit
+// a JavaScript safe integer are written as integers, like lib0 does. Object
+// keys are written in sorted order so equal values encode the same.
+func (e *encoder) writeAny(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.writeByte(126)
+	case bool:
+		if v {
+			e.writeByte(120)
+		} else {
+			e.writeByte(121)
+		}
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= 1<<53-1 {
+			e.writeByte(125)
+			e.writeInt(int64(v))
+			return nil
+		}
+		e.writeByte(123)
+		e.buf = binary.BigEndian.AppendUint64(e.buf, math.Float64bits(v))
+	case string:
+		e.writeByte(119)
+		e.writeString(v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		e.writeByte(118)
+		e.writeUint(uint64(len(keys)))
+		for _, key := range keys {
+			e.writeString(key)
+			if err := e.writeAny(v[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		e.writeByte(117)
+		e.writeUint(uint64(len(v)))
+		for _, elem := range v {
+			if err := e.writeAny(elem); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("yjs: can't encode %T", v)
+	}
+	return nil
+}
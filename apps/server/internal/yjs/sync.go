@@ -0,0 +1,65 @@
+package yjs
+
+// y-websocket message types. Every frame starts with a varuint message type.
+const (
+	MessageSync           = 0
+	MessageAwareness      = 1
+	MessageAuth           = 2
+	MessageQueryAwareness = 3
+)
+
+// Sync protocol steps, written after MessageSync
+const (
+	SyncStep1  = 0 // payload is the sender's state vector
+	SyncStep2  = 1 // payload is an update answering a state vector
+	SyncUpdate = 2 // payload is an incremental update
+)
+
+// Message is a decoded y-websocket frame. Step is only set for sync
+// messages and Payload for sync and awareness messages.
+type Message struct {
+	Type    uint64
+	Step    uint64
+	Payload []byte
+}
+
+// DecodeMessage decodes the envelope of a y-websocket frame
+func DecodeMessage(frame []byte) (Message, error) {
+	dec := newDecoder(frame)
+	msgType, err := dec.readUint()
+	if err != nil {
+		return Message{}, err
+	}
+	msg := Message{Type: msgType}
+	switch msgType {
+	case MessageSync:
+		if msg.Step, err = dec.readUint(); err != nil {
+			return Message{}, err
+		}
+	case MessageAwareness:
+	default:
+		return msg, nil
+	}
+	if msg.Payload, err = dec.readBytes(); err != nil {
+		return Message{}, err
+	}
+	return msg, nil
+}
+
+// EncodeSyncMessage builds a sync frame for the given step
+func EncodeSyncMessage(step uint64, payload []byte) []byte {
+	e := &encoder{}
+	e.writeUint(MessageSync)
+	e.writeUint(step)
+	e.writeBytes(payload)
+	return e.buf
+}
+
+// EncodeMessage builds a frame of the given message type carrying a
+// length-prefixed payload
+func EncodeMessage(msgType uint64, payload []byte) []byte {
+	e := &encoder{}
+	e.writeUint(msgType)
+	e.writeBytes(payload)
+	return e.buf
+}
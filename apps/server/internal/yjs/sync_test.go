@@ -0,0 +1,94 @@
+package yjs
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+// An awareness update as y-protocols encodes it
+// (encodeAwarenessUpdate): client 5 at clock 3 with a user, and client 6 at
+// clock 4 gone offline
+const fixtureAwareness = "02" + // 2 clients
+	" 05 03 17 7b2275736572223a7b226e616d65223a22416e6e227d7d" + // 5, clock 3, {"user":{"name":"Ann"}}
+	" 06 04 04 6e756c6c" // 6, clock 4, null
+
+func TestDecodeAwarenessUpdate(t *testing.T) {
+	states, err := DecodeAwarenessUpdate(fixture(t, fixtureAwareness))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != 2 {
+		t.Fatalf("got %d states, want 2", len(states))
+	}
+
+	if s := states[0]; s.ClientID != 5 || s.Clock != 3 {
+		t.Errorf("first state is client %d at clock %d, want 5 at 3", s.ClientID, s.Clock)
+	}
+	var state struct {
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(states[0].State, &state); err != nil || state.User.Name != "Ann" {
+		t.Errorf("first state = %s, want Ann's", states[0].State)
+	}
+
+	if s := states[1]; s.ClientID != 6 || s.Clock != 4 || s.State != nil {
+		t.Errorf("second state = %+v, want client 6 at clock 4 offline", s)
+	}
+
+	full := fixture(t, fixtureAwareness)
+	if _, err := DecodeAwarenessUpdate(full[:len(full)-1]); err == nil {
+		t.Error("truncated awareness update decoded without error")
+	}
+}
+
+func TestDecodeMessage(t *testing.T) {
+	awareness := fixture(t, fixtureAwareness)
+	tests := []struct {
+		name  string
+		frame string
+		want  Message
+	}{
+		{"sync step 1", "00 00 03 01 01 02", Message{Type: MessageSync, Step: SyncStep1, Payload: fixture(t, "01 01 02")}},
+		{"sync update", "00 02 02 00 00", Message{Type: MessageSync, Step: SyncUpdate, Payload: fixture(t, "00 00")}},
+		{"awareness", "01 22 " + fixtureAwareness, Message{Type: MessageAwareness, Payload: awareness}},
+		{"query awareness", "03", Message{Type: MessageQueryAwareness}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := DecodeMessage(fixture(t, tt.frame))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.Type != tt.want.Type || msg.Step != tt.want.Step || !bytes.Equal(msg.Payload, tt.want.Payload) {
+				t.Errorf("message = %+v, want %+v", msg, tt.want)
+			}
+		})
+	}
+
+	if _, err := DecodeMessage(fixture(t, "00 01 05 00")); err == nil {
+		t.Error("sync message with a truncated payload decoded without error")
+	}
+}
+
+func TestEncodeMessagesRoundTrip(t *testing.T) {
+	update := fixture(t, fixturePushA)
+	msg, err := DecodeMessage(EncodeSyncMessage(SyncStep2, update))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != MessageSync || msg.Step != SyncStep2 || !bytes.Equal(msg.Payload, update) {
+		t.Errorf("sync message = %+v", msg)
+	}
+
+	awareness := fixture(t, fixtureAwareness)
+	msg, err = DecodeMessage(EncodeMessage(MessageAwareness, awareness))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != MessageAwareness || !bytes.Equal(msg.Payload, awareness) {
+		t.Errorf("awareness message = %+v", msg)
+	}
+}
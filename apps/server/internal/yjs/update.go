@@ -0,0 +1,450 @@
+package yjs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Bits of an item's info byte
+const (
+	infoHasOrigin      = 0x80
+	infoHasRightOrigin = 0x40
+	infoHasParentSub   = 0x20
+)
+
+// decodeUpdate reads a v1 update into per-client struct queues and a delete
+// set without touching the document's structs
+func (d *Doc) decodeUpdate(update []byte) (map[uint64][]*item, []deleteRange, error) {
+	dec := newDecoder(update)
+	queues := make(map[uint64][]*item)
+
+	numClients, err := dec.readUint()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := uint64(0); i < numClients; i++ {
+		numStructs, err := dec.readUint()
+		if err != nil {
+			return nil, nil, err
+		}
+		client, err := dec.readUint()
+		if err != nil {
+			return nil, nil, err
+		}
+		clock, err := dec.readUint()
+		if err != nil {
+			return nil, nil, err
+		}
+		for j := uint64(0); j < numStructs; j++ {
+			s, err := d.readStruct(dec, ID{Client: client, Clock: clock})
+			if err != nil {
+				return nil, nil, err
+			}
+			queues[client] = append(queues[client], s)
+			clock += s.length
+		}
+	}
+
+	deletes, err := readDeleteSet(dec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return queues, deletes, nil
+}
+
+func (d *Doc) readStruct(dec *decoder, id ID) (*item, error) {
+	info, err := dec.readByte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch info & refBitsMask {
+	case refGC:
+		n, err := dec.readUint()
+		return &item{id: id, length: n, kind: kindGC, deleted: true}, err
+	case refSkip:
+		n, err := dec.readUint()
+		return &item{id: id, length: n, kind: kindSkip}, err
+	}
+
+	it := &item{id: id, kind: kindItem}
+	if info&infoHasOrigin != 0 {
+		origin, err := readID(dec)
+		if err != nil {
+			return nil, err
+		}
+		it.origin = &origin
+	}
+	if info&infoHasRightOrigin != 0 {
+		rightOrigin, err := readID(dec)
+		if err != nil {
+			return nil, err
+		}
+		it.rightOrigin = &rightOrigin
+	}
+
+	// Without origins the parent is written out, otherwise it's copied from
+	// the neighbours on integration
+	if info&(infoHasOrigin|infoHasRightOrigin) == 0 {
+		isRoot, err := dec.readUint()
+		if err != nil {
+			return nil, err
+		}
+		if isRoot == 1 {
+			name, err := dec.readString()
+			if err != nil {
+				return nil, err
+			}
+			it.parent = d.root(name)
+		} else {
+			parentID, err := readID(dec)
+			if err != nil {
+				return nil, err
+			}
+			it.parentID = &parentID
+		}
+		if info&infoHasParentSub != 0 {
+			sub, err := dec.readString()
+			if err != nil {
+				return nil, err
+			}
+			it.parentSub = &sub
+		}
+	}
+
+	if it.content, err = readContent(dec, info); err != nil {
+		return nil, err
+	}
+	it.length = it.content.length()
+	if it.length == 0 {
+		return nil, fmt.Errorf("yjs: empty struct at %d:%d", id.Client, id.Clock)
+	}
+	return it, nil
+}
+
+func readID(dec *decoder) (ID, error) {
+	client, err := dec.readUint()
+	if err != nil {
+		return ID{}, err
+	}
+	clock, err := dec.readUint()
+	return ID{Client: client, Clock: clock}, err
+}
+
+func readDeleteSet(dec *decoder) ([]deleteRange, error) {
+	var ranges []deleteRange
+	numClients, err := dec.readUint()
+	if err != nil {
+		return nil, err
+	}
+	for i := uint64(0); i < numClients; i++ {
+		client, err := dec.readUint()
+		if err != nil {
+			return nil, err
+		}
+		n, err := dec.readUint()
+		if err != nil {
+			return nil, err
+		}
+		for j := uint64(0); j < n; j++ {
+			clock, err := dec.readUint()
+			if err != nil {
+				return nil, err
+			}
+			length, err := dec.readUint()
+			if err != nil {
+				return nil, err
+			}
+			ranges = append(ranges, deleteRange{client: client, clock: clock, length: length})
+		}
+	}
+	return ranges, nil
+}
+
+// write encodes the struct, skipping its first offset elements
+func (it *item) write(e *encoder, offset uint64) {
+	switch it.kind {
+	case kindGC:
+		e.writeByte(refGC)
+		e.writeUint(it.length - offset)
+		return
+	case kindSkip:
+		e.writeByte(refSkip)
+		e.writeUint(it.length - offset)
+		return
+	}
+
+	origin := it.origin
+	if offset > 0 {
+		origin = &ID{Client: it.id.Client, Clock: it.id.Clock + offset - 1}
+	}
+
+	info := it.content.ref() & refBitsMask
+	if origin != nil {
+		info |= infoHasOrigin
+	}
+	if it.rightOrigin != nil {
+		info |= infoHasRightOrigin
+	}
+	if it.parentSub != nil {
+		info |= infoHasParentSub
+	}
+	e.writeByte(info)
+
+	if origin != nil {
+		e.writeID(*origin)
+	}
+	if it.rightOrigin != nil {
+		e.writeID(*it.rightOrigin)
+	}
+	if origin == nil && it.rightOrigin == nil {
+		if it.parent.item == nil {
+			e.writeUint(1)
+			e.writeString(it.parent.name)
+		} else {
+			e.writeUint(0)
+			e.writeID(it.parent.item.id)
+		}
+		if it.parentSub != nil {
+			e.writeString(*it.parentSub)
+		}
+	}
+	it.content.write(e, offset)
+}
+
+// EncodeArrayEdits encodes an update, written by the given client, that turns
+// root arrays into the given JSON values. Elements are matched by key: an
+// element whose key is still wanted with an equal value is kept, every other
+// element is deleted and the values left over are appended. Elements without
+// a key are always replaced, and only the first element of each key is kept.
+// It returns nil when the arrays already hold the values.
+func (d *Doc) EncodeArrayEdits(client uint64, arrays map[string][]interface{}, key func(interface{}) (string, bool)) ([]byte, error) {
+	names := make([]string, 0, len(arrays))
+	for name := range arrays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	type insertion struct {
+		parent string
+		origin *ID
+		values []interface{}
+	}
+	var inserts []insertion
+	var deletes []deleteRange
+
+	for _, name := range names {
+		wanted := make(map[string][]byte)
+		for _, v := range arrays[name] {
+			if k, ok := key(v); ok && wanted[k] == nil {
+				encoded, err := json.Marshal(v)
+				if err != nil {
+					return nil, err
+				}
+				wanted[k] = encoded
+			}
+		}
+
+		kept := make(map[string]bool)
+		var last *ID
+		if t, ok := d.roots[name]; ok {
+			for it := t.start; it != nil; it = it.right {
+				lastID := it.lastID()
+				last = &lastID
+				if it.deleted || !it.content.countable() {
+					continue
+				}
+				for i, v := range it.content.values() {
+					if k, ok := key(v); ok && !kept[k] && wanted[k] != nil {
+						if encoded, err := json.Marshal(v); err == nil && bytes.Equal(encoded, wanted[k]) {
+							kept[k] = true
+							continue
+						}
+					}
+					deletes = append(deletes, deleteRange{client: it.id.Client, clock: it.id.Clock + uint64(i), length: 1})
+				}
+			}
+		}
+
+		var added []interface{}
+		for _, v := range arrays[name] {
+			if k, ok := key(v); ok {
+				if kept[k] {
+					continue
+				}
+				kept[k] = true
+			}
+			added = append(added, v)
+		}
+		if len(added) > 0 {
+			inserts = append(inserts, insertion{parent: name, origin: last, values: added})
+		}
+	}
+
+	if len(inserts) == 0 && len(deletes) == 0 {
+		return nil, nil
+	}
+
+	e := &encoder{}
+	if len(inserts) == 0 {
+		e.writeUint(0)
+	} else {
+		e.writeUint(1)
+		e.writeUint(uint64(len(inserts)))
+		e.writeUint(client)
+		e.writeUint(d.state(client))
+		for _, ins := range inserts {
+			// Appended after the array's last element, or as its first
+			if ins.origin != nil {
+				e.writeByte(refAny | infoHasOrigin)
+				e.writeID(*ins.origin)
+			} else {
+				e.writeByte(refAny)
+				e.writeUint(1)
+				e.writeString(ins.parent)
+			}
+			e.writeUint(uint64(len(ins.values)))
+			for _, v := range ins.values {
+				if err := e.writeAny(v); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	writeDeleteRanges(e, deletes)
+	return e.buf, nil
+}
+
+// StateVector returns the next expected clock of every client
+func (d *Doc) StateVector() map[uint64]uint64 {
+	sv := make(map[uint64]uint64, len(d.clients))
+	for client := range d.clients {
+		sv[client] = d.state(client)
+	}
+	return sv
+}
+
+// EncodeStateVector encodes the document's state vector for sync step 1
+func (d *Doc) EncodeStateVector() []byte {
+	e := &encoder{}
+	sv := d.StateVector()
+	e.writeUint(uint64(len(sv)))
+	for _, client := range sortedClients(sv) {
+		e.writeUint(client)
+		e.writeUint(sv[client])
+	}
+	return e.buf
+}
+
+// DecodeStateVector decodes a state vector sent in sync step 1
+func DecodeStateVector(b []byte) (map[uint64]uint64, error) {
+	dec := newDecoder(b)
+	n, err := dec.readUint()
+	if err != nil {
+		return nil, err
+	}
+	sv := make(map[uint64]uint64, min(n, 1024))
+	for i := uint64(0); i < n; i++ {
+		client, err := dec.readUint()
+		if err != nil {
+			return nil, err
+		}
+		if sv[client], err = dec.readUint(); err != nil {
+			return nil, err
+		}
+	}
+	return sv, nil
+}
+
+// EncodeStateAsUpdate encodes everything the document has beyond the given
+// state vector, plus its full delete set. A nil state vector encodes the
+// whole document.
+func (d *Doc) EncodeStateAsUpdate(sv map[uint64]uint64) []byte {
+	e := &encoder{}
+
+	var clients []uint64
+	for client := range d.clients {
+		if d.state(client) > sv[client] {
+			clients = append(clients, client)
+		}
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+
+	e.writeUint(uint64(len(clients)))
+	for _, client := range clients {
+		structs := d.clients[client]
+		clock := max(sv[client], structs[0].id.Clock)
+		start, _ := findIndex(structs, clock)
+
+		e.writeUint(uint64(len(structs) - start))
+		e.writeUint(client)
+		e.writeUint(clock)
+		structs[start].write(e, clock-structs[start].id.Clock)
+		for _, s := range structs[start+1:] {
+			s.write(e, 0)
+		}
+	}
+
+	d.writeDeleteSet(e)
+	return e.buf
+}
+
+// writeDeleteSet encodes the ranges of every deleted struct
+func (d *Doc) writeDeleteSet(e *encoder) {
+	var deleted []deleteRange
+	for client, structs := range d.clients {
+		for _, s := range structs {
+			if s.deleted {
+				deleted = append(deleted, deleteRange{client: client, clock: s.id.Clock, length: s.length})
+			}
+		}
+	}
+	writeDeleteRanges(e, deleted)
+}
+
+// writeDeleteRanges encodes a delete set, merging adjacent ranges
+func writeDeleteRanges(e *encoder, deleted []deleteRange) {
+	sort.Slice(deleted, func(i, j int) bool {
+		if deleted[i].client != deleted[j].client {
+			return deleted[i].client < deleted[j].client
+		}
+		return deleted[i].clock < deleted[j].clock
+	})
+	ranges := make(map[uint64][]deleteRange)
+	for _, r := range deleted {
+		rs := ranges[r.client]
+		if n := len(rs); n > 0 && rs[n-1].clock+rs[n-1].length >= r.clock {
+			rs[n-1].length = max(rs[n-1].length, r.clock+r.length-rs[n-1].clock)
+		} else {
+			rs = append(rs, r)
+		}
+		ranges[r.client] = rs
+	}
+
+	clients := make([]uint64, 0, len(ranges))
+	for client := range ranges {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+
+	e.writeUint(uint64(len(clients)))
+	for _, client := range clients {
+		e.writeUint(client)
+		e.writeUint(uint64(len(ranges[client])))
+		for _, r := range ranges[client] {
+			e.writeUint(r.clock)
+			e.writeUint(r.length)
+		}
+	}
+}
+
+func sortedClients(sv map[uint64]uint64) []uint64 {
+	clients := make([]uint64, 0, len(sv))
+	for client := range sv {
+		clients = append(clients, client)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i] > clients[j] })
+	return clients
+}
@@ -0,0 +1,353 @@
+package yjs
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Updates as Yjs encodes them (Y.encodeStateAsUpdate, v1), written out
+// byte by byte. Each comment is the edit that produced the update.
+const (
+	// Client 1: doc.getArray("nodes").push([{id: "a"}])
+	fixturePushA = "01 01 01 00" + // 1 client, 1 struct, client 1, clock 0
+		" 08 01 05 6e6f646573" + // ContentAny, parent is the root "nodes"
+		" 01 76 01 02 6964 77 01 61" + // [{id: "a"}]
+		" 00" // no deletes
+
+	// Client 1, next: nodes.push([{id: "b"}]), placed after "a"
+	fixturePushB = "01 01 01 01" + // 1 client, 1 struct, client 1, clock 1
+		" 88 01 00" + // ContentAny, origin 1:0
+		" 01 76 01 02 6964 77 01 62" + // [{id: "b"}]
+		" 00"
+
+	// Client 2, having seen only "a": nodes.insert(0, [{id: "c"}])
+	fixtureInsertC = "01 01 02 00" + // 1 client, 1 struct, client 2, clock 0
+		" 48 01 00" + // ContentAny, right origin 1:0
+		" 01 76 01 02 6964 77 01 63" + // [{id: "c"}]
+		" 00"
+
+	// Client 1: nodes.delete(0, 1), deleting "a"
+	fixtureDeleteA = "00" + // no structs
+		" 01 01 01 00 01" // 1 client, client 1, 1 range, clock 0, length 1
+
+	// Client 3: doc.getArray("edges").push(["x", 42, true, null, 1.5])
+	fixturePrimitives = "01 01 03 00" +
+		" 08 01 05 6564676573" + // ContentAny, parent is the root "edges"
+		" 05 77 01 78 7d 2a 78 7e" + // "x", 42, true, null
+		" 7b 3ff8000000000000" + // 1.5 as a float64
+		" 00"
+)
+
+func fixture(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatalf("bad fixture %q: %v", s, err)
+	}
+	return b
+}
+
+func applyFixtures(t *testing.T, d *Doc, fixtures ...string) {
+	t.Helper()
+	for _, f := range fixtures {
+		if err := d.ApplyUpdate(fixture(t, f)); err != nil {
+			t.Fatalf("apply %q: %v", f, err)
+		}
+	}
+}
+
+// ids returns the "id" of each object in the array
+func ids(t *testing.T, d *Doc, name string) []string {
+	t.Helper()
+	values, _ := d.Array(name)
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			t.Fatalf("%s holds %T, want an object", name, v)
+		}
+		out = append(out, obj["id"].(string))
+	}
+	return out
+}
+
+func TestApplyUpdateDecodesArrays(t *testing.T) {
+	d := NewDoc()
+	if _, ok := d.Array("nodes"); ok {
+		t.Fatal("empty document has a nodes array")
+	}
+	applyFixtures(t, d, fixturePushA, fixturePushB, fixturePrimitives)
+
+	if got := ids(t, d, "nodes"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("nodes = %v, want [a b]", got)
+	}
+	edges, ok := d.Array("edges")
+	if !ok {
+		t.Fatal("edges array missing")
+	}
+	want := []interface{}{"x", int64(42), true, nil, 1.5}
+	if len(edges) != len(want) {
+		t.Fatalf("edges = %#v, want %#v", edges, want)
+	}
+	for i := range want {
+		if !equalNumber(edges[i], want[i]) {
+			t.Errorf("edges[%d] = %#v, want %#v", i, edges[i], want[i])
+		}
+	}
+}
+
+// equalNumber compares values, treating numbers of any Go type as equal
+// when their values are
+func equalNumber(a, b interface{}) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
+
+func TestApplyUpdateRejectsTruncatedUpdates(t *testing.T) {
+	full := fixture(t, fixturePushA)
+	for n := 1; n < len(full); n++ {
+		if err := NewDoc().ApplyUpdate(full[:n]); err == nil {
+			t.Errorf("update truncated to %d bytes applied without error", n)
+		}
+	}
+}
+
+func TestApplyUpdateOutOfOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		fixtures []string
+		want     []string
+	}{
+		{"in order", []string{fixturePushA, fixturePushB, fixtureInsertC}, []string{"c", "a", "b"}},
+		{"successor first", []string{fixturePushB, fixturePushA, fixtureInsertC}, []string{"c", "a", "b"}},
+		{"other client first", []string{fixtureInsertC, fixturePushB, fixturePushA}, []string{"c", "a", "b"}},
+		{"delete first", []string{fixtureDeleteA, fixtureInsertC, fixturePushA, fixturePushB}, []string{"c", "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDoc()
+			applyFixtures(t, d, tt.fixtures...)
+			if got := ids(t, d, "nodes"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("nodes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyUpdateIsIdempotent(t *testing.T) {
+	d := NewDoc()
+	applyFixtures(t, d, fixturePushA, fixturePushB, fixturePushA, fixtureDeleteA, fixtureDeleteA, fixturePushB)
+	if got := ids(t, d, "nodes"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("nodes = %v, want [b]", got)
+	}
+}
+
+func TestDeleteSetRoundTrip(t *testing.T) {
+	d := NewDoc()
+	applyFixtures(t, d, fixturePushA, fixturePushB, fixtureDeleteA)
+
+	// The full state carries the deletion, so a fresh document agrees
+	copied := NewDoc()
+	if err := copied.ApplyUpdate(d.EncodeStateAsUpdate(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, copied, "nodes"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("nodes = %v, want [b]", got)
+	}
+
+	// A document that has both items only needs the deletion
+	behind := NewDoc()
+	applyFixtures(t, behind, fixturePushA, fixturePushB)
+	if err := behind.ApplyUpdate(d.EncodeStateAsUpdate(behind.StateVector())); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(t, behind, "nodes"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("nodes = %v, want [b]", got)
+	}
+}
+
+func TestWriteDeleteRangesMergesAdjacentRanges(t *testing.T) {
+	e := &encoder{}
+	writeDeleteRanges(e, []deleteRange{
+		{client: 1, clock: 3, length: 2},
+		{client: 2, clock: 0, length: 1},
+		{client: 1, clock: 0, length: 3},
+		{client: 1, clock: 4, length: 2},
+		{client: 1, clock: 9, length: 1},
+	})
+	// Clients in descending order; 1:0-5 merged, 1:9 apart
+	want := fixture(t, "02 02 01 00 01 01 02 00 06 09 01")
+	if !bytes.Equal(e.buf, want) {
+		t.Errorf("delete set = % x, want % x", e.buf, want)
+	}
+}
+
+func TestStateVector(t *testing.T) {
+	d := NewDoc()
+	applyFixtures(t, d, fixturePushA, fixturePushB, fixtureInsertC)
+
+	sv := d.StateVector()
+	if want := map[uint64]uint64{1: 2, 2: 1}; !reflect.DeepEqual(sv, want) {
+		t.Errorf("state vector = %v, want %v", sv, want)
+	}
+
+	// Written as y-protocols does: clients in descending order
+	encoded := d.EncodeStateVector()
+	if want := fixture(t, "02 02 01 01 02"); !bytes.Equal(encoded, want) {
+		t.Errorf("encoded state vector = % x, want % x", encoded, want)
+	}
+	decoded, err := DecodeStateVector(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, sv) {
+		t.Errorf("decoded state vector = %v, want %v", decoded, sv)
+	}
+
+	if _, err := DecodeStateVector(fixture(t, "02 01")); err == nil {
+		t.Error("truncated state vector decoded without error")
+	}
+}
+
+func TestEncodeStateAsUpdateDiff(t *testing.T) {
+	d := NewDoc()
+	applyFixtures(t, d, fixturePushA, fixturePushB, fixtureInsertC)
+
+	tests := []struct {
+		name  string
+		known []string
+	}{
+		{"nothing", nil},
+		{"first item", []string{fixturePushA}},
+		{"one client", []string{fixturePushA, fixturePushB}},
+		{"other client", []string{fixturePushA, fixtureInsertC}},
+		{"everything", []string{fixturePushA, fixturePushB, fixtureInsertC}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			peer := NewDoc()
+			applyFixtures(t, peer, tt.known...)
+			if err := peer.ApplyUpdate(d.EncodeStateAsUpdate(peer.StateVector())); err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(t, peer, "nodes"); !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+				t.Errorf("nodes = %v, want [c a b]", got)
+			}
+			if !reflect.DeepEqual(peer.StateVector(), d.StateVector()) {
+				t.Errorf("state vector = %v, want %v", peer.StateVector(), d.StateVector())
+			}
+		})
+	}
+
+	// Nothing is missing: no structs and the (empty) delete set
+	if got := d.EncodeStateAsUpdate(d.StateVector()); !bytes.Equal(got, fixture(t, "00 00")) {
+		t.Errorf("empty diff = % x, want 00 00", got)
+	}
+}
+
+func TestEncodeArrayEditsMatchesYjs(t *testing.T) {
+	update, err := NewDoc().EncodeArrayEdits(1, map[string][]interface{}{
+		"nodes": {map[string]interface{}{"id": "a"}},
+	}, idKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fixture(t, fixturePushA); !bytes.Equal(update, want) {
+		t.Errorf("update = % x, want % x", update, want)
+	}
+}
+
+func idKey(v interface{}) (string, bool) {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	id, ok := obj["id"].(string)
+	return id, ok
+}
+
+func TestEncodeArrayEditsRoundTrip(t *testing.T) {
+	d := NewDoc()
+	applyFixtures(t, d, fixturePushA, fixturePushB, fixtureInsertC)
+	peer := NewDoc()
+	applyFixtures(t, peer, fixturePushA, fixturePushB, fixtureInsertC)
+
+	// Keep c, change a, drop b and add d
+	want := map[string][]interface{}{
+		"nodes": {
+			map[string]interface{}{"id": "c"},
+			map[string]interface{}{"id": "a", "x": "moved"},
+			map[string]interface{}{"id": "d"},
+		},
+		"edges": {map[string]interface{}{"id": "e"}},
+	}
+	update, err := d.EncodeArrayEdits(9, want, idKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update == nil {
+		t.Fatal("no update for changed arrays")
+	}
+
+	for name, doc := range map[string]*Doc{"writer": d, "peer": peer} {
+		if err := doc.ApplyUpdate(update); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got := ids(t, doc, "nodes"); !reflect.DeepEqual(got, []string{"c", "a", "d"}) {
+			t.Errorf("%s: nodes = %v, want [c a d]", name, got)
+		}
+		nodes, _ := doc.Array("nodes")
+		if moved := nodes[1].(map[string]interface{}); moved["x"] != "moved" {
+			t.Errorf("%s: a = %v, want it moved", name, moved)
+		}
+		if got := ids(t, doc, "edges"); !reflect.DeepEqual(got, []string{"e"}) {
+			t.Errorf("%s: edges = %v, want [e]", name, got)
+		}
+	}
+
+	again, err := d.EncodeArrayEdits(9, want, idKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != nil {
+		t.Errorf("arrays already match, but got update % x", again)
+	}
+}
+
+func TestEncodeArrayEditsKeepsFirstOfEachKey(t *testing.T) {
+	d := NewDoc()
+	update, err := d.EncodeArrayEdits(1, map[string][]interface{}{
+		"nodes": {
+			map[string]interface{}{"id": "a", "n": "first"},
+			map[string]interface{}{"id": "a", "n": "second"},
+		},
+	}, idKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.ApplyUpdate(update); err != nil {
+		t.Fatal(err)
+	}
+	nodes, _ := d.Array("nodes")
+	if len(nodes) != 1 || nodes[0].(map[string]interface{})["n"] != "first" {
+		t.Errorf("nodes = %v, want only the first a", nodes)
+	}
+}
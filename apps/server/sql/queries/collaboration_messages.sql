@@ -0,0 +1,15 @@
+-- name: NotifyCollaborationChannel :exec
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
+
+-- name: CreateCollaborationMessage :one
+INSERT INTO collaboration_messages (payload)
+VALUES ($1)
+RETURNING id;
+
+-- name: GetCollaborationMessage :one
+SELECT payload FROM collaboration_messages
+WHERE id = $1;
+
+-- name: DeleteCollaborationMessagesBefore :exec
+DELETE FROM collaboration_messages
+WHERE created_at < $1;
@@ -0,0 +1,9 @@
+-- name: GetProjectDocument :one
+SELECT state FROM project_documents
+WHERE project_id = $1;
+
+-- name: UpsertProjectDocument :exec
+INSERT INTO project_documents (project_id, state)
+VALUES ($1, $2)
+ON CONFLICT (project_id)
+DO UPDATE SET state = EXCLUDED.state, updated_at = NOW();
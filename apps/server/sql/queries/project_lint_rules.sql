@@ -0,0 +1,10 @@
+-- name: ListProjectLintRules :many
+SELECT * FROM project_lint_rules
+WHERE project_id = $1
+ORDER BY rule_id;
+
+-- name: UpsertProjectLintRule :exec
+INSERT INTO project_lint_rules (project_id, rule_id, enabled)
+VALUES ($1, $2, $3)
+ON CONFLICT (project_id, rule_id)
+DO UPDATE SET enabled = EXCLUDED.enabled, updated_at = NOW();
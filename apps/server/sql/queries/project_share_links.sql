@@ -1,6 +1,6 @@
 -- name: CreateProjectShareLink :one
-INSERT INTO project_share_links (project_id, token, room_key, created_by, expires_at)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO project_share_links (project_id, token, room_key, created_by, expires_at, name, role, max_uses)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 RETURNING *;
 
 -- name: GetActiveShareLinkForProject :one
@@ -11,6 +11,26 @@ WHERE project_id = $1
 ORDER BY created_at DESC
 LIMIT 1;
 
+-- name: GetProjectRoomKey :one
+SELECT room_key
+FROM project_share_links
+WHERE project_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetProjectIDByRoomKey :one
+SELECT project_id
+FROM project_share_links
+WHERE room_key = $1
+LIMIT 1;
+
+-- name: ListShareLinksForProject :many
+SELECT *
+FROM project_share_links
+WHERE project_id = $1
+  AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
 -- name: GetShareLinkByToken :one
 SELECT *
 FROM project_share_links
@@ -18,9 +38,30 @@ WHERE token = $1
   AND revoked_at IS NULL
 LIMIT 1;
 
+-- name: ClaimShareLinkUse :one
+UPDATE project_share_links
+SET use_count = use_count + 1,
+    last_used_at = NOW()
+WHERE id = $1
+  AND revoked_at IS NULL
+  AND (expires_at IS NULL OR expires_at > NOW())
+  AND (max_uses IS NULL OR use_count < max_uses)
+RETURNING *;
+
+-- name: TouchShareLink :exec
+UPDATE project_share_links
+SET last_used_at = NOW()
+WHERE id = $1;
+
+-- name: RevokeShareLink :execrows
+UPDATE project_share_links
+SET revoked_at = NOW()
+WHERE id = $1
+  AND project_id = $2
+  AND revoked_at IS NULL;
+
 -- name: RevokeShareLinksForProject :exec
 UPDATE project_share_links
 SET revoked_at = NOW()
 WHERE project_id = $1
   AND revoked_at IS NULL;
-
@@ -0,0 +1,27 @@
+-- name: CreateProjectVersion :one
+INSERT INTO project_versions (project_id, version_number, data, content_hash, message, created_by)
+VALUES (
+    $1,
+    (SELECT COALESCE(MAX(pv.version_number), 0) + 1 FROM project_versions pv WHERE pv.project_id = $1),
+    $2, $3, $4, $5
+)
+RETURNING *;
+
+-- name: GetLatestProjectVersion :one
+SELECT *
+FROM project_versions
+WHERE project_id = $1
+ORDER BY version_number DESC
+LIMIT 1;
+
+-- name: GetProjectVersion :one
+SELECT *
+FROM project_versions
+WHERE id = $1 AND project_id = $2;
+
+-- name: ListProjectVersions :many
+SELECT pv.id, pv.project_id, pv.version_number, pv.content_hash, pv.message, pv.created_by, u.name as author_name, pv.created_at
+FROM project_versions pv
+LEFT JOIN users u ON pv.created_by = u.id
+WHERE pv.project_id = $1
+ORDER BY pv.version_number DESC;
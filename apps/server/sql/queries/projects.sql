@@ -30,6 +30,9 @@ SET name = $2,
 WHERE id = $1
 RETURNING *;
 
+-- name: LockProject :one
+SELECT id FROM projects WHERE id = $1 FOR UPDATE;
+
 -- name: UpdateProjectData :one
 UPDATE projects
 SET data = $2,
@@ -62,4 +65,24 @@ UNION ALL
 SELECT u.id, u.email, u.name, u.avatar_url, u.provider, pc.role, pc.created_at
 FROM project_collaborators pc
 JOIN users u ON pc.user_id = u.id
-WHERE pc.project_id = $1;
\ No newline at end of file
+WHERE pc.project_id = $1;
+
+-- name: UpdateProjectCollaboratorRole :execrows
+UPDATE project_collaborators
+SET role = $3
+WHERE project_id = $1 AND user_id = $2;
+
+-- name: DeleteProjectCollaborator :execrows
+DELETE FROM project_collaborators
+WHERE project_id = $1 AND user_id = $2;
+
+-- name: ListProjectCanvasesAfter :many
+SELECT id, data FROM projects
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: ReplaceProjectCanvas :execrows
+UPDATE projects
+SET data = sqlc.arg(data)
+WHERE id = sqlc.arg(id) AND data = sqlc.arg(previous);
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS project_versions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    version_number INTEGER NOT NULL,
+    data JSONB NOT NULL,
+    content_hash TEXT NOT NULL,
+    message TEXT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE(project_id, version_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_project_versions_project_id ON project_versions(project_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS project_versions;
@@ -0,0 +1,12 @@
+-- +goose Up
+UPDATE project_collaborators
+SET role = 'editor'
+WHERE role NOT IN ('admin', 'editor', 'commenter', 'viewer');
+
+ALTER TABLE project_collaborators
+    ADD CONSTRAINT project_collaborators_role_check
+    CHECK (role IN ('admin', 'editor', 'commenter', 'viewer'));
+
+-- +goose Down
+ALTER TABLE project_collaborators
+    DROP CONSTRAINT IF EXISTS project_collaborators_role_check;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- Links of the same project share one collaboration room, so room_key is no
+-- longer unique per link.
+ALTER TABLE project_share_links DROP CONSTRAINT IF EXISTS project_share_links_room_key_key;
+CREATE INDEX IF NOT EXISTS idx_project_share_links_room_key ON project_share_links(room_key);
+
+ALTER TABLE project_share_links
+    ADD COLUMN name TEXT,
+    ADD COLUMN role TEXT NOT NULL DEFAULT 'editor' CHECK (role IN ('viewer', 'editor')),
+    ADD COLUMN max_uses INTEGER CHECK (max_uses IS NULL OR max_uses > 0),
+    ADD COLUMN use_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE project_share_links
+    DROP COLUMN IF EXISTS use_count,
+    DROP COLUMN IF EXISTS max_uses,
+    DROP COLUMN IF EXISTS role,
+    DROP COLUMN IF EXISTS name;
+
+-- Links sharing a room each get their own again, as before; the oldest
+-- keeps the key
+UPDATE project_share_links
+SET room_key = replace(gen_random_uuid()::text, '-', '')
+WHERE id IN (
+    SELECT id FROM (
+        SELECT id, row_number() OVER (PARTITION BY room_key ORDER BY created_at, id) AS n
+        FROM project_share_links
+    ) ranked
+    WHERE n > 1
+);
+
+DROP INDEX IF EXISTS idx_project_share_links_room_key;
+ALTER TABLE project_share_links ADD CONSTRAINT project_share_links_room_key_key UNIQUE (room_key);
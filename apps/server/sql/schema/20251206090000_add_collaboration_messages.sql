@@ -0,0 +1,13 @@
+-- +goose Up
+-- Collaboration messages too large for a NOTIFY payload are stored here and
+-- the notification only carries the row's id.
+CREATE TABLE collaboration_messages (
+    id BIGSERIAL PRIMARY KEY,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_collaboration_messages_created_at ON collaboration_messages(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS collaboration_messages;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Per-project overrides for schema lint rules. Rules without a row are
+-- enabled.
+CREATE TABLE project_lint_rules (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    rule_id TEXT NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (project_id, rule_id)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS project_lint_rules;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- The Yjs document behind a project's collaboration rooms, saved next to
+-- projects.data. Rooms reopen from it, so clients that reconnect merge into
+-- the same document instead of a newly seeded copy.
+CREATE TABLE project_documents (
+    project_id UUID PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    state BYTEA NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS project_documents;
@@ -57,6 +57,40 @@ const serializePeers = (peers: CollaboratorPresence[]): string => {
   return peers.map(p => `${p.id}:${p.name}`).sort().join('|');
 };
 
+// syncArray brings a shared array in line with the store item by item, keyed
+// by ID: removed items are deleted, changed ones replaced in place and new
+// ones appended. Items other peers added meanwhile stay untouched, so a
+// client that reconnects with an old copy of the document never re-adds the
+// whole canvas next to the server's. Like the server, only the first item of
+// each ID is kept.
+const syncArray = <T extends { id: string }>(array: Y.Array<T>, items: T[]) => {
+  const wanted = new Map<string, T>();
+  for (const item of items) {
+    if (!wanted.has(item.id)) wanted.set(item.id, item);
+  }
+  const kept = new Set<string>();
+
+  for (let index = 0; index < array.length; ) {
+    const current = array.get(index);
+    const next = wanted.get(current.id);
+    if (!next || kept.has(current.id)) {
+      array.delete(index, 1);
+      continue;
+    }
+    kept.add(current.id);
+    if (JSON.stringify(current) !== JSON.stringify(next)) {
+      array.delete(index, 1);
+      array.insert(index, [deepClone(next)]);
+    }
+    index++;
+  }
+
+  const added = [...wanted.values()].filter((item) => !kept.has(item.id));
+  if (added.length > 0) {
+    array.push(added.map((item) => deepClone(item)));
+  }
+};
+
 export function useCanvasCollaboration(options: UseCanvasCollaborationOptions) {
   const setNodes = useCanvasStore((state) => state.setNodes);
   const setEdges = useCanvasStore((state) => state.setEdges);
@@ -199,18 +233,15 @@ export function useCanvasCollaboration(options: UseCanvasCollaborationOptions) {
     nodesArray.observeDeep(syncNodesFromDoc);
     edgesArray.observeDeep(syncEdgesFromDoc);
 
-    const initialState = useCanvasStore.getState();
-    doc.transact(() => {
-      if (nodesArray.length === 0 && initialState.nodes.length > 0) {
-        nodesArray.push(deepClone(initialState.nodes));
-      }
-      if (edgesArray.length === 0 && initialState.edges.length > 0) {
-        edgesArray.push(deepClone(initialState.edges));
-      }
-    });
-
-    if (nodesArray.length > 0) syncNodesFromDoc();
-    if (edgesArray.length > 0) syncEdgesFromDoc();
+    // The server seeds the room from the saved project, so the local canvas
+    // is only pushed once synced, and only into a room that's still empty
+    const seedFromStore = () => {
+      const state = useCanvasStore.getState();
+      doc.transact(() => {
+        if (nodesArray.length === 0) syncArray(nodesArray, state.nodes);
+        if (edgesArray.length === 0) syncArray(edgesArray, state.edges);
+      });
+    };
 
     const awarenessChangeHandler = ({ added, removed }: { added: number[]; removed: number[] }) => {
       updatePeers();
@@ -225,6 +256,8 @@ export function useCanvasCollaboration(options: UseCanvasCollaborationOptions) {
     
     const syncHandler = (synced: boolean) => {
       if (synced) {
+        seedFromStore();
+        flushSync();
         forceUpdateAwareness();
         updatePeers();
       }
@@ -244,21 +277,17 @@ export function useCanvasCollaboration(options: UseCanvasCollaborationOptions) {
     let pendingEdgesSync = false;
     
     const flushSync = () => {
+      // Edits made before the first sync are pushed once it's done
+      if (!provider.synced) return;
       const state = useCanvasStore.getState();
       
       if (pendingNodesSync && !applyingNodes.current) {
-        doc.transact(() => {
-          nodesArray.delete(0, nodesArray.length);
-          nodesArray.push(deepClone(state.nodes));
-        });
+        doc.transact(() => syncArray(nodesArray, state.nodes));
         pendingNodesSync = false;
       }
       
       if (pendingEdgesSync && !applyingEdges.current) {
-        doc.transact(() => {
-          edgesArray.delete(0, edgesArray.length);
-          edgesArray.push(deepClone(state.edges));
-        });
+        doc.transact(() => syncArray(edgesArray, state.edges));
         pendingEdgesSync = false;
       }
     };